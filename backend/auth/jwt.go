@@ -13,39 +13,77 @@ import (
 
 // JWTClaims represents the claims in the JWT token
 type JWTClaims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    uint        `json:"user_id"`
+	Username  string      `json:"username"`
+	Role      string      `json:"role"`
+	SessionID string      `json:"sid"`
+	Purpose   string      `json:"purpose,omitempty"` // Set only on challenge tokens
+	Actor     *ActorClaim `json:"act,omitempty"`     // Set only on impersonation tokens
 	jwt.RegisteredClaims
 }
 
-// Generate JWT token for a user
-func GenerateToken(user *model.User) (string, error) {
-	// Get the JWT secret from environment
-	jwtSecret := getEnv("JWT_SECRET", "")
-	
-	// Ensure a secret is set
-	if jwtSecret == "" {
-		return "", errors.New("JWT_SECRET environment variable must be set")
+// Challenge token purposes issued by Login when a second factor or a new password is needed
+const (
+	PurposeMFARequired            = "mfa_required"
+	PurposeMFASetupRequired       = "mfa_setup_required"
+	PurposePasswordChangeRequired = "password_change_required"
+)
+
+// MFAChallengeTTL is the lifetime of challenge tokens
+const MFAChallengeTTL = 5 * time.Minute
+
+// AccessTokenTTL returns the lifetime of access tokens, 15 minutes by default
+func AccessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(getEnv("JWT_ACCESS_EXPIRATION_MINUTES", "15"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
 	}
-	
-	// Get expiration duration from environment variable, default to 24 hours
-	expirationStr := getEnv("JWT_EXPIRATION_HOURS", "24")
-	expirationHours, err := strconv.Atoi(expirationStr)
-	if err != nil {
-		expirationHours = 24 // Default to 24 hours if parsing fails
+	return time.Duration(minutes) * time.Minute
+}
+
+// RefreshTokenTTL returns the lifetime of a login session and its refresh tokens, 7 days by default
+func RefreshTokenTTL() time.Duration {
+	hours, err := strconv.Atoi(getEnv("JWT_REFRESH_EXPIRATION_HOURS", "168"))
+	if err != nil || hours <= 0 {
+		hours = 168
 	}
-	
+	return time.Duration(hours) * time.Hour
+}
+
+// GenerateToken generates a short-lived access token for a user bound to a session
+func GenerateToken(user *model.User, sessionID string) (string, error) {
 	// Set expiration time
-	expirationTime := time.Now().Add(time.Duration(expirationHours) * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL())
 	
-	// Create claims with user information
+	// Create claims with user and session information
+	claims := &JWTClaims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "worksite-management-studio",
+			Subject:   fmt.Sprintf("%d", user.ID),
+		},
+	}
+	
+	// Generate the signed token string
+	return signToken(claims)
+}
+
+// GenerateChallengeToken generates a short-lived token that only proves the password step of a login.
+// It cannot be used as an access token.
+func GenerateChallengeToken(user *model.User, purpose string) (string, error) {
 	claims := &JWTClaims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
+		Purpose:  purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFAChallengeTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "worksite-management-studio",
@@ -53,46 +91,110 @@ func GenerateToken(user *model.User) (string, error) {
 		},
 	}
 	
-	// Create the token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return signToken(claims)
+}
+
+// signToken signs claims with the current signing key, or with JWT_SECRET when HS256 is configured
+func signToken(claims *JWTClaims) (string, error) {
+	if SigningAlgorithm() == AlgHS256 {
+		jwtSecret := getEnv("JWT_SECRET", "")
+		
+		// Ensure a secret is set
+		if jwtSecret == "" {
+			return "", errors.New("JWT_SECRET environment variable must be set")
+		}
+		
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(jwtSecret))
+	}
 	
-	// Generate the signed token string
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	key, err := ring.signer()
 	if err != nil {
 		return "", err
 	}
 	
-	return tokenString, nil
+	// The key ID tells verifiers which published key to use
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.privateKey)
+}
+
+// ValidateChallengeToken validates a challenge token issued for the given purpose
+func ValidateChallengeToken(tokenString, purpose string) (*JWTClaims, error) {
+	claims, err := ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid challenge token")
+	}
+	
+	return claims, nil
 }
 
 // ValidateToken validates the JWT token and returns the claims
 func ValidateToken(tokenString string) (*JWTClaims, error) {
-	jwtSecret := getEnv("JWT_SECRET", "")
+	// Parse the JWT string and store the result in claims
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, verificationKey)
 	
-	// Ensure a secret is set
-	if jwtSecret == "" {
-		return nil, errors.New("JWT_SECRET environment variable must be set")
+	if err != nil {
+		return nil, err
 	}
 	
-	// Parse the JWT string and store the result in claims
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+	// Check if the token is valid
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		return claims, nil
+	}
+	
+	return nil, errors.New("invalid token")
+}
+
+// LegacySecretCutoff returns until when tokens signed with JWT_SECRET are accepted after switching
+// to RS256 or EdDSA, read from JWT_SECRET_ACCEPT_UNTIL as an RFC 3339 time or a YYYY-MM-DD date.
+// Without it such tokens are not accepted at all.
+func LegacySecretCutoff() time.Time {
+	value := getEnv("JWT_SECRET_ACCEPT_UNTIL", "")
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if cutoff, err := time.Parse(layout, value); err == nil {
+			return cutoff
+		}
+	}
+	return time.Time{}
+}
+
+// verificationKey selects the key of a token from its "kid" header. Tokens without a key ID
+// were signed with JWT_SECRET. They are accepted while HS256 is configured, and after switching
+// to RS256 or EdDSA only until LegacySecretCutoff.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
 		// Validate the signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		
+		if SigningAlgorithm() != AlgHS256 && !time.Now().Before(LegacySecretCutoff()) {
+			return nil, errors.New("tokens signed with JWT_SECRET are no longer accepted")
+		}
+		
+		jwtSecret := getEnv("JWT_SECRET", "")
+		if jwtSecret == "" {
+			return nil, errors.New("JWT_SECRET environment variable must be set")
+		}
 		return []byte(jwtSecret), nil
-	})
+	}
 	
+	key, err := ring.verifier(kid)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Check if the token is valid
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	// The algorithm is bound to the key, never taken from the token alone
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
-	
-	return nil, errors.New("invalid token")
+	return key.publicKey, nil
 }
 
 // Helper function to get environment variables
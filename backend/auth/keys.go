@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signing algorithms selectable with JWT_SIGNING_ALG
+const (
+	AlgHS256 = "HS256" // Shared JWT_SECRET, kept for existing deployments
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	// rsaKeyBits is the size of generated RSA keys
+	rsaKeyBits = 2048
+
+	// keyRingRefreshInterval is how long loaded keys are used before reloading them,
+	// so keys rotated by another instance are picked up
+	keyRingRefreshInterval = time.Minute
+
+	// keyRingMissInterval limits reloads triggered by tokens with an unknown key ID
+	keyRingMissInterval = 10 * time.Second
+)
+
+var (
+	// ErrNoSigningKey is returned when no key is available to sign tokens
+	ErrNoSigningKey = errors.New("no signing key available")
+	// ErrRotationUnsupported is returned when rotating keys while the shared HS256 secret is used
+	ErrRotationUnsupported = errors.New("key rotation requires JWT_SIGNING_ALG RS256 or EdDSA")
+)
+
+// SigningKeyStore persists the signing keys shared by all instances
+type SigningKeyStore interface {
+	// GetSigningKeys returns the keys that are unretired or were retired after the given time, newest first
+	GetSigningKeys(retiredAfter time.Time) ([]model.SigningKey, error)
+	// RotateSigningKey stores a new key and retires all others
+	RotateSigningKey(key *model.SigningKey) error
+}
+
+// signingKeyStore is consulted to sign and verify tokens unless HS256 is used
+var signingKeyStore SigningKeyStore
+
+// SetSigningKeyStore registers the store of asymmetric signing keys
+func SetSigningKeyStore(s SigningKeyStore) {
+	signingKeyStore = s
+	ring.invalidate()
+}
+
+// SigningAlgorithm returns the algorithm used to sign new tokens, RS256 by default
+func SigningAlgorithm() string {
+	switch alg := getEnv("JWT_SIGNING_ALG", AlgRS256); alg {
+	case AlgHS256, AlgEdDSA:
+		return alg
+	default:
+		return AlgRS256
+	}
+}
+
+// KeyRetention returns how long a retired key keeps verifying tokens.
+// It covers the lifetime of every token the key may have signed.
+func KeyRetention() time.Duration {
+	return AccessTokenTTL() + MFAChallengeTTL
+}
+
+// parsedKey is a signing key with its decoded key material
+type parsedKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey
+	publicKey  crypto.PublicKey
+	retired    bool
+}
+
+// keyRing caches the parsed signing keys of the store
+type keyRing struct {
+	mu       sync.RWMutex
+	loadedAt time.Time
+	keys     []*parsedKey // Newest first
+}
+
+var ring keyRing
+
+// invalidate forces the next lookup to reload the keys
+func (r *keyRing) invalidate() {
+	r.mu.Lock()
+	r.loadedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+// get returns the loaded keys, reloading them when older than maxAge
+func (r *keyRing) get(maxAge time.Duration) ([]*parsedKey, error) {
+	r.mu.RLock()
+	keys, loadedAt := r.keys, r.loadedAt
+	r.mu.RUnlock()
+	if !loadedAt.IsZero() && time.Since(loadedAt) < maxAge {
+		return keys, nil
+	}
+
+	if signingKeyStore == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	stored, err := signingKeyStore.GetSigningKeys(time.Now().Add(-KeyRetention()))
+	if err != nil {
+		return nil, err
+	}
+
+	keys = make([]*parsedKey, 0, len(stored))
+	for i := range stored {
+		key, err := parseSigningKey(&stored[i])
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", stored[i].ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	r.mu.Lock()
+	r.keys, r.loadedAt = keys, time.Now()
+	r.mu.Unlock()
+	return keys, nil
+}
+
+// signer returns the newest unretired key of the configured algorithm
+func (r *keyRing) signer() (*parsedKey, error) {
+	keys, err := r.get(keyRingRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	alg := SigningAlgorithm()
+	for _, key := range keys {
+		if !key.retired && key.method.Alg() == alg {
+			return key, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// verifier returns the key with the given ID, reloading once if it is unknown
+func (r *keyRing) verifier(kid string) (*parsedKey, error) {
+	for _, maxAge := range []time.Duration{keyRingRefreshInterval, keyRingMissInterval} {
+		keys, err := r.get(maxAge)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if key.id == kid {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// parseSigningKey decodes the PEM encoded key pair of a stored key
+func parseSigningKey(key *model.SigningKey) (*parsedKey, error) {
+	parsed := &parsedKey{id: key.ID, retired: key.RetiredAt != nil}
+
+	var err error
+	switch key.Algorithm {
+	case AlgRS256:
+		parsed.method = jwt.SigningMethodRS256
+		if parsed.privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey)); err != nil {
+			return nil, err
+		}
+		parsed.publicKey, err = jwt.ParseRSAPublicKeyFromPEM([]byte(key.PublicKey))
+	case AlgEdDSA:
+		parsed.method = jwt.SigningMethodEdDSA
+		if parsed.privateKey, err = jwt.ParseEdPrivateKeyFromPEM([]byte(key.PrivateKey)); err != nil {
+			return nil, err
+		}
+		parsed.publicKey, err = jwt.ParseEdPublicKeyFromPEM([]byte(key.PublicKey))
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", key.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// GenerateSigningKey generates a new key pair for the given algorithm
+func GenerateSigningKey(alg string) (*model.SigningKey, error) {
+	var privateKey crypto.PrivateKey
+	var publicKey crypto.PublicKey
+
+	switch alg {
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, publicKey = key, &key.PublicKey
+	case AlgEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, publicKey = key, pub
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SigningKey{
+		ID:         kid,
+		Algorithm:  alg,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+	}, nil
+}
+
+// RotateSigningKeys generates a new key of the configured algorithm and makes it the signing key.
+// Previous keys are retired but keep verifying the tokens they signed, so nobody is logged out.
+func RotateSigningKeys() (*model.SigningKey, error) {
+	if signingKeyStore == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	alg := SigningAlgorithm()
+	if alg == AlgHS256 {
+		return nil, ErrRotationUnsupported
+	}
+
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := signingKeyStore.RotateSigningKey(key); err != nil {
+		return nil, err
+	}
+
+	ring.invalidate()
+	return key, nil
+}
+
+// EnsureSigningKey creates the first signing key, or a new one after the algorithm changed
+func EnsureSigningKey() error {
+	if SigningAlgorithm() == AlgHS256 {
+		return nil
+	}
+
+	_, err := ring.signer()
+	if errors.Is(err, ErrNoSigningKey) {
+		_, err = RotateSigningKeys()
+	}
+	return err
+}
+
+// JSONWebKey represents a public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA public exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JSONWebKeySet represents the published verification keys
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicKeySet returns every key that can currently verify tokens, including retired ones
+func PublicKeySet() (*JSONWebKeySet, error) {
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	if signingKeyStore == nil {
+		return set, nil
+	}
+
+	keys, err := ring.get(keyRingRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		jwk := JSONWebKey{KeyID: key.id, Use: "sig", Algorithm: key.method.Alg()}
+		switch pub := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
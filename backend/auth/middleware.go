@@ -7,46 +7,110 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// JWTMiddleware checks for a valid JWT token in the Authorization header
+// JWTMiddleware checks for a valid JWT token in the Authorization header.
+// Personal API keys are accepted as well, see authenticateAPIKey.
 func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		// Get the Authorization header
-		authHeader := c.Request().Header.Get("Authorization")
+		// Scripts and integrations authenticate with an API key instead of a token
+		if key, ok := apiKey(c); ok {
+			return authenticateAPIKey(c, key, next)
+		}
 		
-		// Check if the header is empty or doesn't start with "Bearer "
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		// Extract the token from the Authorization header
+		tokenString, ok := bearerToken(c)
+		if !ok {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Missing or invalid authorization token")
 		}
 		
-		// Extract the token from the header
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		
 		// Validate the token
 		claims, err := ValidateToken(tokenString)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
 		}
 		
+		// MFA challenge tokens are not access tokens
+		if claims.Purpose != "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+		}
+		
+		// Reject tokens whose session was revoked (logout, refresh token reuse, ...)
+		if claims.SessionID == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+		}
+		if sessionValidator != nil {
+			active, err := sessionValidator.TouchSession(claims.SessionID, c.RealIP())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify session")
+			}
+			if !active {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Session has been revoked")
+			}
+		}
+		
 		// Set user information in the context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		
+		// Impersonation tokens also carry the admin acting as the user
+		if claims.Actor != nil {
+			c.Set("impersonator_id", claims.Actor.UserID)
+			c.Set("impersonator_username", claims.Actor.Username)
+		}
 		
 		// Continue to the next handler
 		return next(c)
 	}
 }
 
-// AdminOnly middleware ensures that only users with admin role can access the route
-func AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// Get the role from the context (set by JWTMiddleware)
-		role, ok := c.Get("role").(string)
-		if !ok || role != "admin" {
-			return echo.NewHTTPError(http.StatusForbidden, "Admin access required")
+// MFASetupMiddleware accepts either a regular access token or an "mfa_setup_required"
+// challenge token, so users forced to enroll in MFA can reach the enrollment endpoints
+func MFASetupMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return challengeMiddleware(PurposeMFASetupRequired, "mfa_setup")(next)
+}
+
+// PasswordChangeMiddleware accepts either a regular access token or a "password_change_required"
+// challenge token, so users whose password expired can set a new one
+func PasswordChangeMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return challengeMiddleware(PurposePasswordChangeRequired, "password_expired")(next)
+}
+
+// challengeMiddleware accepts a challenge token of the given purpose in place of an access token
+// and sets the flag in the context, other tokens get the regular access token checks
+func challengeMiddleware(purpose, flag string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tokenString, ok := bearerToken(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing or invalid authorization token")
+			}
+			
+			// Fall back to the regular access token checks
+			claims, err := ValidateChallengeToken(tokenString, purpose)
+			if err != nil {
+				return JWTMiddleware(next)(c)
+			}
+			
+			// Set user information in the context, marking the pending challenge
+			c.Set("user_id", claims.UserID)
+			c.Set("username", claims.Username)
+			c.Set("role", claims.Role)
+			c.Set(flag, true)
+			
+			return next(c)
 		}
-		
-		// Continue to the next handler
-		return next(c)
 	}
-} 
\ No newline at end of file
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer ..." header
+func bearerToken(c echo.Context) (string, bool) {
+	authHeader := c.Request().Header.Get("Authorization")
+	
+	// Check if the header is empty or doesn't start with "Bearer "
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", false
+	}
+	
+	return strings.TrimPrefix(authHeader, "Bearer "), true
+}
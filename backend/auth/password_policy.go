@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes is the longest password bcrypt can hash
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords is the set of bundled common and breached passwords, loaded on first use
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// Password policy violations
+var (
+	// ErrPasswordTooLong is returned for passwords bcrypt cannot hash
+	ErrPasswordTooLong = fmt.Errorf("password must not be longer than %d bytes", maxPasswordBytes)
+	// ErrPasswordSimilar is returned for passwords containing the username or email address
+	ErrPasswordSimilar = errors.New("password must not contain the username or email address")
+	// ErrPasswordCommon is returned for passwords found in the bundled list of common and breached passwords
+	ErrPasswordCommon = errors.New("password is too common or has appeared in a data breach")
+)
+
+// PasswordPolicy describes the rules new passwords must follow
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`     // Minimum number of characters
+	MinClasses    int  `json:"min_classes"`    // Required character classes out of lowercase, uppercase, digits and symbols
+	CheckBreached bool `json:"check_breached"` // Reject passwords from the bundled common password list
+	HistorySize   int  `json:"history_size"`   // Recent passwords, including the current one, that cannot be reused
+	MaxAgeDays    int  `json:"max_age_days"`   // Days after which a password must be changed, 0 disables expiry
+}
+
+// LoadPasswordPolicy reads the password policy from the environment
+func LoadPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		MinClasses:    getEnvNonNegativeInt("PASSWORD_MIN_CHARACTER_CLASSES", 3),
+		CheckBreached: getEnv("PASSWORD_CHECK_BREACHED", "true") != "false",
+		HistorySize:   getEnvNonNegativeInt("PASSWORD_HISTORY_SIZE", 5),
+		MaxAgeDays:    getEnvNonNegativeInt("PASSWORD_MAX_AGE_DAYS", 0),
+	}
+	if policy.MinClasses > 4 {
+		policy.MinClasses = 4
+	}
+	return policy
+}
+
+// Validate checks a new password against the policy. The username and email of the account
+// are used to reject passwords derived from them.
+func (p PasswordPolicy) Validate(password, username, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return fmt.Errorf("password must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)
+	}
+
+	if isSimilar(password, username, email) {
+		return ErrPasswordSimilar
+	}
+
+	if p.CheckBreached && isCommonPassword(password) {
+		return ErrPasswordCommon
+	}
+
+	return nil
+}
+
+// Expired reports whether a password last changed at the given time has to be changed
+func (p PasswordPolicy) Expired(changedAt *time.Time, now time.Time) bool {
+	if p.MaxAgeDays <= 0 || changedAt == nil {
+		return false
+	}
+	return now.After(changedAt.AddDate(0, 0, p.MaxAgeDays))
+}
+
+// characterClasses counts the character classes used in a password
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// isSimilar reports whether a password contains the username, the email address or its
+// local part, forwards or backwards. Identifiers shorter than 3 characters are ignored.
+func isSimilar(password, username, email string) bool {
+	password = strings.ToLower(password)
+
+	identifiers := []string{strings.ToLower(username), strings.ToLower(email)}
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		identifiers = append(identifiers, strings.ToLower(email[:at]))
+	}
+
+	for _, id := range identifiers {
+		if utf8.RuneCountInString(id) < 3 {
+			continue
+		}
+		if strings.Contains(password, id) || strings.Contains(password, reverse(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommonPassword reports whether a password, or the password without the digits and symbols
+// commonly appended to a word ("Summer2024!"), is in the bundled list
+func isCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(loadCommonPasswords)
+
+	password = strings.ToLower(password)
+	if _, ok := commonPasswords[password]; ok {
+		return true
+	}
+
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if utf8.RuneCountInString(base) < 4 {
+		return false
+	}
+	_, ok := commonPasswords[base]
+	return ok
+}
+
+// loadCommonPasswords builds the common password set from the embedded list
+func loadCommonPasswords() {
+	commonPasswords = make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commonPasswords[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+// reverse returns s with its characters in reverse order
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// getEnvNonNegativeInt reads an integer environment variable where 0 is meaningful
+func getEnvNonNegativeInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PermissionResolver returns the permission names granted to a role
+type PermissionResolver interface {
+	GetRolePermissions(role string) ([]string, error)
+}
+
+// permissionResolver is consulted by RequirePermission and HasPermission
+var permissionResolver PermissionResolver
+
+// SetPermissionResolver registers the resolver used to look up role permissions
+func SetPermissionResolver(r PermissionResolver) {
+	permissionResolver = r
+}
+
+// RequirePermission middleware ensures the authenticated user's role grants every given permission.
+// It must run after JWTMiddleware.
+func RequirePermission(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, permission := range permissions {
+				if !HasPermission(c, permission) {
+					return echo.NewHTTPError(http.StatusForbidden, "Missing permission: "+permission)
+				}
+			}
+
+			// Continue to the next handler
+			return next(c)
+		}
+	}
+}
+
+// HasPermission reports whether the authenticated user's role grants a permission.
+// Requests made with an API key are further limited to the key's scopes.
+func HasPermission(c echo.Context, permission string) bool {
+	granted, ok := c.Get("permissions").(map[string]bool)
+	if !ok {
+		// Resolve the permissions once per request
+		role, ok := c.Get("role").(string)
+		if !ok || permissionResolver == nil {
+			return false
+		}
+
+		names, err := permissionResolver.GetRolePermissions(role)
+		if err != nil {
+			return false
+		}
+
+		granted = make(map[string]bool, len(names))
+		for _, name := range names {
+			granted[name] = true
+		}
+		if scopes, ok := c.Get("api_key_scopes").([]string); ok {
+			granted = restrictToScopes(granted, scopes)
+		}
+		c.Set("permissions", granted)
+	}
+
+	return granted[permission]
+}
+
+// restrictToScopes keeps only the granted permissions that are also listed in scopes
+func restrictToScopes(granted map[string]bool, scopes []string) map[string]bool {
+	restricted := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if granted[scope] {
+			restricted[scope] = true
+		}
+	}
+	return restricted
+}
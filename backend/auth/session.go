@@ -0,0 +1,15 @@
+package auth
+
+// SessionValidator reports whether a session referenced by an access token is still active
+// and records the activity of the session
+type SessionValidator interface {
+	TouchSession(sessionID string, ip string) (bool, error)
+}
+
+// sessionValidator is consulted by JWTMiddleware on every authenticated request
+var sessionValidator SessionValidator
+
+// SetSessionValidator registers the validator used to reject tokens of revoked sessions
+func SetSessionValidator(v SessionValidator) {
+	sessionValidator = v
+}
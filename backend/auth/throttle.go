@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+)
+
+// LoginThrottlePolicy describes how failed login attempts are slowed down and locked out
+type LoginThrottlePolicy struct {
+	MaxUsernameFailures int           // Failures before a username is locked
+	MaxIPFailures       int           // Failures before a client IP is locked
+	MaxMFAFailures      int           // Failed second-factor codes before a user's challenge tokens are invalidated
+	LockoutDuration     time.Duration // How long a lock lasts
+	FailureWindow       time.Duration // Failures older than this are forgotten
+	BaseDelay           time.Duration // Delay after the first failure, doubled for each further one
+	MaxDelay            time.Duration // Upper bound of the backoff delay
+}
+
+// LoadLoginThrottlePolicy reads the throttle policy from the environment
+func LoadLoginThrottlePolicy() LoginThrottlePolicy {
+	lockout := time.Duration(getEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+	return LoginThrottlePolicy{
+		MaxUsernameFailures: getEnvInt("LOGIN_MAX_FAILURES", 5),
+		MaxIPFailures:       getEnvInt("LOGIN_MAX_IP_FAILURES", 20),
+		MaxMFAFailures:      getEnvInt("MFA_MAX_FAILURES", 5),
+		LockoutDuration:     lockout,
+		FailureWindow:       lockout,
+		BaseDelay:           time.Second,
+		MaxDelay:            time.Minute,
+	}
+}
+
+// RetryAfter returns how long a subject has to wait before the next login attempt is accepted
+func (p LoginThrottlePolicy) RetryAfter(t *model.LoginThrottle, now time.Time) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	// Locked subjects wait for the lock to expire
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+
+	if t.Failures == 0 || now.Sub(t.LastFailureAt) > p.FailureWindow {
+		return 0
+	}
+
+	// Exponential backoff: BaseDelay, 2*BaseDelay, 4*BaseDelay, ...
+	delay := p.BaseDelay
+	for i := 1; i < t.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	next := t.LastFailureAt.Add(delay)
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// getEnvInt reads a positive integer environment variable
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
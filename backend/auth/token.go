@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// GenerateRandomToken returns a URL-safe random string built from n random bytes
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateSessionID returns a new random session identifier
+func GenerateSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of an opaque token.
+// Opaque tokens (refresh tokens, reset tokens, ...) are only stored hashed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PasswordResetTTL returns the lifetime of password reset tokens, 30 minutes by default
+func PasswordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRATION_MINUTES", "30"))
+	if err != nil || minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// EmailVerificationTTL returns the lifetime of email verification tokens, 24 hours by default
+func EmailVerificationTTL() time.Duration {
+	hours, err := strconv.Atoi(getEnv("EMAIL_VERIFICATION_EXPIRATION_HOURS", "24"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// InviteTTL returns the lifetime of registration invites, 7 days by default
+func InviteTTL() time.Duration {
+	hours, err := strconv.Atoi(getEnv("INVITE_EXPIRATION_HOURS", "168"))
+	if err != nil || hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
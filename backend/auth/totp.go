@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTP parameters as recommended by RFC 6238 and understood by common authenticator apps
+	totpPeriod = 30
+	totpDigits = 6
+	// Number of periods before and after the current one that are still accepted (clock drift)
+	totpSkew = 1
+	// Issuer shown in authenticator apps
+	totpIssuer = "Worksite Management Studio"
+)
+
+// recoveryCodeAlphabet avoids characters that are easily confused (0/O, 1/I/L)
+const recoveryCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// GenerateTOTPSecret returns a new random base32 encoded TOTP secret (160 bits)
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI that authenticator apps read from a QR code
+func TOTPProvisioningURI(secret, accountName string) string {
+	label := url.PathEscape(totpIssuer + ":" + accountName)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// ValidateTOTP checks a TOTP code against the secret at the given time.
+// It returns the time step that matched so callers can reject replays of the same code.
+func ValidateTOTP(secret, code string, at time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := at.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step := current + offset
+		expected := totpCode(key, step)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the HOTP value (RFC 4226) for a counter
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// GenerateRecoveryCodes returns n random one-time recovery codes formatted as XXXXX-XXXXX
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		chars := make([]byte, len(b))
+		for j, v := range b {
+			chars[j] = recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)]
+		}
+		codes = append(codes, string(chars[:5])+"-"+string(chars[5:]))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode normalizes a recovery code and returns its hash
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return HashToken(normalized)
+}
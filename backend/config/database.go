@@ -9,6 +9,7 @@ import (
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -64,15 +65,48 @@ func InitDB() {
 	sqlDB.SetMaxOpenConns(100)       // Maximum number of open connections
 	sqlDB.SetConnMaxLifetime(1 * time.Hour) // Maximum connection lifetime
 
+	// Users created before email verification existed are treated as verified
+	backfillEmailVerified := !db.Migrator().HasColumn(&model.User{}, "email_verified")
+
 	// Auto Migrate the schema with optimized indices
-	err = db.AutoMigrate(&model.Worker{}, &model.Project{}, &model.User{}, &model.WorkerProject{}, &model.ActivityLog{})
+	err = db.AutoMigrate(&model.Worker{}, &model.Project{}, &model.User{}, &model.WorkerProject{}, &model.ActivityLog{},
+		&model.Session{}, &model.RefreshToken{}, &model.PasswordResetToken{},
+		&model.MFARecoveryCode{}, &model.Setting{}, &model.LoginThrottle{},
+		&model.Permission{}, &model.Role{},
+		&model.Organization{}, &model.OrganizationMember{}, &model.OrganizationInvitation{},
+		&model.APIKey{}, &model.SigningKey{},
+		&model.OIDCLoginState{}, &model.UserIdentity{},
+		&model.EmailVerificationToken{}, &model.RegistrationInvite{},
+		&model.PasswordHistory{},
+		&model.Skill{}, &model.WorkerSkill{},
+		&model.StaffingRequirement{}, &model.TimesheetEntry{}, &model.AttendancePunch{},
+		&model.PayRate{}, &model.PayrollRun{}, &model.PayrollLine{}, &model.WorkerChange{},
+		&model.LeaveRequest{}, &model.LeaveAllowance{}, &model.ShiftTemplate{}, &model.Shift{}, &model.ShiftWorker{},
+		&model.Crew{}, &model.CrewMember{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	
+	if backfillEmailVerified {
+		if err := db.Exec("UPDATE users SET email_verified = true").Error; err != nil {
+			log.Fatal("Failed to mark existing users as verified:", err)
+		}
+	}
+
+	// Password age is counted from account creation for users without a recorded change
+	if err := db.Exec("UPDATE users SET password_changed_at = created_at WHERE password_changed_at IS NULL").Error; err != nil {
+		log.Fatal("Failed to backfill password change times:", err)
+	}
 
 	// Create indexes for frequently queried fields
 	createIndexes(db)
 
+	// Seed the permission catalog and built-in roles
+	seedRoles(db)
+
+	// Move per-user data into personal organizations
+	migrateToOrganizations(db)
+
 	DB = db
 }
 
@@ -104,6 +138,11 @@ func createIndexes(db *gorm.DB) {
 	// Add index for user_id in worker_projects table
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_worker_projects_user_id ON worker_projects(user_id)")
 	
+	// Add indexes for organization scoping
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_workers_organization_id ON workers(organization_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_projects_organization_id ON projects(organization_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_worker_projects_organization_id ON worker_projects(organization_id)")
+	
 	// Add indexes for ActivityLog table
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_activity_logs_user_id ON activity_logs(user_id)")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_activity_logs_log_type ON activity_logs(log_type)")
@@ -111,9 +150,118 @@ func createIndexes(db *gorm.DB) {
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_activity_logs_entity_id ON activity_logs(entity_id)")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_activity_logs_created_at ON activity_logs(created_at)")
 	
+	// Add indexes for Session and RefreshToken tables
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id)")
+	
 	log.Println("Database indexes created successfully")
 }
 
+// seedRoles stores the permission catalog and creates missing built-in roles.
+// Existing roles keep their permissions, except admin which always receives every permission
+// and built-in roles which receive newly added permissions they hold by default.
+func seedRoles(db *gorm.DB) {
+	added := make(map[string]bool)
+	for _, perm := range model.AllPermissions {
+		perm := perm
+		var known int64
+		if err := db.Model(&model.Permission{}).Where("name = ?", perm.Name).Count(&known).Error; err != nil {
+			log.Fatal("Failed to seed permissions:", err)
+		}
+		added[perm.Name] = known == 0
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(&perm).Error; err != nil {
+			log.Fatal("Failed to seed permissions:", err)
+		}
+	}
+
+	for _, defaultRole := range model.DefaultRoles {
+		var role model.Role
+		err := db.Where("name = ?", defaultRole.Name).First(&role).Error
+		if err != nil {
+			role = defaultRole
+			role.BuiltIn = true
+			if err := db.Create(&role).Error; err != nil {
+				log.Fatal("Failed to seed roles:", err)
+			}
+		} else if role.BuiltIn {
+			var grants []model.Permission
+			for _, perm := range defaultRole.Permissions {
+				if added[perm.Name] {
+					grants = append(grants, perm)
+				}
+			}
+			if len(grants) > 0 {
+				if err := db.Model(&role).Association("Permissions").Append(grants); err != nil {
+					log.Fatal("Failed to seed role permissions:", err)
+				}
+			}
+		}
+
+		if role.Name == model.RoleAdmin {
+			if err := db.Model(&role).Association("Permissions").Replace(model.AllPermissions); err != nil {
+				log.Fatal("Failed to seed admin permissions:", err)
+			}
+		}
+	}
+
+	log.Println("Roles and permissions seeded successfully")
+}
+
+// migrateToOrganizations creates a personal organization for every user that has none
+// and moves workers, projects and assignments without an organization into it
+func migrateToOrganizations(db *gorm.DB) {
+	var users []model.User
+	err := db.Where("id NOT IN (?)",
+		db.Model(&model.Organization{}).Select("owner_id").Where("personal = ?", true)).
+		Find(&users).Error
+	if err != nil {
+		log.Fatal("Failed to migrate organizations:", err)
+	}
+
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			org := &model.Organization{
+				Name:     fmt.Sprintf("%s's organization", user.Username),
+				OwnerID:  user.ID,
+				Personal: true,
+			}
+			if err := tx.Create(org).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&model.OrganizationMember{
+				OrganizationID: org.ID,
+				UserID:         user.ID,
+				Role:           model.OrgRoleOwner,
+			}).Error; err != nil {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal("Failed to create personal organization:", err)
+		}
+	}
+
+	// Assign data that predates organizations to its creator's personal organization
+	personalOrg := "(SELECT id FROM organizations WHERE owner_id = %s.user_id AND personal = true AND deleted_at IS NULL LIMIT 1)"
+	for _, table := range []string{"workers", "projects", "worker_projects"} {
+		err := db.Exec(fmt.Sprintf("UPDATE %s SET organization_id = "+personalOrg+
+			" WHERE organization_id IS NULL OR organization_id = 0", table, table)).Error
+		if err != nil {
+			log.Fatal("Failed to migrate "+table+" to organizations:", err)
+		}
+	}
+
+	if len(users) > 0 {
+		log.Printf("Created personal organizations for %d users", len(users))
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type AdminController interface {
@@ -13,17 +20,40 @@ type AdminController interface {
 	UpdateUserStatus(c echo.Context) error
 	UpdateUserRole(c echo.Context) error
 	GetUserActivity(c echo.Context) error
+	ResetUserMFA(c echo.Context) error
+	ImpersonateUser(c echo.Context) error
+	GetMFASettings(c echo.Context) error
+	UpdateMFASettings(c echo.Context) error
+	GetLockouts(c echo.Context) error
+	Unlock(c echo.Context) error
+	GetPermissions(c echo.Context) error
+	GetRoles(c echo.Context) error
+	CreateRole(c echo.Context) error
+	UpdateRole(c echo.Context) error
+	DeleteRole(c echo.Context) error
 }
 
 type adminController struct {
-	userRepo repository.UserRepository
-	logRepo  *repository.LogRepository
+	userRepo     repository.UserRepository
+	logRepo      *repository.LogRepository
+	mfaRepo      *repository.MFARepository
+	sessionRepo  *repository.SessionRepository
+	settingRepo  *repository.SettingRepository
+	throttleRepo *repository.LoginThrottleRepository
+	roleRepo     *repository.RoleRepository
+	validate     *validator.Validate
 }
 
-func NewAdminController(userRepo repository.UserRepository, logRepo *repository.LogRepository) AdminController {
+func NewAdminController(userRepo repository.UserRepository, logRepo *repository.LogRepository, mfaRepo *repository.MFARepository, sessionRepo *repository.SessionRepository, settingRepo *repository.SettingRepository, throttleRepo *repository.LoginThrottleRepository, roleRepo *repository.RoleRepository) AdminController {
 	return &adminController{
-		userRepo: userRepo,
-		logRepo:  logRepo,
+		userRepo:     userRepo,
+		logRepo:      logRepo,
+		mfaRepo:      mfaRepo,
+		sessionRepo:  sessionRepo,
+		settingRepo:  settingRepo,
+		throttleRepo: throttleRepo,
+		roleRepo:     roleRepo,
+		validate:     validator.New(),
 	}
 }
 
@@ -80,6 +110,13 @@ func (c *adminController) UpdateUserStatus(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user status")
 	}
 	
+	// Deactivated users are signed out everywhere right away
+	if !req.Active {
+		if err := c.sessionRepo.RevokeUserSessions(uint(userID)); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+		}
+	}
+	
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
 		"message": "User status updated successfully",
 	})
@@ -95,23 +132,26 @@ func (c *adminController) UpdateUserRole(ctx echo.Context) error {
 	
 	// Parse request body
 	var req struct {
-		Role string `json:"role" validate:"required,oneof=user admin"`
+		Role string `json:"role" validate:"required"`
 	}
 	
 	if err := ctx.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 	
-	// Check if role is valid
-	if req.Role != "user" && req.Role != "admin" {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role. Must be 'user' or 'admin'")
-	}
-	
 	// Update user role
 	if err := c.userRepo.UpdateUserRole(uint(userID), req.Role); err != nil {
+		if errors.Is(err, repository.ErrInvalidRole) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user role")
 	}
 	
+	// The role is embedded in access tokens, so sign the user out everywhere
+	if err := c.sessionRepo.RevokeUserSessions(uint(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
 		"message": "User role updated successfully",
 	})
@@ -163,4 +203,343 @@ func (c *adminController) GetUserActivity(ctx echo.Context) error {
 		"page":     page,
 		"pageSize": pageSize,
 	})
-} 
\ No newline at end of file
+}
+
+// ResetUserMFA removes a user's second factor so they can enroll again, e.g. after losing their device
+func (c *adminController) ResetUserMFA(ctx echo.Context) error {
+	// Get user ID from path parameter
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	
+	if _, err := c.userRepo.GetUserByID(uint(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	
+	if err := c.mfaRepo.Disable(uint(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset MFA")
+	}
+	
+	// Sessions established with the old factor must not survive the reset
+	if err := c.sessionRepo.RevokeUserSessions(uint(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "User MFA reset successfully",
+	})
+}
+
+// ImpersonationResponse contains a short-lived access token for acting as another user.
+// There is no refresh token, the impersonation ends when the token expires or on logout.
+type ImpersonationResponse struct {
+	Token        string      `json:"token"`
+	ExpiresIn    int         `json:"expires_in"` // Access token lifetime in seconds
+	User         *model.User `json:"user"`
+	Impersonator *model.User `json:"impersonator"`
+}
+
+// ImpersonateUser issues a token that lets an admin see the application exactly as a user does
+func (c *adminController) ImpersonateUser(ctx echo.Context) error {
+	adminID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+	
+	// Get user ID from path parameter
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	
+	if auth.IsAPIKeyRequest(ctx) {
+		return echo.NewHTTPError(http.StatusForbidden, "Users cannot be impersonated with an API key")
+	}
+	
+	if uint(userID) == adminID {
+		return echo.NewHTTPError(http.StatusBadRequest, "You cannot impersonate yourself")
+	}
+	
+	admin, err := c.userRepo.GetUserByID(adminID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User not authenticated")
+	}
+	
+	user, err := c.userRepo.GetUserByID(uint(userID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	
+	if !user.Active {
+		return echo.NewHTTPError(http.StatusBadRequest, "Inactive users cannot be impersonated")
+	}
+	
+	if user.Role == model.RoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "Admins cannot be impersonated")
+	}
+	
+	// Impersonating must not grant permissions the impersonator lacks
+	allowed, err := grantsSubset(c.roleRepo, user.Role, admin.Role)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve role permissions")
+	}
+	if !allowed {
+		return echo.NewHTTPError(http.StatusForbidden, "Users with permissions you do not have cannot be impersonated")
+	}
+	
+	sessionID, err := auth.GenerateSessionID()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+	
+	// The session belongs to the user so it shows up in their session list
+	now := time.Now()
+	session := &model.Session{
+		ID:             sessionID,
+		UserID:         user.ID,
+		ImpersonatorID: &admin.ID,
+		IPAddress:      ctx.RealIP(),
+		UserAgent:      truncate(ctx.Request().UserAgent(), 255),
+		LastSeenAt:     &now,
+		ExpiresAt:      now.Add(auth.ImpersonationTTL()),
+	}
+	if err := c.sessionRepo.CreateImpersonationSession(session); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+	
+	token, err := auth.GenerateImpersonationToken(user, admin, sessionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+	
+	// Clear sensitive data
+	user.PasswordHash = ""
+	admin.PasswordHash = ""
+	
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":     admin.ID,
+		"username":    admin.Username,
+		"entity_id":   user.ID,
+		"description": fmt.Sprintf("User %s started impersonating %s", admin.Username, user.Username),
+	})
+	
+	return ctx.JSON(http.StatusOK, ImpersonationResponse{
+		Token:        token,
+		ExpiresIn:    int(auth.ImpersonationTTL().Seconds()),
+		User:         user,
+		Impersonator: admin,
+	})
+}
+
+// grantsSubset reports whether every permission of role is also granted to other
+func grantsSubset(roleRepo *repository.RoleRepository, role, other string) (bool, error) {
+	permissions, err := roleRepo.GetRolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+	otherPermissions, err := roleRepo.GetRolePermissions(other)
+	if err != nil {
+		return false, err
+	}
+	
+	granted := make(map[string]bool, len(otherPermissions))
+	for _, permission := range otherPermissions {
+		granted[permission] = true
+	}
+	for _, permission := range permissions {
+		if !granted[permission] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// GetMFASettings returns the system-wide MFA settings
+func (c *adminController) GetMFASettings(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"require_admin_mfa": c.settingRepo.GetBool(model.SettingRequireAdminMFA, false),
+	})
+}
+
+// UpdateMFASettings enables or disables mandatory MFA for the admin role
+func (c *adminController) UpdateMFASettings(ctx echo.Context) error {
+	// Parse request body
+	var req struct {
+		RequireAdminMFA bool `json:"require_admin_mfa"`
+	}
+	
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if err := c.settingRepo.Set(model.SettingRequireAdminMFA, strconv.FormatBool(req.RequireAdminMFA)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update MFA settings")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message":           "MFA settings updated successfully",
+		"require_admin_mfa": req.RequireAdminMFA,
+	})
+}
+
+// GetLockouts returns the usernames and client IPs that are currently locked after failed logins
+func (c *adminController) GetLockouts(ctx echo.Context) error {
+	lockouts, err := c.throttleRepo.GetLocked()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch lockouts")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":  lockouts,
+		"total": len(lockouts),
+	})
+}
+
+// Unlock removes a login lock and its failure counter
+func (c *adminController) Unlock(ctx echo.Context) error {
+	// Get lockout ID from path parameter
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid lockout ID")
+	}
+	
+	if err := c.throttleRepo.Unlock(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Lockout not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Unlocked successfully",
+	})
+}
+
+// RoleRequest represents the role create/update request body
+type RoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// GetPermissions returns the permission catalog
+func (c *adminController) GetPermissions(ctx echo.Context) error {
+	permissions, err := c.roleRepo.GetAllPermissions()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch permissions")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": permissions,
+	})
+}
+
+// GetRoles returns all roles with their permissions
+func (c *adminController) GetRoles(ctx echo.Context) error {
+	roles, err := c.roleRepo.GetAll()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch roles")
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": roles,
+	})
+}
+
+// CreateRole creates a custom role bundling the given permissions
+func (c *adminController) CreateRole(ctx echo.Context) error {
+	var req RoleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	
+	exists, err := c.roleRepo.Exists(req.Name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create role")
+	}
+	if exists {
+		return echo.NewHTTPError(http.StatusConflict, "Role already exists")
+	}
+	
+	role := &model.Role{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := c.roleRepo.Create(role, req.Permissions); err != nil {
+		if errors.Is(err, repository.ErrUnknownPermission) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown permission")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create role")
+	}
+	
+	return ctx.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes the description and permissions of a role
+func (c *adminController) UpdateRole(ctx echo.Context) error {
+	// Get role ID from path parameter
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role ID")
+	}
+	
+	var req RoleRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	role, err := c.roleRepo.GetByID(uint(id))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+	}
+	
+	// Users reference roles by name, so the name is immutable
+	if req.Name != "" && req.Name != role.Name {
+		return echo.NewHTTPError(http.StatusBadRequest, "Role name cannot be changed")
+	}
+	
+	role.Description = req.Description
+	if err := c.roleRepo.Update(role, req.Permissions); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoleBuiltIn):
+			return echo.NewHTTPError(http.StatusForbidden, "The admin role cannot be changed")
+		case errors.Is(err, repository.ErrUnknownPermission):
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown permission")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update role")
+	}
+	
+	return ctx.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role that is no longer assigned to users
+func (c *adminController) DeleteRole(ctx echo.Context) error {
+	// Get role ID from path parameter
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role ID")
+	}
+	
+	role, err := c.roleRepo.GetByID(uint(id))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+	}
+	
+	if err := c.roleRepo.Delete(role); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoleBuiltIn):
+			return echo.NewHTTPError(http.StatusForbidden, "Built-in roles cannot be deleted")
+		case errors.Is(err, repository.ErrRoleInUse):
+			return echo.NewHTTPError(http.StatusConflict, "Role is still assigned to users")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete role")
+	}
+	
+	return ctx.NoContent(http.StatusNoContent)
+}
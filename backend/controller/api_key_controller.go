@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefixLength is the number of leading secret characters kept to identify a key
+const apiKeyPrefixLength = 12
+
+type APIKeyController interface {
+	GetAPIKeys(c echo.Context) error
+	CreateAPIKey(c echo.Context) error
+	RevokeAPIKey(c echo.Context) error
+}
+
+type apiKeyController struct {
+	apiKeyRepo *repository.APIKeyRepository
+	validate   *validator.Validate
+}
+
+func NewAPIKeyController(apiKeyRepo *repository.APIKeyRepository) APIKeyController {
+	return &apiKeyController{
+		apiKeyRepo: apiKeyRepo,
+		validate:   validator.New(),
+	}
+}
+
+// CreateAPIKeyRequest represents the API key creation request body
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"` // Permission names, each must be granted by the user's role
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse returns the new key together with its secret, which is only shown once
+type CreateAPIKeyResponse struct {
+	*model.APIKey
+	Key string `json:"key"`
+}
+
+// GetAPIKeys handles GET /api/auth/api-keys
+func (c *apiKeyController) GetAPIKeys(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := c.apiKeyRepo.GetUserKeys(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch API keys")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": keys,
+	})
+}
+
+// CreateAPIKey handles POST /api/auth/api-keys
+func (c *apiKeyController) CreateAPIKey(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Keys can only be created from an interactive session
+	if auth.IsAPIKeyRequest(ctx) {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys cannot create API keys")
+	}
+
+	var req CreateAPIKeyRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	// A key can never grant more than the user's role
+	for _, scope := range req.Scopes {
+		if !auth.HasPermission(ctx, scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Scope not granted by your role: "+scope)
+		}
+	}
+
+	secret, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key")
+	}
+	secret = model.APIKeyPrefix + secret
+
+	key := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    secret[:apiKeyPrefixLength],
+		KeyHash:   auth.HashToken(secret),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := c.validate.Struct(key); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "A name and at least one scope are required")
+	}
+
+	if err := c.apiKeyRepo.Create(key); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create API key")
+	}
+
+	// Store auth info in context for logging middleware
+	username, _ := ctx.Get("username").(string)
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  userID,
+		"username": username,
+	})
+
+	return ctx.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKey: key,
+		Key:    secret,
+	})
+}
+
+// RevokeAPIKey handles DELETE /api/auth/api-keys/:id
+func (c *apiKeyController) RevokeAPIKey(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	if err := c.apiKeyRepo.Revoke(uint(id), userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key")
+	}
+
+	// Store auth info in context for logging middleware
+	username, _ := ctx.Get("username").(string)
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  userID,
+		"username": username,
+	})
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPunchClockSkew is how far in the future a punch timestamp may be, to allow for client clock drift
+	maxPunchClockSkew = 5 * time.Minute
+	// defaultRegularHours is the number of hours a day counted as regular time when converting punches
+	defaultRegularHours = 8
+	// maxAttendanceRangeDays limits the date range of attendance reports and conversions
+	maxAttendanceRangeDays = 92
+)
+
+type AttendanceController struct {
+	repo     *repository.AttendanceRepository
+	validate *validator.Validate
+}
+
+func NewAttendanceController(repo *repository.AttendanceRepository) *AttendanceController {
+	return &AttendanceController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// PunchRequest is a clock-in or clock-out posted by a client. Without a project ID the punch goes to
+// the nearest site the worker is assigned to.
+type PunchRequest struct {
+	WorkerID  uint      `json:"worker_id" validate:"required"`
+	ProjectID uint      `json:"project_id"`
+	Type      string    `json:"type" validate:"required,oneof=in out"`
+	Timestamp time.Time `json:"timestamp" validate:"required"`
+	Latitude  float64   `json:"latitude" validate:"required,latitude"`
+	Longitude float64   `json:"longitude" validate:"required,longitude"`
+}
+
+// ConvertAttendanceRequest turns the punches of a date range into timesheet hours
+type ConvertAttendanceRequest struct {
+	ProjectID    uint      `json:"project_id"` // All sites when empty
+	From         time.Time `json:"from" validate:"required"`
+	Until        time.Time `json:"until" validate:"required,gtefield=From"`
+	RegularHours float64   `json:"regular_hours" validate:"omitempty,min=1,max=24"` // Hours a day before overtime, 8 by default
+}
+
+// RecordPunch handles POST /api/attendance/punches
+func (c *AttendanceController) RecordPunch(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req PunchRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if req.Timestamp.After(time.Now().Add(maxPunchClockSkew)) {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Timestamp must not be in the future"})
+	}
+
+	punch := &model.AttendancePunch{
+		WorkerID:  req.WorkerID,
+		ProjectID: req.ProjectID,
+		Type:      req.Type,
+		Timestamp: req.Timestamp,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+	}
+	if err := c.repo.RecordPunch(punch, orgID, userID); err != nil {
+		return attendanceError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, punch)
+}
+
+// GetPunches handles GET /api/attendance/punches?worker_id=1&project_id=2&status=flagged&from=2024-03-04&until=2024-03-10
+func (c *AttendanceController) GetPunches(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	filters := make(map[string]interface{})
+	for _, key := range []string{"worker_id", "project_id"} {
+		if value := ctx.QueryParam(key); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + key})
+			}
+			filters[key] = uint(id)
+		}
+	}
+	if status := ctx.QueryParam("status"); status != "" {
+		filters["status"] = status
+	}
+	for _, key := range []string{"from", "until"} {
+		if value := ctx.QueryParam(key); value != "" {
+			date, err := parseDate(value)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+			}
+			filters[key] = date
+		}
+	}
+
+	// Handle pagination
+	page := 1
+	pageSize := 50
+
+	if pageParam := ctx.QueryParam("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if pageSizeParam := ctx.QueryParam("page_size"); pageSizeParam != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 {
+			pageSize = parsedPageSize
+		}
+	}
+
+	punches, total, err := c.repo.GetPunches(orgID, filters, page, pageSize)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	redactPunches(ctx, punches)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":     punches,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// GetRoster handles GET /api/projects/:id/attendance?date=2024-03-04 (today by default)
+func (c *AttendanceController) GetRoster(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	now := time.Now().UTC()
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if dateParam := ctx.QueryParam("date"); dateParam != "" {
+		date, err = parseDate(dateParam)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+		}
+	}
+
+	roster, err := c.repo.GetRoster(uint(projectId), orgID, date)
+	if err != nil {
+		return attendanceError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": roster,
+		"date": date.Format(dateLayout),
+	})
+}
+
+// GetMissingClockOuts handles GET /api/attendance/missing-clock-outs?from=2024-03-01&until=2024-03-07&project_id=2.
+// The range defaults to the last seven days before today.
+func (c *AttendanceController) GetMissingClockOuts(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	until := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	if untilParam := ctx.QueryParam("until"); untilParam != "" {
+		until, err = parseDate(untilParam)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid until date, expected YYYY-MM-DD"})
+		}
+	}
+	from := until.AddDate(0, 0, -6)
+	if fromParam := ctx.QueryParam("from"); fromParam != "" {
+		from, err = parseDate(fromParam)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from date, expected YYYY-MM-DD"})
+		}
+	}
+	if err := checkAttendanceRange(from, until); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var projectID uint
+	if projectParam := ctx.QueryParam("project_id"); projectParam != "" {
+		id, err := strconv.ParseUint(projectParam, 10, 32)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project_id"})
+		}
+		projectID = uint(id)
+	}
+
+	missing, err := c.repo.GetMissingClockOuts(orgID, projectID, from, until)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":  missing,
+		"from":  from.Format(dateLayout),
+		"until": until.Format(dateLayout),
+	})
+}
+
+// ConvertPunches handles POST /api/attendance/convert. The accepted punches of each worker, site and
+// day become draft timesheet entries, replacing existing draft or rejected entries.
+func (c *AttendanceController) ConvertPunches(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req ConvertAttendanceRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := checkAttendanceRange(req.From, req.Until); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	regularHours := req.RegularHours
+	if regularHours == 0 {
+		regularHours = defaultRegularHours
+	}
+
+	result, err := c.repo.ConvertToTimesheets(orgID, userID, req.ProjectID, req.From, req.Until, regularHours)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// checkAttendanceRange verifies that a report or conversion covers at most maxAttendanceRangeDays days
+func checkAttendanceRange(from time.Time, until time.Time) error {
+	if until.Before(from) {
+		return errors.New("the until date must not be before the from date")
+	}
+	if until.Sub(from) >= maxAttendanceRangeDays*24*time.Hour {
+		return errors.New("the date range must not exceed 92 days")
+	}
+	return nil
+}
+
+// redactPunches zeroes the salaries of the punches' workers for users without the workers:salary:read permission
+func redactPunches(ctx echo.Context, punches []model.AttendancePunch) {
+	if canReadSalary(ctx) {
+		return
+	}
+	for i := range punches {
+		redactWorker(punches[i].Worker)
+	}
+}
+
+// attendanceError maps attendance repository errors to responses
+func attendanceError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNoSite):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Worker is not assigned to any site on that date, a project ID is required"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker or project not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
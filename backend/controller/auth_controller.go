@@ -1,27 +1,60 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/notify"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthController interface {
 	Login(c echo.Context) error
 	Register(c echo.Context) error
+	Refresh(c echo.Context) error
+	Logout(c echo.Context) error
+	ChangePassword(c echo.Context) error
+	RequestPasswordReset(c echo.Context) error
+	ConfirmPasswordReset(c echo.Context) error
+	GetPasswordPolicy(c echo.Context) error
+	VerifyEmail(c echo.Context) error
+	ResendVerification(c echo.Context) error
 }
 
 type authController struct {
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	sessionRepo      *repository.SessionRepository
+	resetRepo        *repository.PasswordResetRepository
+	settingRepo      *repository.SettingRepository
+	throttleRepo     *repository.LoginThrottleRepository
+	registrationRepo *repository.RegistrationRepository
+	throttle         auth.LoginThrottlePolicy
+	passwordPolicy   auth.PasswordPolicy
+	notifier         notify.Notifier
+	validate         *validator.Validate
 }
 
-func NewAuthController(userRepo repository.UserRepository) AuthController {
+func NewAuthController(userRepo repository.UserRepository, sessionRepo *repository.SessionRepository, resetRepo *repository.PasswordResetRepository, settingRepo *repository.SettingRepository, throttleRepo *repository.LoginThrottleRepository, registrationRepo *repository.RegistrationRepository, notifier notify.Notifier) AuthController {
 	return &authController{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		resetRepo:        resetRepo,
+		settingRepo:      settingRepo,
+		throttleRepo:     throttleRepo,
+		registrationRepo: registrationRepo,
+		throttle:         auth.LoadLoginThrottlePolicy(),
+		passwordPolicy:   auth.LoadPasswordPolicy(),
+		notifier:         notifier,
+		validate:         validator.New(),
 	}
 }
 
@@ -33,16 +66,61 @@ type LoginRequest struct {
 
 // LoginResponse represents the login response body
 type LoginResponse struct {
-	Token    string      `json:"token"`
-	User     *model.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int         `json:"expires_in"` // Access token lifetime in seconds
+	User         *model.User `json:"user"`
 }
 
-// RegisterRequest represents the registration request body
+// MFAChallengeResponse is returned by Login instead of a token pair when a second factor is needed
+type MFAChallengeResponse struct {
+	Status    string `json:"status"` // "mfa_required" or "mfa_setup_required"
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// PasswordExpiredResponse is returned by Login instead of a token pair when the password expired.
+// The token is accepted by PUT /api/auth/password, after which the user logs in again.
+type PasswordExpiredResponse struct {
+	Status        string `json:"status"` // "password_change_required"
+	PasswordToken string `json:"password_token"`
+	ExpiresIn     int    `json:"expires_in"`
+}
+
+// RefreshRequest represents the token refresh request body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ChangePasswordRequest represents the password change request body
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"` // Checked against the password policy
+}
+
+// PasswordResetRequest represents the password reset request body
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetConfirmRequest represents the password reset confirmation body
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"` // Checked against the password policy
+}
+
+// RegisterRequest represents the registration request body.
+// There is deliberately no role: self-registered users never choose their role.
 type RegisterRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=50"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
-	Role     string `json:"role" validate:"omitempty,oneof=user admin"`
+	Username    string `json:"username" validate:"required,min=3,max=50"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required"` // Checked against the password policy
+	InviteToken string `json:"invite_token"` // Required in invite-only mode
+}
+
+// VerifyEmailRequest represents the email verification request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
 }
 
 // Login handles user authentication and returns a JWT token
@@ -57,14 +135,60 @@ func (c *authController) Login(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Username and password are required")
 	}
 	
+	// Slow down or refuse attempts for throttled usernames and client IPs
+	ip := ctx.RealIP()
+	if wait := c.loginRetryAfter(req.Username, ip); wait > 0 {
+		ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	}
+	
 	// Authenticate the user
 	user, err := c.userRepo.ValidateCredentials(req.Username, req.Password)
+	if errors.Is(err, repository.ErrEmailNotVerified) {
+		return echo.NewHTTPError(http.StatusForbidden, "Email address has not been verified")
+	}
 	if err != nil {
+		c.recordLoginFailure(ctx, req.Username, ip, err.Error())
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid credentials")
 	}
 	
-	// Generate JWT token
-	token, err := auth.GenerateToken(user)
+	// A successful password check clears the username counter
+	c.throttleRepo.Reset(model.ThrottleKindUsername, req.Username)
+	
+	// An expired password has to be changed before the user can sign in
+	if c.passwordPolicy.Expired(user.PasswordChangedAt, time.Now()) {
+		challenge, err := auth.GenerateChallengeToken(user, auth.PurposePasswordChangeRequired)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+		}
+		return ctx.JSON(http.StatusOK, PasswordExpiredResponse{
+			Status:        auth.PurposePasswordChangeRequired,
+			PasswordToken: challenge,
+			ExpiresIn:     int(auth.MFAChallengeTTL.Seconds()),
+		})
+	}
+	
+	// Require the second factor, or its enrollment when MFA is enforced for admins
+	purpose := ""
+	if user.MFAEnabled {
+		purpose = auth.PurposeMFARequired
+	} else if user.Role == "admin" && c.settingRepo.GetBool(model.SettingRequireAdminMFA, false) {
+		purpose = auth.PurposeMFASetupRequired
+	}
+	if purpose != "" {
+		challenge, err := auth.GenerateChallengeToken(user, purpose)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+		}
+		return ctx.JSON(http.StatusOK, MFAChallengeResponse{
+			Status:    purpose,
+			MFAToken:  challenge,
+			ExpiresIn: int(auth.MFAChallengeTTL.Seconds()),
+		})
+	}
+	
+	// Start a new session and generate the token pair
+	response, err := startSession(ctx, c.sessionRepo, user)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
 	}
@@ -83,19 +207,50 @@ func (c *authController) Login(ctx echo.Context) error {
 		"username": user.Username,
 	})
 	
-	return ctx.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	return ctx.JSON(http.StatusOK, response)
 }
 
-// Register handles user registration
+// Register handles user registration. Depending on the registration mode, accounts are
+// created from an invite or stay inactive until the email address is verified.
 func (c *authController) Register(ctx echo.Context) error {
 	var req RegisterRequest
 	if err := ctx.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 	
+	mode := registrationMode(c.settingRepo)
+	if mode == model.RegistrationDisabled {
+		return echo.NewHTTPError(http.StatusForbidden, "Registration is disabled")
+	}
+	
+	// Validate the request
+	if req.Username == "" || req.Email == "" || req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Username, email and password are required")
+	}
+	
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Username must be 3 to 50 characters long and email must be valid")
+	}
+	
+	if err := c.checkNewPassword(&model.User{Username: req.Username, Email: req.Email}, req.Password); err != nil {
+		return err
+	}
+	
+	// Invites are required in invite-only mode and accepted in open mode
+	var invite *model.RegistrationInvite
+	if req.InviteToken != "" {
+		var err error
+		invite, err = c.registrationRepo.GetValidInvite(auth.HashToken(req.InviteToken))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired invite")
+		}
+		if !strings.EqualFold(invite.Email, req.Email) {
+			return echo.NewHTTPError(http.StatusBadRequest, "The invite was issued for another email address")
+		}
+	} else if mode == model.RegistrationInviteOnly {
+		return echo.NewHTTPError(http.StatusForbidden, "Registration requires an invite")
+	}
+	
 	// Check if username already exists
 	existingUser, err := c.userRepo.GetUserByUsername(req.Username)
 	if err == nil && existingUser != nil {
@@ -108,26 +263,54 @@ func (c *authController) Register(ctx echo.Context) error {
 		return echo.NewHTTPError(http.StatusConflict, "Email already registered")
 	}
 	
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = "user"
-	}
-	
-	// Create user object
+	// Create user object, inactive until the email is verified
 	user := &model.User{
 		Username: req.Username,
 		Email:    req.Email,
-		Role:     req.Role,
-		Active:   true,
+		Role:     model.RoleUser,
+	}
+	
+	// The invite was delivered to this email, which proves ownership
+	if invite != nil {
+		if invite.Role != model.RoleAdmin {
+			user.Role = invite.Role
+		}
+		user.Active = true
+		user.EmailVerified = true
+		if err := c.registrationRepo.UseInvite(invite.ID); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired invite")
+		}
 	}
 	
 	// Create the user in the database with hashed password
 	if err := c.userRepo.CreateUser(user, req.Password); err != nil {
+		if invite != nil {
+			c.registrationRepo.ReleaseInvite(invite.ID)
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user")
 	}
 	
-	// Generate JWT token
-	token, err := auth.GenerateToken(user)
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})
+	
+	// Clear sensitive data
+	user.PasswordHash = ""
+	
+	if invite == nil {
+		if err := c.sendVerificationEmail(user); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email")
+		}
+		return ctx.JSON(http.StatusCreated, map[string]interface{}{
+			"message": "Registration successful, check your email to verify your account",
+			"user":    user,
+		})
+	}
+	
+	// Start a new session and generate the token pair
+	response, err := startSession(ctx, c.sessionRepo, user)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
 	}
@@ -137,17 +320,480 @@ func (c *authController) Register(ctx echo.Context) error {
 	user.LastLogin = &now
 	c.userRepo.UpdateLastLogin(user.ID)
 	
+	return ctx.JSON(http.StatusCreated, response)
+}
+
+// VerifyEmail consumes a verification token and activates the account
+func (c *authController) VerifyEmail(ctx echo.Context) error {
+	var req VerifyEmailRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token is required")
+	}
+	
+	userID, err := c.registrationRepo.VerifyEmail(auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, repository.ErrVerificationTokenInvalid) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify email")
+	}
+	
+	// Store auth info in context for logging middleware
+	if user, err := c.userRepo.GetUserByID(userID); err == nil {
+		ctx.Set("auth_response", map[string]interface{}{
+			"user_id":  user.ID,
+			"username": user.Username,
+		})
+	}
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Email verified successfully, you can now log in",
+	})
+}
+
+// ResendVerification sends a new verification link to an unverified account.
+// The response is the same whether or not the email is registered.
+func (c *authController) ResendVerification(ctx echo.Context) error {
+	var req PasswordResetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+	
+	response := map[string]interface{}{
+		"message": "If the account is waiting for verification, a new link has been sent",
+	}
+	
+	user, err := c.userRepo.GetUserByEmail(req.Email)
+	if err != nil || user.EmailVerified {
+		return ctx.JSON(http.StatusOK, response)
+	}
+	
+	if err := c.sendVerificationEmail(user); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email")
+	}
+	
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func (c *authController) Refresh(ctx echo.Context) error {
+	var req RefreshRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Refresh token is required")
+	}
+	
+	// Look up the refresh token and its session
+	current, err := c.sessionRepo.GetRefreshTokenByHash(auth.HashToken(req.RefreshToken))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+	}
+	
+	session, err := c.sessionRepo.GetSessionByID(current.SessionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+	}
+	
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(current.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token expired or revoked")
+	}
+	
+	// A refresh token that was already exchanged indicates theft: revoke the whole family
+	if current.UsedAt != nil {
+		c.sessionRepo.RevokeSession(session.ID)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token reuse detected")
+	}
+	
+	// Make sure the account is still allowed to sign in
+	user, err := c.userRepo.GetUserByID(current.UserID)
+	if err != nil || !user.Active {
+		c.sessionRepo.RevokeSession(session.ID)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Account is inactive")
+	}
+	
+	// Rotate the refresh token
+	plainToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+	
+	next := &model.RefreshToken{
+		TokenHash: auth.HashToken(plainToken),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := c.sessionRepo.RotateRefreshToken(current, next); err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			c.sessionRepo.RevokeSession(session.ID)
+			return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token reuse detected")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refresh token")
+	}
+	
+	// Record the activity on the session
+	c.sessionRepo.TouchSession(session.ID, ctx.RealIP())
+	
+	// Issue a new access token bound to the same session
+	accessToken, err := auth.GenerateToken(user, session.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+	
 	// Clear sensitive data
 	user.PasswordHash = ""
 	
+	return ctx.JSON(http.StatusOK, LoginResponse{
+		Token:        accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int(auth.AccessTokenTTL().Seconds()),
+		User:         user,
+	})
+}
+
+// Logout revokes the current session and all of its refresh tokens
+func (c *authController) Logout(ctx echo.Context) error {
+	sessionID, _ := ctx.Get("session_id").(string)
+	if sessionID == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User not authenticated")
+	}
+	
+	if err := c.sessionRepo.RevokeSession(sessionID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to log out")
+	}
+	
+	// Store auth info in context for logging middleware
+	userID, _ := ctx.Get("user_id").(uint)
+	username, _ := ctx.Get("username").(string)
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  userID,
+		"username": username,
+	})
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Logged out successfully",
+	})
+}
+
+// ChangePassword changes the password of the authenticated user after checking the current one
+func (c *authController) ChangePassword(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+	
+	if auth.IsAPIKeyRequest(ctx) {
+		return echo.NewHTTPError(http.StatusForbidden, "Passwords cannot be changed with an API key")
+	}
+	
+	var req ChangePasswordRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Current and new password are required")
+	}
+	
+	// Check the current password
+	if err := c.userRepo.VerifyPassword(userID, req.CurrentPassword); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Current password is incorrect")
+	}
+	
+	user, err := c.userRepo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+	
+	if err := c.checkNewPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+	
+	if err := c.userRepo.ChangePassword(userID, req.NewPassword, c.passwordPolicy.HistorySize); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to change password")
+	}
+	
+	// Sign out everywhere, including the session used for this request
+	if err := c.sessionRepo.RevokeUserSessions(userID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	
+	// Store auth info in context for logging middleware
+	username, _ := ctx.Get("username").(string)
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  userID,
+		"username": username,
+	})
+	
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Password changed successfully, please log in again",
+	})
+}
+
+// RequestPasswordReset creates a single-use reset token and sends it to the user.
+// The response is the same whether or not the email is registered.
+func (c *authController) RequestPasswordReset(ctx echo.Context) error {
+	var req PasswordResetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+	
+	response := map[string]interface{}{
+		"message": "If the email is registered, a password reset link has been sent",
+	}
+	
+	user, err := c.userRepo.GetUserByEmail(req.Email)
+	if err != nil || !user.Active {
+		return ctx.JSON(http.StatusOK, response)
+	}
+	
+	// Generate the reset token, only its hash is stored
+	plainToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate reset token")
+	}
+	
+	resetToken := &model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(plainToken),
+		ExpiresAt: time.Now().Add(auth.PasswordResetTTL()),
+	}
+	if err := c.resetRepo.CreateToken(resetToken); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create reset token")
+	}
+	
+	// Deliver the token to the user
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:5173/reset-password"
+	}
+	err = c.notifier.Send(notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the following link to reset your password: %s?token=%s\nThe link expires at %s.",
+			resetURL, plainToken, resetToken.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send reset link")
+	}
+	
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// ConfirmPasswordReset consumes a reset token and sets a new password
+func (c *authController) ConfirmPasswordReset(ctx echo.Context) error {
+	var req PasswordResetConfirmRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	
+	if req.Token == "" || req.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token and new password are required")
+	}
+	
+	// Check the new password before consuming the token, so a rejected password can be retried
+	resetToken, err := c.resetRepo.GetValidToken(auth.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, repository.ErrResetTokenInvalid) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password")
+	}
+	
+	user, err := c.userRepo.GetUserByID(resetToken.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+	}
+	
+	if err := c.checkNewPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+	
+	if _, err := c.resetRepo.ConsumeToken(resetToken.TokenHash); err != nil {
+		if errors.Is(err, repository.ErrResetTokenInvalid) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired reset token")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password")
+	}
+	
+	if err := c.userRepo.ChangePassword(user.ID, req.NewPassword, c.passwordPolicy.HistorySize); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset password")
+	}
+	
+	// Invalidate every active session of the user
+	if err := c.sessionRepo.RevokeUserSessions(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+	
 	// Store auth info in context for logging middleware
 	ctx.Set("auth_response", map[string]interface{}{
 		"user_id":  user.ID,
 		"username": user.Username,
 	})
 	
-	return ctx.JSON(http.StatusCreated, LoginResponse{
-		Token: token,
-		User:  user,
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Password reset successfully",
 	})
-}
\ No newline at end of file
+}
+
+// GetPasswordPolicy returns the rules new passwords must follow, so clients can show them
+func (c *authController) GetPasswordPolicy(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.passwordPolicy)
+}
+
+// checkNewPassword validates a new password against the password policy and,
+// for existing users, against their recent passwords
+func (c *authController) checkNewPassword(user *model.User, password string) error {
+	if err := c.passwordPolicy.Validate(password, user.Username, user.Email); err != nil {
+		message := err.Error()
+		return echo.NewHTTPError(http.StatusBadRequest, strings.ToUpper(message[:1])+message[1:])
+	}
+	
+	if user.ID == 0 {
+		return nil
+	}
+	
+	reused, err := c.userRepo.IsPasswordReused(user.ID, password, c.passwordPolicy.HistorySize)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check password history")
+	}
+	if reused {
+		return echo.NewHTTPError(http.StatusBadRequest, "Password was used recently, choose a different one")
+	}
+	
+	return nil
+}
+
+// loginRetryAfter returns how long the username or client IP must wait before trying again
+func (c *authController) loginRetryAfter(username, ip string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	
+	if t, err := c.throttleRepo.Get(model.ThrottleKindUsername, username); err == nil {
+		wait = c.throttle.RetryAfter(t, now)
+	}
+	if t, err := c.throttleRepo.Get(model.ThrottleKindIP, ip); err == nil {
+		if ipWait := c.throttle.RetryAfter(t, now); ipWait > wait {
+			wait = ipWait
+		}
+	}
+	
+	return wait
+}
+
+// recordLoginFailure counts a failed attempt for the username and client IP
+// and stores the details in the context for the logging middleware
+func (c *authController) recordLoginFailure(ctx echo.Context, username, ip, reason string) {
+	c.throttleRepo.RecordFailure(model.ThrottleKindUsername, username,
+		c.throttle.MaxUsernameFailures, c.throttle.FailureWindow, c.throttle.LockoutDuration)
+	c.throttleRepo.RecordFailure(model.ThrottleKindIP, ip,
+		c.throttle.MaxIPFailures, c.throttle.FailureWindow, c.throttle.LockoutDuration)
+	
+	// Resolve the user ID when the username exists, 0 otherwise
+	userID := uint(0)
+	if user, err := c.userRepo.GetUserByUsername(username); err == nil {
+		userID = user.ID
+	}
+	
+	ctx.Set("auth_failure", map[string]interface{}{
+		"user_id":  userID,
+		"username": username,
+		"ip":       ip,
+		"reason":   reason,
+	})
+}
+
+// sendVerificationEmail creates a single-use verification token and sends it to the user
+func (c *authController) sendVerificationEmail(user *model.User) error {
+	plainToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	
+	token := &model.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(plainToken),
+		ExpiresAt: time.Now().Add(auth.EmailVerificationTTL()),
+	}
+	if err := c.registrationRepo.CreateVerificationToken(token); err != nil {
+		return err
+	}
+	
+	verifyURL := os.Getenv("EMAIL_VERIFICATION_URL")
+	if verifyURL == "" {
+		verifyURL = "http://localhost:5173/verify-email"
+	}
+	return c.notifier.Send(notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Use the following link to verify your email address: %s?token=%s\nThe link expires at %s.",
+			verifyURL, plainToken, token.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// startSession creates a new session for the user and issues an access and refresh token pair.
+// The client IP and user agent of the request are recorded on the session.
+func startSession(ctx echo.Context, sessionRepo *repository.SessionRepository, user *model.User) (*LoginResponse, error) {
+	sessionID, err := auth.GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	
+	plainToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	expiresAt := now.Add(auth.RefreshTokenTTL())
+	session := &model.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		IPAddress:  ctx.RealIP(),
+		UserAgent:  truncate(ctx.Request().UserAgent(), 255),
+		LastSeenAt: &now,
+		ExpiresAt:  expiresAt,
+	}
+	refreshToken := &model.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(plainToken),
+		ExpiresAt: expiresAt,
+	}
+	if err := sessionRepo.CreateSession(session, refreshToken); err != nil {
+		return nil, err
+	}
+	
+	accessToken, err := auth.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &LoginResponse{
+		Token:        accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int(auth.AccessTokenTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// truncate shortens a string to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type CrewController struct {
+	repo        *repository.CrewRepository
+	projectRepo *repository.ProjectRepository
+	validate    *validator.Validate
+}
+
+func NewCrewController(repo *repository.CrewRepository, projectRepo *repository.ProjectRepository) *CrewController {
+	return &CrewController{
+		repo:        repo,
+		projectRepo: projectRepo,
+		validate:    validator.New(),
+	}
+}
+
+// CrewAssignmentRequest assigns every member of a crew to a project with the same dates, allocation,
+// role and notes. Force saves the assignments even when members would be allocated more than 100 percent.
+type CrewAssignmentRequest struct {
+	CrewId     uint       `json:"crewId" validate:"required"`
+	StartDate  *time.Time `json:"start_date"`
+	EndDate    *time.Time `json:"end_date"`
+	Allocation int        `json:"allocation" validate:"omitempty,min=1,max=100"`
+	Role       string     `json:"role" validate:"max=50"`
+	Notes      string     `json:"notes" validate:"max=500"`
+	Force      bool       `json:"force"`
+}
+
+// GetCrews handles GET /api/crews
+func (c *CrewController) GetCrews(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	crews, err := c.repo.GetAll(orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !canReadSalary(ctx) {
+		for i := range crews {
+			redactCrew(&crews[i])
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": crews,
+	})
+}
+
+// GetCrew handles GET /api/crews/:id
+func (c *CrewController) GetCrew(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	crew, err := c.repo.GetByID(uint(id), orgID)
+	if err != nil {
+		return crewError(ctx, err)
+	}
+	if !canReadSalary(ctx) {
+		redactCrew(crew)
+	}
+
+	return ctx.JSON(http.StatusOK, crew)
+}
+
+// CreateCrew handles POST /api/crews
+func (c *CrewController) CreateCrew(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	crew, err := c.bindCrew(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.Create(crew, orgID, userID); err != nil {
+		return crewError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, crew)
+}
+
+// UpdateCrew handles PUT /api/crews/:id
+func (c *CrewController) UpdateCrew(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	crew, err := c.bindCrew(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	crew.ID = uint(id)
+
+	if err := c.repo.Update(crew, orgID); err != nil {
+		return crewError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, crew)
+}
+
+// DeleteCrew handles DELETE /api/crews/:id
+func (c *CrewController) DeleteCrew(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
+		return crewError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// AssignCrewToProject handles POST /api/projects/:id/crews. Members who would be allocated more
+// than 100 percent are reported with 409 unless the request is forced.
+func (c *CrewController) AssignCrewToProject(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	request, err := c.bindCrewAssignment(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	assignment := model.WorkerProject{
+		ProjectID:  uint(projectId),
+		StartDate:  request.StartDate,
+		EndDate:    request.EndDate,
+		Allocation: request.Allocation,
+		Role:       request.Role,
+		Notes:      request.Notes,
+	}
+
+	conflicts, err := c.repo.AssignToProject(request.CrewId, assignment, orgID, userID, request.Force)
+	if err != nil {
+		if errors.Is(err, repository.ErrOverAllocated) {
+			return ctx.JSON(http.StatusConflict, map[string]interface{}{
+				"error":     "Some crew members would be allocated more than 100%",
+				"conflicts": conflicts,
+			})
+		}
+		return crewError(ctx, err)
+	}
+	if len(conflicts) > 0 {
+		ctx.Set("allocation_override", fmt.Sprintf("Over-allocation forced: crew %d on project %d with %d over-allocated worker(s)",
+			request.CrewId, projectId, len(conflicts)))
+	}
+
+	project, err := c.projectRepo.GetByID(uint(projectId), orgID, nil)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	redactSalaries(ctx, project.Workers)
+	redactAssignments(ctx, project.Assignments)
+
+	return ctx.JSON(http.StatusOK, project)
+}
+
+// UnassignCrewFromProject handles DELETE /api/projects/:id/crews/:crewId. Only the workers assigned
+// with the crew are removed.
+func (c *CrewController) UnassignCrewFromProject(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	crewId, err := strconv.ParseUint(ctx.Param("crewId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid crew ID"})
+	}
+
+	if _, err := c.repo.UnassignFromProject(uint(projectId), uint(crewId), orgID); err != nil {
+		return crewError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// bindCrew binds and validates a crew from the request body
+func (c *CrewController) bindCrew(ctx echo.Context) (*model.Crew, error) {
+	var crew model.Crew
+	if err := ctx.Bind(&crew); err != nil {
+		return nil, err
+	}
+	crew.Members = nil
+	crew.Lead = nil
+
+	if err := c.validate.Struct(crew); err != nil {
+		return nil, err
+	}
+
+	return &crew, nil
+}
+
+// bindCrewAssignment binds and validates a crew assignment from the request body
+func (c *CrewController) bindCrewAssignment(ctx echo.Context) (*CrewAssignmentRequest, error) {
+	var request CrewAssignmentRequest
+	if err := ctx.Bind(&request); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate.Struct(request); err != nil {
+		return nil, err
+	}
+
+	if request.StartDate != nil && request.EndDate != nil && request.EndDate.Before(*request.StartDate) {
+		return nil, errors.New("end date must not be before the start date")
+	}
+
+	if request.Allocation == 0 {
+		request.Allocation = 100
+	}
+
+	return &request, nil
+}
+
+// redactCrew zeroes the salaries of the crew's lead and members
+func redactCrew(crew *model.Crew) {
+	redactWorker(crew.Lead)
+	for i := range crew.Members {
+		redactWorker(crew.Members[i].Worker)
+	}
+}
+
+// crewError maps crew repository errors to responses
+func crewError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrCrewExists):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "A crew with this name already exists"})
+	case errors.Is(err, repository.ErrCrewMember):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Some workers already belong to another crew"})
+	case errors.Is(err, repository.ErrCrewLead):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "The crew lead must be a member of the crew"})
+	case errors.Is(err, repository.ErrCrewEmpty):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "The crew has no members"})
+	case errors.Is(err, repository.ErrAlreadyAssigned):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Some crew members are already assigned to the project"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Crew, worker, project or crew assignment not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,435 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type LeaveController struct {
+	repo     *repository.LeaveRepository
+	validate *validator.Validate
+}
+
+func NewLeaveController(repo *repository.LeaveRepository) *LeaveController {
+	return &LeaveController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// LeaveReviewRequest approves or rejects a leave request
+type LeaveReviewRequest struct {
+	Note string `json:"note" validate:"max=255"` // Required when rejecting
+}
+
+// GetLeaveRequests handles GET /api/leave?worker_id=1&type=vacation&status=approved&from=2024-07-01&until=2024-07-31
+func (c *LeaveController) GetLeaveRequests(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	filters := make(map[string]interface{})
+	if value := ctx.QueryParam("worker_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker_id"})
+		}
+		filters["worker_id"] = uint(id)
+	}
+	for _, key := range []string{"type", "status"} {
+		if value := ctx.QueryParam(key); value != "" {
+			filters[key] = value
+		}
+	}
+	for _, key := range []string{"from", "until"} {
+		if value := ctx.QueryParam(key); value != "" {
+			date, err := parseDate(value)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+			}
+			filters[key] = date
+		}
+	}
+
+	// Handle pagination
+	page := 1
+	pageSize := 50
+
+	if pageParam := ctx.QueryParam("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if pageSizeParam := ctx.QueryParam("page_size"); pageSizeParam != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 {
+			pageSize = parsedPageSize
+		}
+	}
+
+	leaves, total, err := c.repo.GetAll(orgID, filters, page, pageSize)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !canReadSalary(ctx) {
+		for i := range leaves {
+			redactLeave(&leaves[i])
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":     leaves,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// GetLeaveRequest handles GET /api/leave/:id
+func (c *LeaveController) GetLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	leave, err := c.repo.GetByID(uint(id), orgID)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+	if !canReadSalary(ctx) {
+		redactLeave(leave)
+	}
+
+	return ctx.JSON(http.StatusOK, leave)
+}
+
+// CreateLeaveRequest handles POST /api/leave
+func (c *LeaveController) CreateLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	leave, err := c.bindLeave(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.Create(leave, orgID, userID); err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, leave)
+}
+
+// UpdateLeaveRequest handles PUT /api/leave/:id
+func (c *LeaveController) UpdateLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	leave, err := c.bindLeave(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	leave.ID = uint(id)
+
+	if err := c.repo.Update(leave, orgID); err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, leave)
+}
+
+// DeleteLeaveRequest handles DELETE /api/leave/:id
+func (c *LeaveController) DeleteLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// ApproveLeaveRequest handles POST /api/leave/:id/approve
+func (c *LeaveController) ApproveLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	req, err := c.bindReview(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	leave, err := c.repo.Approve(uint(id), orgID, userID, req.Note)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Approved leave request %d", leave.ID))
+	if !canReadSalary(ctx) {
+		redactLeave(leave)
+	}
+	return ctx.JSON(http.StatusOK, leave)
+}
+
+// RejectLeaveRequest handles POST /api/leave/:id/reject
+func (c *LeaveController) RejectLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	req, err := c.bindReview(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Note == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "A note is required to reject a leave request"})
+	}
+
+	leave, err := c.repo.Reject(uint(id), orgID, userID, req.Note)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Rejected leave request %d: %s", leave.ID, req.Note))
+	if !canReadSalary(ctx) {
+		redactLeave(leave)
+	}
+	return ctx.JSON(http.StatusOK, leave)
+}
+
+// CancelLeaveRequest handles POST /api/leave/:id/cancel
+func (c *LeaveController) CancelLeaveRequest(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	leave, err := c.repo.Cancel(uint(id), orgID)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Cancelled leave request %d", leave.ID))
+	if !canReadSalary(ctx) {
+		redactLeave(leave)
+	}
+	return ctx.JSON(http.StatusOK, leave)
+}
+
+// GetBalance handles GET /api/workers/:id/leave-balance?year=2024. The year defaults to the current one.
+func (c *LeaveController) GetBalance(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	year := time.Now().Year()
+	if yearParam := ctx.QueryParam("year"); yearParam != "" {
+		year, err = strconv.Atoi(yearParam)
+		if err != nil || year < 2000 || year > 2100 {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid year"})
+		}
+	}
+
+	balances, err := c.repo.GetBalance(uint(workerId), orgID, year)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": balances,
+		"year": year,
+	})
+}
+
+// SetAllowance handles PUT /api/workers/:id/leave-allowances
+func (c *LeaveController) SetAllowance(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	var allowance model.LeaveAllowance
+	if err := ctx.Bind(&allowance); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	allowance.WorkerID = uint(workerId)
+
+	if err := c.validate.Struct(allowance); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.SetAllowance(&allowance, orgID); err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, allowance)
+}
+
+// GetAbsences handles GET /api/projects/:id/absences?from=2024-07-01&until=2024-07-31.
+// The range defaults to the current month.
+func (c *LeaveController) GetAbsences(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, -1)
+	if fromParam := ctx.QueryParam("from"); fromParam != "" {
+		if from, err = parseDate(fromParam); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+		}
+	}
+	if untilParam := ctx.QueryParam("until"); untilParam != "" {
+		if until, err = parseDate(untilParam); err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+		}
+	}
+	if err := checkAttendanceRange(from, until); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	absences, err := c.repo.GetAbsences(uint(projectId), orgID, from, until)
+	if err != nil {
+		return leaveError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":  absences,
+		"from":  from.Format(dateLayout),
+		"until": until.Format(dateLayout),
+	})
+}
+
+// bindLeave binds and validates a leave request from the request body
+func (c *LeaveController) bindLeave(ctx echo.Context) (*model.LeaveRequest, error) {
+	var leave model.LeaveRequest
+	if err := ctx.Bind(&leave); err != nil {
+		return nil, err
+	}
+	leave.Worker = nil
+
+	if err := c.validate.Struct(leave); err != nil {
+		return nil, err
+	}
+
+	return &leave, nil
+}
+
+// bindReview binds and validates a leave review from the request body
+func (c *LeaveController) bindReview(ctx echo.Context) (*LeaveReviewRequest, error) {
+	var req LeaveReviewRequest
+	if err := ctx.Bind(&req); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// redactLeave zeroes the salary of the leave request's worker
+func redactLeave(leave *model.LeaveRequest) {
+	redactWorker(leave.Worker)
+}
+
+// leaveError maps leave repository errors to responses
+func leaveError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrLeaveNoDays):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "The leave request covers no working day"})
+	case errors.Is(err, repository.ErrLeaveOverlap):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The worker already has pending or approved leave on some of these days"})
+	case errors.Is(err, repository.ErrLeaveStatus):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The leave request is not in a state that allows this action"})
+	case errors.Is(err, repository.ErrLeaveAllowance):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Approving the leave would exceed the worker's allowance for the year"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Leave request, worker or project not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
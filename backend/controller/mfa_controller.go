@@ -0,0 +1,351 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// recoveryCodeCount is the number of recovery codes generated on enrollment
+const recoveryCodeCount = 10
+
+type MFAController interface {
+	Verify(c echo.Context) error
+	Setup(c echo.Context) error
+	Enable(c echo.Context) error
+	Disable(c echo.Context) error
+}
+
+type mfaController struct {
+	userRepo     repository.UserRepository
+	mfaRepo      *repository.MFARepository
+	sessionRepo  *repository.SessionRepository
+	settingRepo  *repository.SettingRepository
+	throttleRepo *repository.LoginThrottleRepository
+	throttle     auth.LoginThrottlePolicy
+}
+
+func NewMFAController(userRepo repository.UserRepository, mfaRepo *repository.MFARepository, sessionRepo *repository.SessionRepository, settingRepo *repository.SettingRepository, throttleRepo *repository.LoginThrottleRepository) MFAController {
+	return &mfaController{
+		userRepo:     userRepo,
+		mfaRepo:      mfaRepo,
+		sessionRepo:  sessionRepo,
+		settingRepo:  settingRepo,
+		throttleRepo: throttleRepo,
+		throttle:     auth.LoadLoginThrottlePolicy(),
+	}
+}
+
+// MFAVerifyRequest represents the second login step request body
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFASetupResponse represents the enrollment data shown to the user
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to render as a QR code
+}
+
+// MFAEnableRequest represents the enrollment confirmation request body
+type MFAEnableRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAEnableResponse returns the recovery codes, and a token pair when enrollment completed a login
+type MFAEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	*LoginResponse
+}
+
+// MFADisableRequest represents the MFA removal request body
+type MFADisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// Verify completes a login with a TOTP or recovery code and returns a token pair
+func (c *mfaController) Verify(ctx echo.Context) error {
+	var req MFAVerifyRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA token and a code are required")
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.MFAToken, auth.PurposeMFARequired)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	user, err := c.userRepo.GetUserByID(claims.UserID)
+	if err != nil || !user.Active || !user.MFAEnabled {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	// Refuse users locked after too many wrong codes and the challenge tokens issued before the lock
+	subject := mfaSubject(user)
+	throttle, err := c.checkMFALock(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if throttle != nil && throttle.Failures >= c.throttle.MaxMFAFailures && (claims.IssuedAt == nil || !claims.IssuedAt.After(throttle.LastFailureAt)) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+	}
+
+	// Check the TOTP code or consume a recovery code
+	if req.Code != "" {
+		if err := c.checkTOTP(user, req.Code); err != nil {
+			c.recordMFAFailure(subject)
+			return err
+		}
+	} else {
+		ok, err := c.mfaRepo.UseRecoveryCode(user.ID, auth.HashRecoveryCode(req.RecoveryCode))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify recovery code")
+		}
+		if !ok {
+			c.recordMFAFailure(subject)
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid recovery code")
+		}
+	}
+	c.throttleRepo.Reset(model.ThrottleKindMFA, subject)
+
+	// Start a new session and generate the token pair
+	response, err := startSession(ctx, c.sessionRepo, user)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	// Update last login timestamp
+	now := time.Now()
+	user.LastLogin = &now
+	c.userRepo.UpdateLastLogin(user.ID)
+
+	// Clear sensitive data
+	user.PasswordHash = ""
+
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Setup generates a new pending TOTP secret for the authenticated user
+func (c *mfaController) Setup(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.userRepo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.MFAEnabled {
+		return echo.NewHTTPError(http.StatusConflict, "MFA is already enabled")
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate MFA secret")
+	}
+
+	if err := c.mfaRepo.SetPendingSecret(user.ID, secret); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store MFA secret")
+	}
+
+	return ctx.JSON(http.StatusOK, MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, user.Username),
+	})
+}
+
+// Enable verifies the first TOTP code for the pending secret and turns MFA on.
+func (c *mfaController) Enable(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req MFAEnableRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	user, err := c.userRepo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.MFAEnabled {
+		return echo.NewHTTPError(http.StatusConflict, "MFA is already enabled")
+	}
+
+	if user.MFASecret == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA setup has not been started")
+	}
+
+	// Wrong codes count towards the same lock as on login
+	subject := mfaSubject(user)
+	if _, err := c.checkMFALock(ctx, subject); err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(user.MFASecret, req.Code, time.Now())
+	if !ok {
+		c.recordMFAFailure(subject)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid MFA code")
+	}
+	c.throttleRepo.Reset(model.ThrottleKindMFA, subject)
+
+	// Generate the recovery codes, only their hashes are stored
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate recovery codes")
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+
+	if err := c.mfaRepo.Enable(user.ID, step, hashes); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enable MFA")
+	}
+
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})
+
+	response := MFAEnableResponse{RecoveryCodes: codes}
+
+	// Enrollment forced during login completes that login
+	if setup, _ := ctx.Get("mfa_setup").(bool); setup {
+		user.MFAEnabled = true
+		response.LoginResponse, err = startSession(ctx, c.sessionRepo, user)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+		}
+		c.userRepo.UpdateLastLogin(user.ID)
+		user.PasswordHash = ""
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Disable turns MFA off after checking the password and a current TOTP code.
+func (c *mfaController) Disable(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req MFADisableRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	user, err := c.userRepo.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if !user.MFAEnabled {
+		return echo.NewHTTPError(http.StatusBadRequest, "MFA is not enabled")
+	}
+
+	if user.Role == "admin" && c.settingRepo.GetBool(model.SettingRequireAdminMFA, false) {
+		return echo.NewHTTPError(http.StatusForbidden, "MFA is required for admin accounts")
+	}
+
+	// Wrong codes count towards the same lock as on login
+	subject := mfaSubject(user)
+	if _, err := c.checkMFALock(ctx, subject); err != nil {
+		return err
+	}
+
+	if err := c.userRepo.VerifyPassword(user.ID, req.Password); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Password is incorrect")
+	}
+
+	if err := c.checkTOTP(user, req.Code); err != nil {
+		c.recordMFAFailure(subject)
+		return err
+	}
+	c.throttleRepo.Reset(model.ThrottleKindMFA, subject)
+
+	if err := c.mfaRepo.Disable(user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to disable MFA")
+	}
+
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "MFA disabled successfully",
+	})
+}
+
+// mfaSubject returns the throttle subject of a user's second-factor attempts
+func mfaSubject(user *model.User) string {
+	return strconv.FormatUint(uint64(user.ID), 10)
+}
+
+// checkMFALock returns the second-factor throttle of a user, or a 429 error while the user is locked
+// after too many wrong codes
+func (c *mfaController) checkMFALock(ctx echo.Context, subject string) (*model.LoginThrottle, error) {
+	throttle, err := c.throttleRepo.Get(model.ThrottleKindMFA, subject)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify MFA code")
+	}
+	if throttle != nil && throttle.LockedUntil != nil {
+		if now := time.Now(); now.Before(*throttle.LockedUntil) {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(throttle.LockedUntil.Sub(now).Seconds())+1))
+			return nil, echo.NewHTTPError(http.StatusTooManyRequests, "Too many failed MFA attempts, try again later")
+		}
+	}
+	return throttle, nil
+}
+
+// recordMFAFailure counts a wrong second-factor code of a user, locking their challenges once
+// MaxMFAFailures is reached
+func (c *mfaController) recordMFAFailure(subject string) {
+	c.throttleRepo.RecordFailure(model.ThrottleKindMFA, subject,
+		c.throttle.MaxMFAFailures, c.throttle.FailureWindow, c.throttle.LockoutDuration)
+}
+
+// checkTOTP validates a TOTP code of an enrolled user and rejects replayed codes
+func (c *mfaController) checkTOTP(user *model.User, code string) error {
+	step, ok := auth.ValidateTOTP(user.MFASecret, code, time.Now())
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid MFA code")
+	}
+
+	if err := c.mfaRepo.RecordStep(user.ID, step); err != nil {
+		if errors.Is(err, repository.ErrTOTPReplayed) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "MFA code already used")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify MFA code")
+	}
+
+	return nil
+}
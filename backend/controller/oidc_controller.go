@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/oidc"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// oidcStateTTL is how long a single sign-on login may take at the provider
+const oidcStateTTL = 10 * time.Minute
+
+type OIDCController interface {
+	Authorize(c echo.Context) error
+	Callback(c echo.Context) error
+}
+
+type oidcController struct {
+	provider    *oidc.Provider
+	oidcRepo    *repository.OIDCRepository
+	userRepo    repository.UserRepository
+	roleRepo    *repository.RoleRepository
+	sessionRepo *repository.SessionRepository
+	settingRepo *repository.SettingRepository
+}
+
+func NewOIDCController(provider *oidc.Provider, oidcRepo *repository.OIDCRepository, userRepo repository.UserRepository, roleRepo *repository.RoleRepository, sessionRepo *repository.SessionRepository, settingRepo *repository.SettingRepository) OIDCController {
+	return &oidcController{
+		provider:    provider,
+		oidcRepo:    oidcRepo,
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		sessionRepo: sessionRepo,
+		settingRepo: settingRepo,
+	}
+}
+
+// OIDCCallbackRequest represents the parameters the provider sent back to the redirect URL
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// Authorize handles GET /api/auth/oidc/authorize.
+// It starts a login and returns the provider URL the browser should be sent to.
+func (c *oidcController) Authorize(ctx echo.Context) error {
+	if !c.provider.Config().Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "Single sign-on is not configured")
+	}
+
+	// State, nonce and PKCE verifier are random and bound together server-side
+	state, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	nonce, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+	verifier, err := auth.GenerateRandomToken(48)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+
+	authURL, err := c.provider.AuthCodeURL(ctx.Request().Context(), state, nonce, verifier)
+	if err != nil {
+		fmt.Printf("OIDC discovery failed: %v\n", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Identity provider is unavailable")
+	}
+
+	err = c.oidcRepo.CreateState(&model.OIDCLoginState{
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"authorization_url": authURL,
+		"expires_in":        int(oidcStateTTL.Seconds()),
+	})
+}
+
+// Callback handles POST /api/auth/oidc/callback. It redeems the authorization code,
+// validates the ID token and signs the linked user in.
+func (c *oidcController) Callback(ctx echo.Context) error {
+	if !c.provider.Config().Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "Single sign-on is not configured")
+	}
+
+	var req OIDCCallbackRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.Code == "" || req.State == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Code and state are required")
+	}
+
+	// The state is single-use and carries the verifier and nonce of this login
+	state, err := c.oidcRepo.ConsumeState(auth.HashToken(req.State))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired login state")
+	}
+
+	token, err := c.provider.Exchange(ctx.Request().Context(), req.Code, state.CodeVerifier)
+	if err != nil {
+		fmt.Printf("OIDC code exchange failed: %v\n", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Single sign-on failed")
+	}
+
+	claims, err := c.provider.VerifyIDToken(ctx.Request().Context(), token.IDToken, state.Nonce)
+	if err != nil {
+		fmt.Printf("OIDC ID token rejected: %v\n", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Single sign-on failed")
+	}
+
+	user, identity, err := c.resolveUser(claims)
+	if err != nil {
+		return err
+	}
+
+	if !user.Active {
+		return echo.NewHTTPError(http.StatusForbidden, "Account is inactive")
+	}
+
+	// Keep the role in sync with the provider when the role claim maps to a known role
+	if role := c.provider.MapRole(claims); role != "" && role != user.Role {
+		if exists, err := c.roleRepo.Exists(role); err == nil && exists {
+			if err := c.userRepo.UpdateUserRole(user.ID, role); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update role")
+			}
+			// Tokens carry the role, so sessions with the old one are signed out
+			c.sessionRepo.RevokeUserSessions(user.ID)
+			user.Role = role
+		}
+	}
+
+	// Start a new session and generate the token pair
+	response, err := startSession(ctx, c.sessionRepo, user)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	// Update last login timestamps
+	now := time.Now()
+	user.LastLogin = &now
+	c.userRepo.UpdateLastLogin(user.ID)
+	c.oidcRepo.RecordLogin(identity)
+
+	// Clear sensitive data
+	user.PasswordHash = ""
+
+	// Store auth info in context for logging middleware
+	ctx.Set("auth_response", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// resolveUser finds the user linked to the provider account. Unlinked accounts are linked
+// to the user with the same verified email, or provisioned when auto-provisioning is on.
+func (c *oidcController) resolveUser(claims *oidc.Claims) (*model.User, *model.UserIdentity, error) {
+	identity, err := c.oidcRepo.GetIdentity(claims.Issuer, claims.Subject)
+	if err == nil {
+		identity.Email = claims.Email
+		user, err := c.userRepo.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, nil, echo.NewHTTPError(http.StatusForbidden, "Account is inactive")
+		}
+		return user, identity, nil
+	}
+
+	// Only a verified email proves ownership of an existing account
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, nil, echo.NewHTTPError(http.StatusForbidden, "The identity provider did not return a verified email")
+	}
+
+	user, err := c.userRepo.GetUserByEmail(claims.Email)
+	if err != nil {
+		// New accounts are only created while registration is open
+		account, err := c.provider.NewAccount(claims)
+		if err != nil || registrationMode(c.settingRepo) != model.RegistrationOpen {
+			return nil, nil, echo.NewHTTPError(http.StatusForbidden, "No account is linked to this identity")
+		}
+		if user, err = c.provisionUser(account); err != nil {
+			return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user")
+		}
+	}
+
+	identity = &model.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	if err := c.oidcRepo.CreateIdentity(identity); err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to link identity")
+	}
+	return user, identity, nil
+}
+
+// provisionUser creates a user for a provider account. The user signs in through the
+// provider only, so the password is random and never shown.
+func (c *oidcController) provisionUser(account *oidc.Account) (*model.User, error) {
+	role := account.Role
+	if exists, err := c.roleRepo.Exists(role); err != nil || !exists {
+		role = model.RoleUser
+	}
+
+	username, err := c.availableUsername(account.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Username:      username,
+		Email:         account.Email,
+		Role:          role,
+		Active:        true,
+		EmailVerified: true,
+	}
+	if err := c.userRepo.CreateUser(user, password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// availableUsername returns base, or base with a number appended when it is taken
+func (c *oidcController) availableUsername(base string) (string, error) {
+	candidate := base
+	for i := 2; i <= 100; i++ {
+		if _, err := c.userRepo.GetUserByUsername(candidate); err != nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("no username available")
+}
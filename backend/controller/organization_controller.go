@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type OrganizationController interface {
+	GetMyOrganizations(c echo.Context) error
+	CreateOrganization(c echo.Context) error
+	GetMembers(c echo.Context) error
+	UpdateMemberRole(c echo.Context) error
+	RemoveMember(c echo.Context) error
+	GetInvitations(c echo.Context) error
+	InviteUser(c echo.Context) error
+	RevokeInvitation(c echo.Context) error
+	GetMyInvitations(c echo.Context) error
+	AcceptInvitation(c echo.Context) error
+	DeclineInvitation(c echo.Context) error
+}
+
+type organizationController struct {
+	orgRepo  *repository.OrganizationRepository
+	userRepo repository.UserRepository
+	validate *validator.Validate
+}
+
+func NewOrganizationController(orgRepo *repository.OrganizationRepository, userRepo repository.UserRepository) OrganizationController {
+	return &organizationController{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		validate: validator.New(),
+	}
+}
+
+// InvitationRequest represents the invitation request body. The invitee is an existing user
+// identified by username or email.
+type InvitationRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role" validate:"omitempty,oneof=admin member"`
+}
+
+// GetMyOrganizations handles GET /api/organizations
+func (c *organizationController) GetMyOrganizations(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	memberships, err := c.orgRepo.GetUserOrganizations(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch organizations")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": memberships,
+	})
+}
+
+// CreateOrganization handles POST /api/organizations
+func (c *organizationController) CreateOrganization(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	org := &model.Organization{
+		Name:    req.Name,
+		OwnerID: userID,
+	}
+	if err := c.validate.Struct(org); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := c.orgRepo.Create(org); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create organization")
+	}
+
+	return ctx.JSON(http.StatusCreated, org)
+}
+
+// GetMembers handles GET /api/organizations/:id/members
+func (c *organizationController) GetMembers(ctx echo.Context) error {
+	orgID, _, err := c.requireOrgRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.orgRepo.GetMembers(orgID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch members")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": members,
+	})
+}
+
+// UpdateMemberRole handles PUT /api/organizations/:id/members/:userId
+func (c *organizationController) UpdateMemberRole(ctx echo.Context) error {
+	orgID, _, err := c.requireOrgRole(ctx, model.OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	memberID, err := strconv.ParseUint(ctx.Param("userId"), 10, 32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req struct {
+		Role string `json:"role" validate:"required,oneof=admin member"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role. Must be 'admin' or 'member'")
+	}
+
+	if err := c.orgRepo.UpdateMemberRole(orgID, uint(memberID), req.Role); err != nil {
+		return memberError(err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Member role updated successfully",
+	})
+}
+
+// RemoveMember handles DELETE /api/organizations/:id/members/:userId.
+// Owners and admins can remove members, and every member can leave.
+func (c *organizationController) RemoveMember(ctx echo.Context) error {
+	orgID, membership, err := c.requireOrgRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	memberID, err := strconv.ParseUint(ctx.Param("userId"), 10, 32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	leaving := uint(memberID) == membership.UserID
+	if !leaving && membership.Role != model.OrgRoleOwner && membership.Role != model.OrgRoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "Only owners and admins can remove members")
+	}
+
+	if err := c.orgRepo.RemoveMember(orgID, uint(memberID)); err != nil {
+		return memberError(err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetInvitations handles GET /api/organizations/:id/invitations
+func (c *organizationController) GetInvitations(ctx echo.Context) error {
+	orgID, _, err := c.requireOrgRole(ctx, model.OrgRoleOwner, model.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := c.orgRepo.GetInvitations(orgID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch invitations")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": invitations,
+	})
+}
+
+// InviteUser handles POST /api/organizations/:id/invitations
+func (c *organizationController) InviteUser(ctx echo.Context) error {
+	orgID, membership, err := c.requireOrgRole(ctx, model.OrgRoleOwner, model.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	var req InvitationRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role. Must be 'admin' or 'member'")
+	}
+	if req.Role == "" {
+		req.Role = model.OrgRoleMember
+	}
+
+	// Look up the invited user
+	var invitee *model.User
+	switch {
+	case req.Username != "":
+		invitee, err = c.userRepo.GetUserByUsername(req.Username)
+	case req.Email != "":
+		invitee, err = c.userRepo.GetUserByEmail(req.Email)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Username or email is required")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	invitation := &model.OrganizationInvitation{
+		OrganizationID: orgID,
+		UserID:         invitee.ID,
+		InvitedByID:    membership.UserID,
+		Role:           req.Role,
+	}
+	if err := c.orgRepo.CreateInvitation(invitation); err != nil {
+		if errors.Is(err, repository.ErrAlreadyMember) {
+			return echo.NewHTTPError(http.StatusConflict, "User is already a member")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create invitation")
+	}
+
+	return ctx.JSON(http.StatusCreated, invitation)
+}
+
+// RevokeInvitation handles DELETE /api/organizations/:id/invitations/:invitationId
+func (c *organizationController) RevokeInvitation(ctx echo.Context) error {
+	orgID, _, err := c.requireOrgRole(ctx, model.OrgRoleOwner, model.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	invitationID, err := strconv.ParseUint(ctx.Param("invitationId"), 10, 32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	if err := c.orgRepo.RevokeInvitation(orgID, uint(invitationID)); err != nil {
+		if errors.Is(err, repository.ErrInvitationNotPending) {
+			return echo.NewHTTPError(http.StatusNotFound, "Pending invitation not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke invitation")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetMyInvitations handles GET /api/organizations/invitations
+func (c *organizationController) GetMyInvitations(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := c.orgRepo.GetPendingInvitationsForUser(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch invitations")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": invitations,
+	})
+}
+
+// AcceptInvitation handles POST /api/organizations/invitations/:invitationId/accept
+func (c *organizationController) AcceptInvitation(ctx echo.Context) error {
+	return c.respondToInvitation(ctx, true)
+}
+
+// DeclineInvitation handles POST /api/organizations/invitations/:invitationId/decline
+func (c *organizationController) DeclineInvitation(ctx echo.Context) error {
+	return c.respondToInvitation(ctx, false)
+}
+
+// respondToInvitation accepts or declines an invitation addressed to the current user
+func (c *organizationController) respondToInvitation(ctx echo.Context, accept bool) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	invitationID, err := strconv.ParseUint(ctx.Param("invitationId"), 10, 32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	invitation, err := c.orgRepo.GetInvitation(uint(invitationID))
+	if err != nil || invitation.UserID != userID {
+		return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+	}
+
+	if err := c.orgRepo.RespondToInvitation(invitation, accept); err != nil {
+		if errors.Is(err, repository.ErrInvitationNotPending) {
+			return echo.NewHTTPError(http.StatusConflict, "Invitation is no longer pending")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to respond to invitation")
+	}
+
+	return ctx.JSON(http.StatusOK, invitation)
+}
+
+// requireOrgRole parses the organization ID from the path and checks that the current user
+// is a member of it, optionally with one of the given roles
+func (c *organizationController) requireOrgRole(ctx echo.Context, roles ...string) (uint, *model.OrganizationMember, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	orgID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return 0, nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid organization ID")
+	}
+
+	membership, err := c.orgRepo.GetMembership(uint(orgID), userID)
+	if err != nil {
+		return 0, nil, echo.NewHTTPError(http.StatusNotFound, "Organization not found")
+	}
+
+	if len(roles) == 0 {
+		return uint(orgID), membership, nil
+	}
+	for _, role := range roles {
+		if membership.Role == role {
+			return uint(orgID), membership, nil
+		}
+	}
+	return 0, nil, echo.NewHTTPError(http.StatusForbidden, "Insufficient organization role")
+}
+
+// memberError maps membership repository errors to HTTP errors
+func memberError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrOwnerMembership):
+		return echo.NewHTTPError(http.StatusForbidden, "The owner's membership cannot be changed")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Member not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update membership")
+}
@@ -0,0 +1,419 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultOvertimeMultiplier applies to runs created without an overtime multiplier
+	defaultOvertimeMultiplier = 1.5
+	// maxPayPeriodDays limits the length of a pay period
+	maxPayPeriodDays = 92
+)
+
+type PayrollController struct {
+	repo     *repository.PayrollRepository
+	validate *validator.Validate
+}
+
+func NewPayrollController(repo *repository.PayrollRepository) *PayrollController {
+	return &PayrollController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// GetPayRates handles GET /api/workers/:id/pay-rates
+func (c *PayrollController) GetPayRates(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	rates, err := c.repo.GetPayRates(uint(workerID), orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": rates,
+	})
+}
+
+// CreatePayRate handles POST /api/workers/:id/pay-rates
+func (c *PayrollController) CreatePayRate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	var rate model.PayRate
+	if err := ctx.Bind(&rate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	rate.WorkerID = uint(workerID)
+
+	if err := c.validate.Struct(rate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.CreatePayRate(&rate, orgID, userID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, rate)
+}
+
+// UpdatePayRate handles PUT /api/workers/:id/pay-rates/:rateId
+func (c *PayrollController) UpdatePayRate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("rateId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pay rate ID"})
+	}
+
+	var rate model.PayRate
+	if err := ctx.Bind(&rate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	rate.ID = uint(id)
+	rate.WorkerID = uint(workerID)
+
+	if err := c.validate.Struct(rate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.UpdatePayRate(&rate, orgID, userID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, rate)
+}
+
+// DeletePayRate handles DELETE /api/workers/:id/pay-rates/:rateId
+func (c *PayrollController) DeletePayRate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("rateId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid pay rate ID"})
+	}
+
+	if err := c.repo.DeletePayRate(uint(workerID), uint(id), orgID, userID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetRuns handles GET /api/payroll/runs
+func (c *PayrollController) GetRuns(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Handle pagination
+	page := 1
+	pageSize := 10
+
+	if pageParam := ctx.QueryParam("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if pageSizeParam := ctx.QueryParam("page_size"); pageSizeParam != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 {
+			pageSize = parsedPageSize
+		}
+	}
+
+	runs, total, err := c.repo.GetRuns(orgID, page, pageSize)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":     runs,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// GetRun handles GET /api/payroll/runs/:id
+func (c *PayrollController) GetRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	run, err := c.repo.GetRun(uint(id), orgID)
+	if err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, run)
+}
+
+// CreateRun handles POST /api/payroll/runs
+func (c *PayrollController) CreateRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	run, err := c.bindRun(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.CreateRun(run, orgID, userID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, run)
+}
+
+// UpdateRun handles PUT /api/payroll/runs/:id. The run is recalculated, so saving it unchanged
+// picks up hours approved since it was created.
+func (c *PayrollController) UpdateRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	run, err := c.bindRun(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	run.ID = uint(id)
+
+	if err := c.repo.UpdateRun(run, orgID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, run)
+}
+
+// DeleteRun handles DELETE /api/payroll/runs/:id
+func (c *PayrollController) DeleteRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := c.repo.DeleteRun(uint(id), orgID); err != nil {
+		return payrollError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// FinalizeRun handles POST /api/payroll/runs/:id/finalize
+func (c *PayrollController) FinalizeRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	run, err := c.repo.FinalizeRun(uint(id), orgID, userID)
+	if err != nil {
+		return payrollError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Finalized payroll run %d for %s to %s",
+		run.ID, run.PeriodStart.Format(dateLayout), run.PeriodEnd.Format(dateLayout)))
+	return ctx.JSON(http.StatusOK, run)
+}
+
+// ExportRun handles GET /api/payroll/runs/:id/export, returning the lines of a finalized run as CSV
+func (c *PayrollController) ExportRun(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	run, err := c.repo.GetRun(uint(id), orgID)
+	if err != nil {
+		return payrollError(ctx, err)
+	}
+	if run.Status != model.PayrollStatusFinalized {
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Only finalized payroll runs can be exported"})
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"worker_id", "worker_name", "position", "rate_type", "rate", "currency",
+		"days_worked", "paid_days", "regular_hours", "overtime_hours", "regular_pay", "overtime_pay", "total"})
+	for _, line := range run.Lines {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(line.WorkerID), 10),
+			csvText(line.WorkerName),
+			csvText(line.Position),
+			line.RateType,
+			formatAmount(line.Rate),
+			line.Currency,
+			strconv.Itoa(line.DaysWorked),
+			strconv.Itoa(line.PaidDays),
+			formatAmount(line.RegularHours),
+			formatAmount(line.OvertimeHours),
+			formatAmount(line.RegularPay),
+			formatAmount(line.OvertimePay),
+			formatAmount(line.Total),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	filename := fmt.Sprintf("payroll-%s-%s.csv", run.PeriodStart.Format(dateLayout), run.PeriodEnd.Format(dateLayout))
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return ctx.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// bindRun binds and validates the period, overtime multiplier and notes of a payroll run
+func (c *PayrollController) bindRun(ctx echo.Context) (*model.PayrollRun, error) {
+	var run model.PayrollRun
+	if err := ctx.Bind(&run); err != nil {
+		return nil, err
+	}
+	run.Lines = nil
+	run.Totals = nil
+
+	if err := c.validate.Struct(run); err != nil {
+		return nil, err
+	}
+
+	if run.PeriodEnd.Sub(run.PeriodStart) >= maxPayPeriodDays*24*time.Hour {
+		return nil, errors.New("the pay period must not exceed 92 days")
+	}
+
+	if run.OvertimeMultiplier == 0 {
+		run.OvertimeMultiplier = defaultOvertimeMultiplier
+	}
+
+	return &run, nil
+}
+
+// formatAmount formats hours and amounts with two decimals
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// csvText keeps spreadsheet applications from reading text cells as formulas
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// payrollError maps payroll repository errors to responses
+func payrollError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrPayRateExists):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The worker already has a pay rate starting on that date"})
+	case errors.Is(err, repository.ErrPayrollLocked):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Finalized payroll runs cannot be changed"})
+	case errors.Is(err, repository.ErrPayrollOverlap):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The pay period overlaps a finalized payroll run"})
+	case errors.Is(err, repository.ErrPayrollMissingRate):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Some workers with approved hours have no pay rate"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker, pay rate or payroll run not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -1,13 +1,17 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ProjectController struct {
@@ -22,10 +26,23 @@ func NewProjectController(repo *repository.ProjectRepository) *ProjectController
 	}
 }
 
+// AssignmentRequest represents the assignment request body. Missing dates leave the assignment
+// open on that side and the allocation defaults to 100 percent. Force saves an assignment that
+// allocates the worker more than 100 percent.
+type AssignmentRequest struct {
+	WorkerId   uint       `json:"workerId"`
+	StartDate  *time.Time `json:"start_date"`
+	EndDate    *time.Time `json:"end_date"`
+	Allocation int        `json:"allocation" validate:"omitempty,min=1,max=100"`
+	Role       string     `json:"role" validate:"max=50"`
+	Notes      string     `json:"notes" validate:"max=500"`
+	Force      bool       `json:"force"`
+}
+
 // GetAllProjects handles GET /api/projects
 func (c *ProjectController) GetAllProjects(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -61,10 +78,13 @@ func (c *ProjectController) GetAllProjects(ctx echo.Context) error {
 		}
 	}
 
-	projects, total, err := c.repo.GetAll(userID, filters, sortBy, sortOrder, page, pageSize)
+	projects, total, err := c.repo.GetAll(orgID, filters, sortBy, sortOrder, page, pageSize)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	for i := range projects {
+		redactSalaries(ctx, projects[i].Workers)
+	}
 
 	// Return paginated response
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
@@ -75,10 +95,11 @@ func (c *ProjectController) GetAllProjects(ctx echo.Context) error {
 	})
 }
 
-// GetProject handles GET /api/projects/:id
+// GetProject handles GET /api/projects/:id. With ?active_on=YYYY-MM-DD only the assignments
+// active on that date are reported.
 func (c *ProjectController) GetProject(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -88,29 +109,45 @@ func (c *ProjectController) GetProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
 	}
 
-	project, err := c.repo.GetByID(uint(id), userID)
+	var activeOn *time.Time
+	if activeOnParam := ctx.QueryParam("active_on"); activeOnParam != "" {
+		date, err := parseDate(activeOnParam)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid active_on date, expected YYYY-MM-DD"})
+		}
+		activeOn = &date
+	}
+
+	project, err := c.repo.GetByID(uint(id), orgID, activeOn)
 	if err != nil {
 		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
 	}
+	redactSalaries(ctx, project.Workers)
+	redactAssignments(ctx, project.Assignments)
 
 	return ctx.JSON(http.StatusOK, project)
 }
 
 // CreateProject handles POST /api/projects
 func (c *ProjectController) CreateProject(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	var project model.Project
 	if err := ctx.Bind(&project); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Set user ID for the project
+	// Set creator and organization for the project
 	project.UserID = userID
+	project.OrganizationID = orgID
 
 	// Validate project
 	if err := c.validate.Struct(project); err != nil {
@@ -126,11 +163,15 @@ func (c *ProjectController) CreateProject(ctx echo.Context) error {
 
 // UpdateProject handles PUT /api/projects/:id
 func (c *ProjectController) UpdateProject(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
@@ -142,11 +183,17 @@ func (c *ProjectController) UpdateProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Set project ID and user ID
+	// Set project ID, the creator and organization are kept by the repository
 	project.ID = uint(id)
-	project.UserID = userID
 
-	if err := c.repo.Update(&project, userID); err != nil {
+	conflicts, err := c.repo.Update(&project, orgID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOverAllocated) {
+			return ctx.JSON(http.StatusConflict, map[string]interface{}{
+				"error":     "Some workers would be allocated more than 100%, assign them through POST /api/projects/:id/workers",
+				"conflicts": conflicts,
+			})
+		}
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -155,8 +202,8 @@ func (c *ProjectController) UpdateProject(ctx echo.Context) error {
 
 // DeleteProject handles DELETE /api/projects/:id
 func (c *ProjectController) DeleteProject(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -166,7 +213,7 @@ func (c *ProjectController) DeleteProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
 	}
 
-	if err := c.repo.Delete(uint(id), userID); err != nil {
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -175,40 +222,147 @@ func (c *ProjectController) DeleteProject(ctx echo.Context) error {
 
 // AssignWorkerToProject handles POST /api/projects/:id/workers
 func (c *ProjectController) AssignWorkerToProject(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	var request struct {
-		WorkerId uint `json:"workerId"`
-	}
-	if err := ctx.Bind(&request); err != nil {
+	request, err := c.bindAssignment(ctx)
+	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if err := c.repo.AddWorker(uint(projectId), request.WorkerId, userID); err != nil {
-		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	assignment := &model.WorkerProject{
+		WorkerID:   request.WorkerId,
+		ProjectID:  uint(projectId),
+		StartDate:  request.StartDate,
+		EndDate:    request.EndDate,
+		Allocation: request.Allocation,
+		Role:       request.Role,
+		Notes:      request.Notes,
+	}
+	conflicts, err := c.repo.AddWorker(assignment, orgID, userID, request.Force)
+	if err != nil {
+		return allocationError(ctx, conflicts, err)
+	}
+	if len(conflicts) > 0 {
+		recordAllocationOverride(ctx, assignment, conflicts)
 	}
 
-	project, err := c.repo.GetByID(uint(projectId), userID)
+	project, err := c.repo.GetByID(uint(projectId), orgID, nil)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	redactSalaries(ctx, project.Workers)
+	redactAssignments(ctx, project.Assignments)
 
 	return ctx.JSON(http.StatusOK, project)
 }
 
-// GetAvailableWorkers handles GET /api/projects/:id/workers/available
+// UpdateAssignment handles PUT /api/projects/:id/workers/:workerId
+func (c *ProjectController) UpdateAssignment(ctx echo.Context) error {
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	workerId, err := strconv.ParseUint(ctx.Param("workerId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	request, err := c.bindAssignment(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	assignment := &model.WorkerProject{
+		WorkerID:   uint(workerId),
+		ProjectID:  uint(projectId),
+		StartDate:  request.StartDate,
+		EndDate:    request.EndDate,
+		Allocation: request.Allocation,
+		Role:       request.Role,
+		Notes:      request.Notes,
+	}
+	conflicts, err := c.repo.UpdateAssignment(assignment, orgID, request.Force)
+	if err != nil {
+		return allocationError(ctx, conflicts, err)
+	}
+	if len(conflicts) > 0 {
+		recordAllocationOverride(ctx, assignment, conflicts)
+	}
+
+	updated, err := c.repo.GetAssignment(uint(projectId), uint(workerId), orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !canReadSalary(ctx) {
+		redactWorker(updated.Worker)
+	}
+
+	return ctx.JSON(http.StatusOK, updated)
+}
+
+// CheckAssignment handles POST /api/projects/:id/workers/check. It reports whether assigning or
+// updating a worker with the given dates and allocation would allocate them more than 100 percent,
+// without saving anything.
+func (c *ProjectController) CheckAssignment(ctx echo.Context) error {
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	request, err := c.bindAssignment(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	assignment := &model.WorkerProject{
+		WorkerID:   request.WorkerId,
+		ProjectID:  uint(projectId),
+		StartDate:  request.StartDate,
+		EndDate:    request.EndDate,
+		Allocation: request.Allocation,
+	}
+	conflicts, err := c.repo.CheckAllocation(assignment, orgID)
+	if err != nil {
+		return assignmentError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"ok":        len(conflicts) == 0,
+		"conflicts": conflicts,
+	})
+}
+
+// GetAvailableWorkers handles GET /api/projects/:id/workers/available. It accepts the filters of
+// GET /api/workers, including availability over a date range and required skills.
 func (c *ProjectController) GetAvailableWorkers(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -218,9 +372,15 @@ func (c *ProjectController) GetAvailableWorkers(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	project, err := c.repo.GetByID(uint(projectId), userID)
+	// Get query parameters for filtering and sorting
+	filters, err := parseWorkerFilters(ctx)
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	sortBy, sortOrder, err := parseWorkerSort(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	// Get pagination parameters
@@ -239,30 +399,19 @@ func (c *ProjectController) GetAvailableWorkers(ctx echo.Context) error {
 		}
 	}
 
-	// Get all workers with pagination
-	workers, total, err := c.repo.GetAllWorkers(userID, page, pageSize)
+	workers, total, err := c.repo.GetAvailableWorkers(uint(projectId), orgID, filters, sortBy, sortOrder, page, pageSize)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
+		}
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// Filter out workers that are already assigned to the project
-	availableWorkers := make([]model.Worker, 0)
-	for _, worker := range workers {
-		isAssigned := false
-		for _, assignedWorker := range project.Workers {
-			if worker.ID == assignedWorker.ID {
-				isAssigned = true
-				break
-			}
-		}
-		if !isAssigned {
-			availableWorkers = append(availableWorkers, worker)
-		}
-	}
+	redactSalaries(ctx, workers)
 
 	// Return paginated response
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
-		"data":     availableWorkers,
+		"data":     workers,
 		"total":    total,
 		"page":     page,
 		"pageSize": pageSize,
@@ -271,8 +420,8 @@ func (c *ProjectController) GetAvailableWorkers(ctx echo.Context) error {
 
 // UnassignWorkerFromProject handles DELETE /api/projects/:id/workers/:workerId
 func (c *ProjectController) UnassignWorkerFromProject(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -287,9 +436,76 @@ func (c *ProjectController) UnassignWorkerFromProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
 	}
 
-	if err := c.repo.RemoveWorker(uint(projectId), uint(workerId), userID); err != nil {
+	if err := c.repo.RemoveWorker(uint(projectId), uint(workerId), orgID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
 	return ctx.NoContent(http.StatusNoContent)
-} 
\ No newline at end of file
+}
+
+// bindAssignment binds and validates an assignment from the request body
+func (c *ProjectController) bindAssignment(ctx echo.Context) (*AssignmentRequest, error) {
+	var request AssignmentRequest
+	if err := ctx.Bind(&request); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate.Struct(request); err != nil {
+		return nil, err
+	}
+
+	if request.StartDate != nil && request.EndDate != nil && request.EndDate.Before(*request.StartDate) {
+		return nil, errors.New("end date must not be before the start date")
+	}
+
+	if request.Allocation == 0 {
+		request.Allocation = 100
+	}
+
+	return &request, nil
+}
+
+// recordAllocationOverride stores a description of a forced over-allocation for the activity log
+func recordAllocationOverride(ctx echo.Context, assignment *model.WorkerProject, conflicts []model.AllocationConflict) {
+	peak := 0
+	for _, conflict := range conflicts {
+		if conflict.Allocation > peak {
+			peak = conflict.Allocation
+		}
+	}
+	ctx.Set("allocation_override", fmt.Sprintf("Over-allocation forced: worker %d on project %d allocated up to %d%% in %d period(s)",
+		assignment.WorkerID, assignment.ProjectID, peak, len(conflicts)))
+}
+
+// redactAssignments zeroes the salaries of the assigned workers for users without the workers:salary:read permission
+func redactAssignments(ctx echo.Context, assignments []model.WorkerProject) {
+	if canReadSalary(ctx) {
+		return
+	}
+	for i := range assignments {
+		redactWorker(assignments[i].Worker)
+	}
+}
+
+// allocationError reports the conflicts of an assignment that would over-allocate its worker,
+// other errors are mapped by assignmentError
+func allocationError(ctx echo.Context, conflicts []model.AllocationConflict, err error) error {
+	if errors.Is(err, repository.ErrOverAllocated) {
+		return ctx.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Worker would be allocated more than 100%",
+			"conflicts": conflicts,
+		})
+	}
+	return assignmentError(ctx, err)
+}
+
+// assignmentError maps assignment repository errors to responses
+func assignmentError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrAlreadyAssigned):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Worker is already assigned to the project"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project, worker or assignment not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/notify"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type RegistrationController interface {
+	GetRegistrationSettings(c echo.Context) error
+	UpdateRegistrationSettings(c echo.Context) error
+	GetInvites(c echo.Context) error
+	CreateInvite(c echo.Context) error
+	RevokeInvite(c echo.Context) error
+}
+
+type registrationController struct {
+	registrationRepo *repository.RegistrationRepository
+	settingRepo      *repository.SettingRepository
+	userRepo         repository.UserRepository
+	roleRepo         *repository.RoleRepository
+	notifier         notify.Notifier
+	validate         *validator.Validate
+}
+
+func NewRegistrationController(registrationRepo *repository.RegistrationRepository, settingRepo *repository.SettingRepository, userRepo repository.UserRepository, roleRepo *repository.RoleRepository, notifier notify.Notifier) RegistrationController {
+	return &registrationController{
+		registrationRepo: registrationRepo,
+		settingRepo:      settingRepo,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		notifier:         notifier,
+		validate:         validator.New(),
+	}
+}
+
+// CreateInviteRequest represents the registration invite request body
+type CreateInviteRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role"` // Defaults to the user role, can never be admin
+}
+
+// registrationMode returns the current registration mode. Admins can change it at runtime,
+// otherwise REGISTRATION_MODE applies, open by default.
+func registrationMode(settingRepo *repository.SettingRepository) string {
+	mode, _ := settingRepo.Get(model.SettingRegistrationMode, os.Getenv("REGISTRATION_MODE"))
+	switch mode {
+	case model.RegistrationInviteOnly, model.RegistrationDisabled:
+		return mode
+	default:
+		return model.RegistrationOpen
+	}
+}
+
+// GetRegistrationSettings returns the registration mode
+func (c *registrationController) GetRegistrationSettings(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"mode": registrationMode(c.settingRepo),
+	})
+}
+
+// UpdateRegistrationSettings changes the registration mode
+func (c *registrationController) UpdateRegistrationSettings(ctx echo.Context) error {
+	// Parse request body
+	var req struct {
+		Mode string `json:"mode" validate:"required,oneof=open invite_only disabled"`
+	}
+
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid mode. Must be 'open', 'invite_only' or 'disabled'")
+	}
+
+	if err := c.settingRepo.Set(model.SettingRegistrationMode, req.Mode); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update registration settings")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Registration settings updated successfully",
+		"mode":    req.Mode,
+	})
+}
+
+// GetInvites returns all registration invites
+func (c *registrationController) GetInvites(ctx echo.Context) error {
+	invites, err := c.registrationRepo.GetInvites()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch invites")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": invites,
+	})
+}
+
+// CreateInvite invites an email address to register and sends the invite link
+func (c *registrationController) CreateInvite(ctx echo.Context) error {
+	adminID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req CreateInviteRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "A valid email is required")
+	}
+
+	// Admins are only ever promoted explicitly, never through registration
+	if req.Role == "" {
+		req.Role = model.RoleUser
+	}
+	if req.Role == model.RoleAdmin {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invites cannot grant the admin role")
+	}
+	if exists, err := c.roleRepo.Exists(req.Role); err != nil || !exists {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
+	}
+
+	// Invites must not grant permissions the inviter lacks
+	inviter, err := c.userRepo.GetUserByID(adminID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User not authenticated")
+	}
+	allowed, err := grantsSubset(c.roleRepo, req.Role, inviter.Role)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve role permissions")
+	}
+	if !allowed {
+		return echo.NewHTTPError(http.StatusForbidden, "Invites cannot grant permissions you do not have")
+	}
+
+	if existing, err := c.userRepo.GetUserByEmail(req.Email); err == nil && existing != nil {
+		return echo.NewHTTPError(http.StatusConflict, "Email already registered")
+	}
+
+	// Generate the invite token, only its hash is stored
+	plainToken, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate invite")
+	}
+
+	invite := &model.RegistrationInvite{
+		Email:       req.Email,
+		Role:        req.Role,
+		TokenHash:   auth.HashToken(plainToken),
+		InvitedByID: adminID,
+		ExpiresAt:   time.Now().Add(auth.InviteTTL()),
+	}
+	if err := c.registrationRepo.CreateInvite(invite); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create invite")
+	}
+
+	// Deliver the invite
+	registerURL := os.Getenv("REGISTRATION_URL")
+	if registerURL == "" {
+		registerURL = "http://localhost:5173/register"
+	}
+	err = c.notifier.Send(notify.Message{
+		To:      invite.Email,
+		Subject: "You have been invited to Worksite Management Studio",
+		Body: fmt.Sprintf("Use the following link to create your account: %s?invite=%s\nThe link expires at %s.",
+			registerURL, plainToken, invite.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send invite")
+	}
+
+	return ctx.JSON(http.StatusCreated, invite)
+}
+
+// RevokeInvite revokes a pending registration invite
+func (c *registrationController) RevokeInvite(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invite ID")
+	}
+
+	if err := c.registrationRepo.RevokeInvite(uint(id)); err != nil {
+		if errors.Is(err, repository.ErrInviteInvalid) {
+			return echo.NewHTTPError(http.StatusNotFound, "Pending invite not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke invite")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type SessionController interface {
+	GetMySessions(c echo.Context) error
+	RevokeMySession(c echo.Context) error
+	RevokeMyOtherSessions(c echo.Context) error
+	GetUserSessions(c echo.Context) error
+	RevokeUserSession(c echo.Context) error
+	RevokeAllUserSessions(c echo.Context) error
+}
+
+type sessionController struct {
+	sessionRepo *repository.SessionRepository
+}
+
+func NewSessionController(sessionRepo *repository.SessionRepository) SessionController {
+	return &sessionController{
+		sessionRepo: sessionRepo,
+	}
+}
+
+// GetMySessions handles GET /api/auth/sessions
+func (c *sessionController) GetMySessions(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := c.sessionRepo.GetUserSessions(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions")
+	}
+
+	// Mark the session used for this request
+	currentID, _ := ctx.Get("session_id").(string)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": sessions,
+	})
+}
+
+// RevokeMySession handles DELETE /api/auth/sessions/:id
+func (c *sessionController) RevokeMySession(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.sessionRepo.RevokeUserSession(userID, ctx.Param("id")); err != nil {
+		return sessionError(err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// RevokeMyOtherSessions handles DELETE /api/auth/sessions, signing out every other device
+func (c *sessionController) RevokeMyOtherSessions(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	currentID, _ := ctx.Get("session_id").(string)
+	if err := c.sessionRepo.RevokeOtherSessions(userID, currentID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetUserSessions handles GET /api/admin/users/:id/sessions
+func (c *sessionController) GetUserSessions(ctx echo.Context) error {
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	sessions, err := c.sessionRepo.GetUserSessions(uint(userID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": sessions,
+	})
+}
+
+// RevokeUserSession handles DELETE /api/admin/users/:id/sessions/:sessionId
+func (c *sessionController) RevokeUserSession(ctx echo.Context) error {
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := c.sessionRepo.RevokeUserSession(uint(userID), ctx.Param("sessionId")); err != nil {
+		return sessionError(err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// RevokeAllUserSessions handles DELETE /api/admin/users/:id/sessions
+func (c *sessionController) RevokeAllUserSessions(ctx echo.Context) error {
+	userID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := c.sessionRepo.RevokeUserSessions(uint(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// sessionError maps session repository errors to HTTP errors
+func sessionError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke session")
+}
@@ -0,0 +1,504 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// defaultMinRestHours is the minimum rest time between two shifts of a worker unless an admin changes it
+const defaultMinRestHours = 11
+
+type ShiftController struct {
+	repo        *repository.ShiftRepository
+	settingRepo *repository.SettingRepository
+	validate    *validator.Validate
+}
+
+func NewShiftController(repo *repository.ShiftRepository, settingRepo *repository.SettingRepository) *ShiftController {
+	return &ShiftController{
+		repo:        repo,
+		settingRepo: settingRepo,
+		validate:    validator.New(),
+	}
+}
+
+// CopyWeekRequest copies the shifts of the previous week into a week
+type CopyWeekRequest struct {
+	WeekStart time.Time `json:"week_start" validate:"required"` // Any day of the week, moved to its Monday
+}
+
+// ShiftSettingsRequest changes the shift settings
+type ShiftSettingsRequest struct {
+	MinRestHours *float64 `json:"min_rest_hours" validate:"required,min=0,max=24"`
+}
+
+// GetTemplates handles GET /api/projects/:id/shift-templates
+func (c *ShiftController) GetTemplates(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	templates, err := c.repo.GetTemplates(uint(projectId), orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": templates,
+	})
+}
+
+// CreateTemplate handles POST /api/projects/:id/shift-templates
+func (c *ShiftController) CreateTemplate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	template, err := c.bindTemplate(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	template.ProjectID = uint(projectId)
+
+	if err := c.repo.CreateTemplate(template, orgID); err != nil {
+		return shiftError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate handles PUT /api/projects/:id/shift-templates/:templateId
+func (c *ShiftController) UpdateTemplate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	templateId, err := strconv.ParseUint(ctx.Param("templateId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+	}
+
+	template, err := c.bindTemplate(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	template.ID = uint(templateId)
+	template.ProjectID = uint(projectId)
+
+	if err := c.repo.UpdateTemplate(template, orgID); err != nil {
+		return shiftError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles DELETE /api/projects/:id/shift-templates/:templateId
+func (c *ShiftController) DeleteTemplate(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	templateId, err := strconv.ParseUint(ctx.Param("templateId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid template ID"})
+	}
+
+	if err := c.repo.DeleteTemplate(uint(projectId), uint(templateId), orgID); err != nil {
+		return shiftError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetProjectRoster handles GET /api/projects/:id/shifts?week=2024-03-04. The week is the one
+// containing the given date, the current week by default.
+func (c *ShiftController) GetProjectRoster(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	weekStart, err := rosterWeek(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	shifts, err := c.repo.GetProjectRoster(uint(projectId), orgID, weekStart)
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	redactShifts(ctx, shifts)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":       shifts,
+		"week_start": weekStart.Format(dateLayout),
+	})
+}
+
+// GetWorkerRoster handles GET /api/workers/:id/shifts?week=2024-03-04
+func (c *ShiftController) GetWorkerRoster(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	weekStart, err := rosterWeek(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	shifts, err := c.repo.GetWorkerRoster(uint(workerId), orgID, weekStart)
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	redactShifts(ctx, shifts)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":       shifts,
+		"week_start": weekStart.Format(dateLayout),
+	})
+}
+
+// GetShift handles GET /api/projects/:id/shifts/:shiftId
+func (c *ShiftController) GetShift(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	shiftId, err := strconv.ParseUint(ctx.Param("shiftId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid shift ID"})
+	}
+
+	shift, err := c.repo.GetShift(uint(projectId), uint(shiftId), orgID)
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	redactShifts(ctx, []model.Shift{*shift})
+
+	return ctx.JSON(http.StatusOK, shift)
+}
+
+// CreateShift handles POST /api/projects/:id/shifts. Workers with conflicts are reported with 409
+// and the shift is not scheduled.
+func (c *ShiftController) CreateShift(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	shift, err := c.bindShift(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	shift.ProjectID = uint(projectId)
+
+	conflicts, err := c.repo.CreateShift(shift, orgID, userID, c.minRest())
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	if len(conflicts) > 0 {
+		return ctx.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Some workers cannot work this shift",
+			"conflicts": conflicts,
+		})
+	}
+	shift.Unfilled = shift.UnfilledPositions()
+
+	return ctx.JSON(http.StatusCreated, shift)
+}
+
+// UpdateShift handles PUT /api/projects/:id/shifts/:shiftId
+func (c *ShiftController) UpdateShift(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	shiftId, err := strconv.ParseUint(ctx.Param("shiftId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid shift ID"})
+	}
+
+	shift, err := c.bindShift(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	shift.ID = uint(shiftId)
+	shift.ProjectID = uint(projectId)
+
+	conflicts, err := c.repo.UpdateShift(shift, orgID, c.minRest())
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	if len(conflicts) > 0 {
+		return ctx.JSON(http.StatusConflict, map[string]interface{}{
+			"error":     "Some workers cannot work this shift",
+			"conflicts": conflicts,
+		})
+	}
+	shift.Unfilled = shift.UnfilledPositions()
+
+	return ctx.JSON(http.StatusOK, shift)
+}
+
+// DeleteShift handles DELETE /api/projects/:id/shifts/:shiftId
+func (c *ShiftController) DeleteShift(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	shiftId, err := strconv.ParseUint(ctx.Param("shiftId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid shift ID"})
+	}
+
+	if err := c.repo.DeleteShift(uint(projectId), uint(shiftId), orgID); err != nil {
+		return shiftError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// CopyWeek handles POST /api/projects/:id/shifts/copy-week. The shifts of the previous week are
+// copied into the given week, leaving off workers who can no longer work them.
+func (c *ShiftController) CopyWeek(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	var req CopyWeekRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	weekStart := startOfWeek(req.WeekStart)
+	shifts, skipped, err := c.repo.CopyWeek(uint(projectId), orgID, userID, weekStart, c.minRest())
+	if err != nil {
+		return shiftError(ctx, err)
+	}
+	redactShifts(ctx, shifts)
+
+	return ctx.JSON(http.StatusCreated, map[string]interface{}{
+		"data":       shifts,
+		"skipped":    skipped,
+		"week_start": weekStart.Format(dateLayout),
+	})
+}
+
+// GetShiftSettings handles GET /api/admin/settings/shifts
+func (c *ShiftController) GetShiftSettings(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"min_rest_hours": c.minRest().Hours(),
+	})
+}
+
+// UpdateShiftSettings handles PUT /api/admin/settings/shifts
+func (c *ShiftController) UpdateShiftSettings(ctx echo.Context) error {
+	var req ShiftSettingsRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "The minimum rest time must be between 0 and 24 hours"})
+	}
+
+	value := strconv.FormatFloat(*req.MinRestHours, 'f', -1, 64)
+	if err := c.settingRepo.Set(model.SettingShiftMinRestHours, value); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update shift settings"})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"message":        "Shift settings updated successfully",
+		"min_rest_hours": *req.MinRestHours,
+	})
+}
+
+// minRest returns the minimum rest time between two shifts of a worker
+func (c *ShiftController) minRest() time.Duration {
+	value, _ := c.settingRepo.Get(model.SettingShiftMinRestHours, "")
+	hours, err := strconv.ParseFloat(value, 64)
+	if err != nil || hours < 0 {
+		hours = defaultMinRestHours
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// bindTemplate binds and validates a shift template from the request body
+func (c *ShiftController) bindTemplate(ctx echo.Context) (*model.ShiftTemplate, error) {
+	var template model.ShiftTemplate
+	if err := ctx.Bind(&template); err != nil {
+		return nil, err
+	}
+	if template.Positions == nil {
+		template.Positions = []model.ShiftPosition{}
+	}
+
+	if err := c.validate.Struct(template); err != nil {
+		return nil, err
+	}
+
+	if template.StartTime == template.EndTime {
+		return nil, errors.New("a shift cannot start and end at the same time")
+	}
+
+	return &template, nil
+}
+
+// bindShift binds and validates a shift and its workers from the request body
+func (c *ShiftController) bindShift(ctx echo.Context) (*model.Shift, error) {
+	var shift model.Shift
+	if err := ctx.Bind(&shift); err != nil {
+		return nil, err
+	}
+	shift.Project = nil
+	shift.Unfilled = nil
+	for i := range shift.Workers {
+		shift.Workers[i].Worker = nil
+	}
+	if shift.Positions == nil {
+		shift.Positions = []model.ShiftPosition{}
+	}
+	if shift.Workers == nil {
+		shift.Workers = []model.ShiftWorker{}
+	}
+
+	if err := c.validate.Struct(shift); err != nil {
+		return nil, err
+	}
+
+	if (shift.StartTime == "") != (shift.EndTime == "") {
+		return nil, errors.New("start and end time must be given together")
+	}
+	if shift.StartTime != "" && shift.StartTime == shift.EndTime {
+		return nil, errors.New("a shift cannot start and end at the same time")
+	}
+
+	return &shift, nil
+}
+
+// rosterWeek returns the Monday of the week requested with the "week" query parameter, the current week by default
+func rosterWeek(ctx echo.Context) (time.Time, error) {
+	now := time.Now().UTC()
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if weekParam := ctx.QueryParam("week"); weekParam != "" {
+		parsed, err := parseDate(weekParam)
+		if err != nil {
+			return time.Time{}, errors.New("invalid week, expected YYYY-MM-DD")
+		}
+		date = parsed
+	}
+	return startOfWeek(date), nil
+}
+
+// redactShifts zeroes the salaries of the shifts' workers for users without the workers:salary:read permission
+func redactShifts(ctx echo.Context, shifts []model.Shift) {
+	if canReadSalary(ctx) {
+		return
+	}
+	for i := range shifts {
+		for j := range shifts[i].Workers {
+			redactWorker(shifts[i].Workers[j].Worker)
+		}
+	}
+}
+
+// shiftError maps shift repository errors to responses
+func shiftError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrShiftTimes):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Start and end time are required when the shift has no template"})
+	case errors.Is(err, repository.ErrShiftWorkerTwice):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Each worker can only be listed once"})
+	case errors.Is(err, repository.ErrShiftWeekNotEmpty):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The week already has shifts"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project, worker, shift or template not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
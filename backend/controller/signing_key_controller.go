@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type SigningKeyController interface {
+	JWKS(c echo.Context) error
+	GetSigningKeys(c echo.Context) error
+	RotateSigningKeys(c echo.Context) error
+}
+
+type signingKeyController struct {
+	keyRepo *repository.SigningKeyRepository
+}
+
+func NewSigningKeyController(keyRepo *repository.SigningKeyRepository) SigningKeyController {
+	return &signingKeyController{
+		keyRepo: keyRepo,
+	}
+}
+
+// JWKS handles GET /.well-known/jwks.json, publishing the keys that verify access tokens
+func (c *signingKeyController) JWKS(ctx echo.Context) error {
+	set, err := auth.PublicKeySet()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load signing keys")
+	}
+
+	// Verifiers should refetch on an unknown key ID, so a short cache is enough
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, set)
+}
+
+// GetSigningKeys handles GET /api/admin/signing-keys
+func (c *signingKeyController) GetSigningKeys(ctx echo.Context) error {
+	keys, err := c.keyRepo.GetSigningKeys(time.Now().Add(-auth.KeyRetention()))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch signing keys")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"algorithm": auth.SigningAlgorithm(),
+		"data":      keys,
+	})
+}
+
+// RotateSigningKeys handles POST /api/admin/signing-keys/rotate.
+// Tokens signed with the previous key stay valid until they expire.
+func (c *signingKeyController) RotateSigningKeys(ctx echo.Context) error {
+	key, err := auth.RotateSigningKeys()
+	if err != nil {
+		if errors.Is(err, auth.ErrRotationUnsupported) {
+			return echo.NewHTTPError(http.StatusConflict, "Key rotation requires JWT_SIGNING_ALG RS256 or EdDSA")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to rotate signing keys")
+	}
+
+	// Drop keys that can no longer verify any valid token
+	c.keyRepo.DeleteRetiredBefore(time.Now().Add(-auth.KeyRetention()))
+
+	return ctx.JSON(http.StatusCreated, key)
+}
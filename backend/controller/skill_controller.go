@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxExpiryWindowDays limits how far ahead expiring certifications can be listed
+const maxExpiryWindowDays = 365
+
+type SkillController struct {
+	repo     *repository.SkillRepository
+	validate *validator.Validate
+}
+
+func NewSkillController(repo *repository.SkillRepository) *SkillController {
+	return &SkillController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// GetSkills handles GET /api/skills
+func (c *SkillController) GetSkills(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	skills, err := c.repo.GetAll(orgID, ctx.QueryParam("kind"))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": skills,
+	})
+}
+
+// CreateSkill handles POST /api/skills
+func (c *SkillController) CreateSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var skill model.Skill
+	if err := ctx.Bind(&skill); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	skill.ID = 0
+	skill.OrganizationID = orgID
+
+	if err := c.validate.Struct(skill); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.Create(&skill); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, skill)
+}
+
+// UpdateSkill handles PUT /api/skills/:id
+func (c *SkillController) UpdateSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	var skill model.Skill
+	if err := ctx.Bind(&skill); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	skill.ID = uint(id)
+	skill.OrganizationID = orgID
+
+	if err := c.validate.Struct(skill); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.Update(&skill, orgID); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, skill)
+}
+
+// DeleteSkill handles DELETE /api/skills/:id
+func (c *SkillController) DeleteSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetWorkerSkills handles GET /api/workers/:id/skills
+func (c *SkillController) GetWorkerSkills(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	skills, err := c.repo.GetWorkerSkills(uint(workerID), orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": skills,
+	})
+}
+
+// AddWorkerSkill handles POST /api/workers/:id/skills
+func (c *SkillController) AddWorkerSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	workerSkill, err := c.bindWorkerSkill(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	workerSkill.ID = 0
+	workerSkill.WorkerID = uint(workerID)
+
+	if err := c.repo.AddWorkerSkill(workerSkill, orgID); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, workerSkill)
+}
+
+// UpdateWorkerSkill handles PUT /api/workers/:id/skills/:skillId
+func (c *SkillController) UpdateWorkerSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("skillId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker skill ID"})
+	}
+
+	workerSkill, err := c.bindWorkerSkill(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	workerSkill.ID = uint(id)
+	workerSkill.WorkerID = uint(workerID)
+
+	if err := c.repo.UpdateWorkerSkill(workerSkill, orgID); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, workerSkill)
+}
+
+// RemoveWorkerSkill handles DELETE /api/workers/:id/skills/:skillId
+func (c *SkillController) RemoveWorkerSkill(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	workerID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("skillId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid worker skill ID"})
+	}
+
+	if err := c.repo.RemoveWorkerSkill(uint(workerID), uint(id), orgID); err != nil {
+		return skillError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetExpiringCertifications handles GET /api/workers/certifications/expiring?days=30.
+// Certifications that already expired are included with ?include_expired=true.
+func (c *SkillController) GetExpiringCertifications(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	days := 30
+	if daysParam := ctx.QueryParam("days"); daysParam != "" {
+		parsedDays, err := strconv.Atoi(daysParam)
+		if err != nil || parsedDays < 0 || parsedDays > maxExpiryWindowDays {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Days must be between 0 and 365"})
+		}
+		days = parsedDays
+	}
+
+	// Whole days: everything expiring from today up to and including the last day
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today
+	if ctx.QueryParam("include_expired") == "true" {
+		from = time.Time{}
+	}
+
+	skills, err := c.repo.GetExpiring(orgID, from, today.AddDate(0, 0, days+1))
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	redactWorkerSkills(ctx, skills)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": skills,
+		"days": days,
+	})
+}
+
+// bindWorkerSkill binds and validates a worker skill from the request body
+func (c *SkillController) bindWorkerSkill(ctx echo.Context) (*model.WorkerSkill, error) {
+	var workerSkill model.WorkerSkill
+	if err := ctx.Bind(&workerSkill); err != nil {
+		return nil, err
+	}
+	workerSkill.Skill = nil
+	workerSkill.Worker = nil
+
+	if err := c.validate.Struct(workerSkill); err != nil {
+		return nil, err
+	}
+
+	if workerSkill.IssueDate != nil && workerSkill.ExpiryDate != nil && workerSkill.ExpiryDate.Before(*workerSkill.IssueDate) {
+		return nil, errors.New("expiry date must not be before the issue date")
+	}
+
+	return &workerSkill, nil
+}
+
+// redactWorkerSkills zeroes the salaries of the skills' workers for users without the workers:salary:read permission
+func redactWorkerSkills(ctx echo.Context, skills []model.WorkerSkill) {
+	if canReadSalary(ctx) {
+		return
+	}
+	for i := range skills {
+		redactWorker(skills[i].Worker)
+	}
+}
+
+// skillError maps skill repository errors to responses
+func skillError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrSkillExists):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "A skill with this name already exists"})
+	case errors.Is(err, repository.ErrSkillInUse):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The skill is still held by workers"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker or skill not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
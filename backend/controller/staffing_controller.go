@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Number of weeks covered by a staffing gap report
+const (
+	defaultGapWeeks = 4
+	maxGapWeeks     = 26
+)
+
+type StaffingController struct {
+	repo     *repository.StaffingRepository
+	validate *validator.Validate
+}
+
+func NewStaffingController(repo *repository.StaffingRepository) *StaffingController {
+	return &StaffingController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// GetRequirements handles GET /api/projects/:id/requirements
+func (c *StaffingController) GetRequirements(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	requirements, err := c.repo.GetRequirements(uint(projectId), orgID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": requirements,
+	})
+}
+
+// CreateRequirement handles POST /api/projects/:id/requirements
+func (c *StaffingController) CreateRequirement(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	requirement, err := c.bindRequirement(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	requirement.ID = 0
+	requirement.ProjectID = uint(projectId)
+
+	if err := c.repo.CreateRequirement(requirement, orgID); err != nil {
+		return requirementError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, requirement)
+}
+
+// UpdateRequirement handles PUT /api/projects/:id/requirements/:requirementId
+func (c *StaffingController) UpdateRequirement(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("requirementId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid requirement ID"})
+	}
+
+	requirement, err := c.bindRequirement(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	requirement.ID = uint(id)
+	requirement.ProjectID = uint(projectId)
+
+	if err := c.repo.UpdateRequirement(requirement, orgID); err != nil {
+		return requirementError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, requirement)
+}
+
+// DeleteRequirement handles DELETE /api/projects/:id/requirements/:requirementId
+func (c *StaffingController) DeleteRequirement(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("requirementId"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid requirement ID"})
+	}
+
+	if err := c.repo.DeleteRequirement(uint(projectId), uint(id), orgID); err != nil {
+		return requirementError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetAllGaps handles GET /api/projects/staffing/gaps?from=2024-03-04&weeks=4
+func (c *StaffingController) GetAllGaps(ctx echo.Context) error {
+	return c.getGaps(ctx, 0)
+}
+
+// GetProjectGaps handles GET /api/projects/:id/staffing/gaps?from=2024-03-04&weeks=4
+func (c *StaffingController) GetProjectGaps(ctx echo.Context) error {
+	projectId, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+	}
+	return c.getGaps(ctx, uint(projectId))
+}
+
+// getGaps reports the weekly staffing shortfalls and surpluses of one or all projects.
+// Weeks start on Monday, the week containing from is the first one (the current week by default).
+func (c *StaffingController) getGaps(ctx echo.Context, projectID uint) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if fromParam := ctx.QueryParam("from"); fromParam != "" {
+		from, err = parseDate(fromParam)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid from date, expected YYYY-MM-DD"})
+		}
+	}
+	from = startOfWeek(from)
+
+	weeks := defaultGapWeeks
+	if weeksParam := ctx.QueryParam("weeks"); weeksParam != "" {
+		parsedWeeks, err := strconv.Atoi(weeksParam)
+		if err != nil || parsedWeeks < 1 || parsedWeeks > maxGapWeeks {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Weeks must be between 1 and 26"})
+		}
+		weeks = parsedWeeks
+	}
+
+	report, err := c.repo.GetGaps(orgID, projectID, from, weeks)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":  report,
+		"from":  from.Format(dateLayout),
+		"weeks": weeks,
+	})
+}
+
+// bindRequirement binds and validates a staffing requirement from the request body
+func (c *StaffingController) bindRequirement(ctx echo.Context) (*model.StaffingRequirement, error) {
+	var requirement model.StaffingRequirement
+	if err := ctx.Bind(&requirement); err != nil {
+		return nil, err
+	}
+	requirement.Skill = nil
+
+	if err := c.validate.Struct(requirement); err != nil {
+		return nil, err
+	}
+
+	if (requirement.Position == "") == (requirement.SkillID == nil) {
+		return nil, errors.New("either a position or a skill is required")
+	}
+
+	return &requirement, nil
+}
+
+// startOfWeek returns the Monday of the week containing date
+func startOfWeek(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+// requirementError maps staffing repository errors to responses
+func requirementError(ctx echo.Context, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Project, requirement or skill not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,403 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TimesheetController struct {
+	repo     *repository.TimesheetRepository
+	validate *validator.Validate
+}
+
+func NewTimesheetController(repo *repository.TimesheetRepository) *TimesheetController {
+	return &TimesheetController{
+		repo:     repo,
+		validate: validator.New(),
+	}
+}
+
+// WeeklyHours holds the hours of one worker from Monday to Sunday
+type WeeklyHours struct {
+	WorkerID      uint       `json:"worker_id" validate:"required"`
+	RegularHours  [7]float64 `json:"regular_hours" validate:"dive,min=0,max=24"`
+	OvertimeHours [7]float64 `json:"overtime_hours" validate:"dive,min=0,max=24"`
+	Notes         string     `json:"notes" validate:"max=500"`
+}
+
+// WeeklyTimesheetRequest records the hours of a crew on a project during one week
+type WeeklyTimesheetRequest struct {
+	ProjectID uint          `json:"project_id" validate:"required"`
+	WeekStart time.Time     `json:"week_start" validate:"required"` // Any day of the week, moved to its Monday
+	Workers   []WeeklyHours `json:"workers" validate:"required,min=1,max=200,dive"`
+}
+
+// TimesheetReviewRequest submits, approves or rejects timesheet entries
+type TimesheetReviewRequest struct {
+	IDs    []uint `json:"ids" validate:"required,min=1,max=500"`
+	Reason string `json:"reason" validate:"max=255"` // Required when rejecting
+}
+
+// GetTimesheets handles GET /api/timesheets?worker_id=1&project_id=2&status=submitted&from=2024-03-04&until=2024-03-10
+func (c *TimesheetController) GetTimesheets(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	filters := make(map[string]interface{})
+	for _, key := range []string{"worker_id", "project_id"} {
+		if value := ctx.QueryParam(key); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid " + key})
+			}
+			filters[key] = uint(id)
+		}
+	}
+	if status := ctx.QueryParam("status"); status != "" {
+		filters["status"] = status
+	}
+	for _, key := range []string{"from", "until"} {
+		if value := ctx.QueryParam(key); value != "" {
+			date, err := parseDate(value)
+			if err != nil {
+				return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+			}
+			filters[key] = date
+		}
+	}
+
+	// Handle pagination
+	page := 1
+	pageSize := 50
+
+	if pageParam := ctx.QueryParam("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	if pageSizeParam := ctx.QueryParam("page_size"); pageSizeParam != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 {
+			pageSize = parsedPageSize
+		}
+	}
+
+	entries, total, err := c.repo.GetAll(orgID, filters, page, pageSize)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	redactTimesheets(ctx, entries)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":     entries,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// GetTimesheet handles GET /api/timesheets/:id
+func (c *TimesheetController) GetTimesheet(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	entry, err := c.repo.GetByID(uint(id), orgID)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+	if !canReadSalary(ctx) {
+		redactWorker(entry.Worker)
+	}
+
+	return ctx.JSON(http.StatusOK, entry)
+}
+
+// CreateTimesheet handles POST /api/timesheets
+func (c *TimesheetController) CreateTimesheet(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry, err := c.bindTimesheet(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.repo.Create(entry, orgID, userID); err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, entry)
+}
+
+// UpdateTimesheet handles PUT /api/timesheets/:id
+func (c *TimesheetController) UpdateTimesheet(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	entry, err := c.bindTimesheet(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	entry.ID = uint(id)
+
+	if err := c.repo.Update(entry, orgID); err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, entry)
+}
+
+// DeleteTimesheet handles DELETE /api/timesheets/:id
+func (c *TimesheetController) DeleteTimesheet(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// SaveWeek handles POST /api/timesheets/weekly. Every worker of the crew gets one draft entry per
+// day with hours, days set to zero remove an existing draft entry.
+func (c *TimesheetController) SaveWeek(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var req WeeklyTimesheetRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	weekStart := startOfWeek(req.WeekStart)
+	seen := make(map[uint]bool, len(req.Workers))
+	entries := make([]model.TimesheetEntry, 0, len(req.Workers)*7)
+	for _, hours := range req.Workers {
+		if seen[hours.WorkerID] {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Each worker can only be listed once"})
+		}
+		seen[hours.WorkerID] = true
+
+		for day := 0; day < 7; day++ {
+			entries = append(entries, model.TimesheetEntry{
+				WorkerID:      hours.WorkerID,
+				ProjectID:     req.ProjectID,
+				Date:          weekStart.AddDate(0, 0, day),
+				RegularHours:  hours.RegularHours[day],
+				OvertimeHours: hours.OvertimeHours[day],
+				Notes:         hours.Notes,
+			})
+		}
+	}
+
+	saved, err := c.repo.SaveWeek(entries, orgID, userID)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data":       saved,
+		"week_start": weekStart.Format(dateLayout),
+	})
+}
+
+// SubmitTimesheets handles POST /api/timesheets/submit
+func (c *TimesheetController) SubmitTimesheets(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.bindReview(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	entries, err := c.repo.Submit(req.IDs, orgID)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Submitted %d timesheet entries %v", len(req.IDs), req.IDs))
+	redactTimesheets(ctx, entries)
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": entries,
+	})
+}
+
+// ApproveTimesheets handles POST /api/timesheets/approve
+func (c *TimesheetController) ApproveTimesheets(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.bindReview(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	entries, err := c.repo.Approve(req.IDs, orgID, userID)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Approved %d timesheet entries %v", len(req.IDs), req.IDs))
+	redactTimesheets(ctx, entries)
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": entries,
+	})
+}
+
+// RejectTimesheets handles POST /api/timesheets/reject
+func (c *TimesheetController) RejectTimesheets(ctx echo.Context) error {
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.bindReview(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Reason == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "A reason is required to reject timesheet entries"})
+	}
+
+	entries, err := c.repo.Reject(req.IDs, orgID, userID, req.Reason)
+	if err != nil {
+		return timesheetError(ctx, err)
+	}
+
+	ctx.Set("activity_description", fmt.Sprintf("Rejected %d timesheet entries %v: %s", len(req.IDs), req.IDs, req.Reason))
+	redactTimesheets(ctx, entries)
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": entries,
+	})
+}
+
+// bindTimesheet binds and validates a timesheet entry from the request body
+func (c *TimesheetController) bindTimesheet(ctx echo.Context) (*model.TimesheetEntry, error) {
+	var entry model.TimesheetEntry
+	if err := ctx.Bind(&entry); err != nil {
+		return nil, err
+	}
+	entry.Worker = nil
+	entry.Project = nil
+
+	if err := c.validate.Struct(entry); err != nil {
+		return nil, err
+	}
+
+	if entry.RegularHours+entry.OvertimeHours == 0 {
+		return nil, errors.New("regular or overtime hours are required")
+	}
+
+	return &entry, nil
+}
+
+// bindReview binds and validates a timesheet review from the request body
+func (c *TimesheetController) bindReview(ctx echo.Context) (*TimesheetReviewRequest, error) {
+	var req TimesheetReviewRequest
+	if err := ctx.Bind(&req); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate.Struct(req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// redactTimesheets zeroes the salaries of the entries' workers for users without the workers:salary:read permission
+func redactTimesheets(ctx echo.Context, entries []model.TimesheetEntry) {
+	if canReadSalary(ctx) {
+		return
+	}
+	for i := range entries {
+		redactWorker(entries[i].Worker)
+	}
+}
+
+// timesheetError maps timesheet repository errors to responses
+func timesheetError(ctx echo.Context, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotAssigned):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Worker is not assigned to the project on that date"})
+	case errors.Is(err, repository.ErrTooManyHours):
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Worker would record more than 24 hours on one day"})
+	case errors.Is(err, repository.ErrTimesheetExists):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "The worker already has an entry for the project on that date"})
+	case errors.Is(err, repository.ErrTimesheetLocked):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Submitted or approved entries cannot be changed"})
+	case errors.Is(err, repository.ErrPayPeriodFinalized):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Some entries are in the period of a finalized payroll run"})
+	case errors.Is(err, repository.ErrTimesheetStatus):
+		return ctx.JSON(http.StatusConflict, map[string]string{"error": "Some entries are not in a state that allows this action"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Timesheet entry not found"})
+	}
+	return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
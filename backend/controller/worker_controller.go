@@ -1,13 +1,18 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type WorkerController struct {
@@ -31,15 +36,69 @@ func getUserID(c echo.Context) (uint, error) {
 	return userID, nil
 }
 
-// GetAllWorkers handles GET /api/workers
-func (c *WorkerController) GetAllWorkers(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
-	if err != nil {
-		return err
+// getOrganizationID extracts the organization ID from the context (set by OrganizationScope)
+func getOrganizationID(c echo.Context) (uint, error) {
+	orgID, ok := c.Get("organization_id").(uint)
+	if !ok {
+		return 0, echo.NewHTTPError(http.StatusForbidden, "No organization selected")
 	}
+	return orgID, nil
+}
 
-	// Get query parameters for filtering and sorting
+// canReadSalary reports whether the authenticated user may see worker salaries
+func canReadSalary(c echo.Context) bool {
+	return auth.HasPermission(c, model.PermWorkersSalaryRead)
+}
+
+// redactSalaries zeroes worker salaries for users without the workers:salary:read permission
+func redactSalaries(c echo.Context, workers []model.Worker) {
+	if canReadSalary(c) {
+		return
+	}
+	for i := range workers {
+		redactWorker(&workers[i])
+	}
+}
+
+// redactWorker zeroes the salary of a worker, if any. Callers check canReadSalary first.
+func redactWorker(worker *model.Worker) {
+	if worker != nil {
+		worker.Salary = 0
+	}
+}
+
+// dateLayout is the format of date query parameters
+const dateLayout = "2006-01-02"
+
+// parseDate parses a date query parameter such as "2024-03-01"
+func parseDate(value string) (time.Time, error) {
+	return time.Parse(dateLayout, value)
+}
+
+// parseIDList parses a comma separated list of IDs such as "1,2,3"
+func parseIDList(value string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// checkEffectiveDate verifies that a position or salary change does not take effect in the future
+func checkEffectiveDate(date *time.Time) error {
+	if date != nil && date.After(time.Now()) {
+		return errors.New("the effective date must not be in the future")
+	}
+	return nil
+}
+
+// parseWorkerFilters reads the worker search, position, age, salary, skill and availability
+// filters from the query parameters
+func parseWorkerFilters(ctx echo.Context) (map[string]interface{}, error) {
 	filters := make(map[string]interface{})
 
 	// Handle search term
@@ -64,20 +123,87 @@ func (c *WorkerController) GetAllWorkers(ctx echo.Context) error {
 		}
 	}
 
-	// Handle salary range filters
-	if minSalary := ctx.QueryParam("min_salary"); minSalary != "" {
-		if salary, err := strconv.Atoi(minSalary); err == nil {
-			filters["min_salary"] = salary
+	// Handle salary range filters (only for users allowed to see salaries)
+	if canReadSalary(ctx) {
+		if minSalary := ctx.QueryParam("min_salary"); minSalary != "" {
+			if salary, err := strconv.Atoi(minSalary); err == nil {
+				filters["min_salary"] = salary
+			}
+		}
+		if maxSalary := ctx.QueryParam("max_salary"); maxSalary != "" {
+			if salary, err := strconv.Atoi(maxSalary); err == nil {
+				filters["max_salary"] = salary
+			}
+		}
+	}
+
+	// Handle skill filters, e.g. ?skills=3,7&skills_valid_on=2024-06-01
+	if skills := ctx.QueryParam("skills"); skills != "" {
+		skillIDs, err := parseIDList(skills)
+		if err != nil {
+			return nil, errors.New("invalid skill IDs")
 		}
+		filters["skills"] = skillIDs
 	}
-	if maxSalary := ctx.QueryParam("max_salary"); maxSalary != "" {
-		if salary, err := strconv.Atoi(maxSalary); err == nil {
-			filters["max_salary"] = salary
+	if validOn := ctx.QueryParam("skills_valid_on"); validOn != "" {
+		date, err := parseDate(validOn)
+		if err != nil {
+			return nil, errors.New("invalid date, expected YYYY-MM-DD")
 		}
+		filters["skills_valid_on"] = date
 	}
 
+	// Handle availability filters, e.g. ?available_from=2024-06-01&available_until=2024-06-30
+	for _, key := range []string{"available_from", "available_until"} {
+		if value := ctx.QueryParam(key); value != "" {
+			date, err := parseDate(value)
+			if err != nil {
+				return nil, errors.New("invalid date, expected YYYY-MM-DD")
+			}
+			filters[key] = date
+		}
+	}
+
+	return filters, nil
+}
+
+// workerSortColumns are the columns worker lists can be sorted by, besides salary
+var workerSortColumns = map[string]bool{"name": true, "age": true, "position": true, "created_at": true}
+
+// parseWorkerSort reads the sort_by and sort_order query parameters of a worker list. Sorting by
+// salary would reveal the salary order, so it is only allowed for users who can see salaries.
+func parseWorkerSort(ctx echo.Context) (string, string, error) {
 	sortBy := ctx.QueryParam("sort_by")
+	if sortBy != "" && !workerSortColumns[sortBy] && !(sortBy == "salary" && canReadSalary(ctx)) {
+		return "", "", errors.New("invalid sort_by")
+	}
+
 	sortOrder := ctx.QueryParam("sort_order")
+	if sortOrder != "" && sortOrder != "asc" && sortOrder != "desc" {
+		return "", "", errors.New("invalid sort_order, expected asc or desc")
+	}
+
+	return sortBy, sortOrder, nil
+}
+
+// GetAllWorkers handles GET /api/workers
+func (c *WorkerController) GetAllWorkers(ctx echo.Context) error {
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get query parameters for filtering and sorting
+	filters, err := parseWorkerFilters(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	sortBy, sortOrder, err := parseWorkerSort(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	// Get pagination parameters
 	page := 1
@@ -95,10 +221,11 @@ func (c *WorkerController) GetAllWorkers(ctx echo.Context) error {
 		}
 	}
 
-	workers, total, err := c.repo.GetAll(userID, filters, sortBy, sortOrder, page, pageSize)
+	workers, total, err := c.repo.GetAll(orgID, filters, sortBy, sortOrder, page, pageSize)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	redactSalaries(ctx, workers)
 
 	// Return paginated response
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
@@ -111,8 +238,8 @@ func (c *WorkerController) GetAllWorkers(ctx echo.Context) error {
 
 // GetWorker handles GET /api/workers/:id
 func (c *WorkerController) GetWorker(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -122,34 +249,45 @@ func (c *WorkerController) GetWorker(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
 	}
 
-	worker, err := c.repo.GetByID(uint(id), userID)
+	worker, err := c.repo.GetByID(uint(id), orgID)
 	if err != nil {
 		return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker not found"})
 	}
+	if !canReadSalary(ctx) {
+		redactWorker(worker)
+	}
 
 	return ctx.JSON(http.StatusOK, worker)
 }
 
 // CreateWorker handles POST /api/workers
 func (c *WorkerController) CreateWorker(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	var worker model.Worker
 	if err := ctx.Bind(&worker); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Set user ID for the worker
+	// Set creator and organization for the worker
 	worker.UserID = userID
+	worker.OrganizationID = orgID
 
 	// Validate worker
 	if err := c.validate.Struct(worker); err != nil {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	if err := checkEffectiveDate(worker.EffectiveDate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	if err := c.repo.Create(&worker); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -160,11 +298,15 @@ func (c *WorkerController) CreateWorker(ctx echo.Context) error {
 
 // UpdateWorker handles PUT /api/workers/:id
 func (c *WorkerController) UpdateWorker(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
@@ -176,21 +318,41 @@ func (c *WorkerController) UpdateWorker(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Set worker ID and user ID
+	// Set worker ID, the creator and organization are kept by the repository
 	worker.ID = uint(id)
-	worker.UserID = userID
 
-	if err := c.repo.Update(&worker, userID); err != nil {
+	// Position and salary changes are recorded in the worker's history
+	if err := checkEffectiveDate(worker.EffectiveDate); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	// Users who cannot see salaries keep the stored salary unchanged
+	salaryVisible := canReadSalary(ctx)
+	if !salaryVisible {
+		existing, err := c.repo.GetByID(worker.ID, orgID)
+		if err != nil {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker not found"})
+		}
+		worker.Salary = existing.Salary
+	}
+
+	if err := c.repo.Update(&worker, orgID, userID); err != nil {
+		if errors.Is(err, repository.ErrBackdatedChange) {
+			return ctx.JSON(http.StatusConflict, map[string]string{"error": "The change would take effect before the latest recorded change"})
+		}
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	if !salaryVisible {
+		redactWorker(&worker)
+	}
 
 	return ctx.JSON(http.StatusOK, worker)
 }
 
 // DeleteWorker handles DELETE /api/workers/:id
 func (c *WorkerController) DeleteWorker(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -200,20 +362,100 @@ func (c *WorkerController) DeleteWorker(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
 	}
 
-	if err := c.repo.Delete(uint(id), userID); err != nil {
+	if err := c.repo.Delete(uint(id), orgID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
 	return ctx.NoContent(http.StatusNoContent)
 }
 
+// GetHistory handles GET /api/workers/:id/history?field=salary. Salary and pay rate changes are
+// only listed for users allowed to see salaries.
+func (c *WorkerController) GetHistory(ctx echo.Context) error {
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	fields := []string{model.WorkerFieldPosition}
+	if canReadSalary(ctx) {
+		fields = append(fields, model.WorkerFieldSalary, model.WorkerFieldPayRate)
+	}
+	if field := ctx.QueryParam("field"); field != "" {
+		allowed := false
+		for _, f := range fields {
+			allowed = allowed || f == field
+		}
+		if !allowed {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid field"})
+		}
+		fields = []string{field}
+	}
+
+	changes, err := c.repo.GetHistory(uint(id), orgID, fields)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker not found"})
+		}
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"data": changes,
+	})
+}
+
+// GetAsOf handles GET /api/workers/:id/as-of?date=2024-03-01, returning the worker's position,
+// salary and pay rate on that date
+func (c *WorkerController) GetAsOf(ctx echo.Context) error {
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ID"})
+	}
+
+	date, err := parseDate(ctx.QueryParam("date"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid date, expected YYYY-MM-DD"})
+	}
+
+	snapshot, err := c.repo.GetAsOf(uint(id), orgID, date)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "Worker not found"})
+		}
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !canReadSalary(ctx) {
+		snapshot.Salary = 0
+		snapshot.PayRate = nil
+	}
+
+	return ctx.JSON(http.StatusOK, snapshot)
+}
+
 // AddToProject handles POST /api/workers/:workerId/projects/:projectId
 func (c *WorkerController) AddToProject(ctx echo.Context) error {
-	// Get user ID from context
+	// Get user and organization ID from context
 	userID, err := getUserID(ctx)
 	if err != nil {
 		return err
 	}
+	orgID, err := getOrganizationID(ctx)
+	if err != nil {
+		return err
+	}
 
 	workerId, err := strconv.ParseUint(ctx.Param("workerId"), 10, 32)
 	if err != nil {
@@ -225,7 +467,7 @@ func (c *WorkerController) AddToProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	if err := c.repo.AddToProject(uint(workerId), uint(projectId), userID); err != nil {
+	if err := c.repo.AddToProject(uint(workerId), uint(projectId), orgID, userID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -234,8 +476,8 @@ func (c *WorkerController) AddToProject(ctx echo.Context) error {
 
 // RemoveFromProject handles DELETE /api/workers/:workerId/projects/:projectId
 func (c *WorkerController) RemoveFromProject(ctx echo.Context) error {
-	// Get user ID from context
-	userID, err := getUserID(ctx)
+	// Get organization ID from context
+	orgID, err := getOrganizationID(ctx)
 	if err != nil {
 		return err
 	}
@@ -250,7 +492,7 @@ func (c *WorkerController) RemoveFromProject(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
 	}
 
-	if err := c.repo.RemoveFromProject(uint(workerId), uint(projectId), userID); err != nil {
+	if err := c.repo.RemoveFromProject(uint(workerId), uint(projectId), orgID); err != nil {
 		return ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/auth"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/cache"
@@ -11,6 +14,8 @@ import (
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/controller"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/middleware"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/notify"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/oidc"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
 	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
@@ -20,12 +25,36 @@ func main() {
 	// Initialize the database
 	config.InitDB()
 	
+	// Asymmetric keys used to sign access tokens, shared by all instances through the database
+	signingKeyRepo := repository.NewSigningKeyRepository()
+	auth.SetSigningKeyStore(signingKeyRepo)
+	
+	// "rotate-signing-key" rotates the signing keys and exits, so rotation can be scheduled
+	if len(os.Args) > 1 && os.Args[1] == "rotate-signing-key" {
+		key, err := auth.RotateSigningKeys()
+		if err != nil {
+			log.Fatal("Failed to rotate signing keys:", err)
+		}
+		signingKeyRepo.DeleteRetiredBefore(time.Now().Add(-auth.KeyRetention()))
+		fmt.Printf("New %s signing key: %s\n", key.Algorithm, key.ID)
+		return
+	}
+	
+	// Create the first signing key on a fresh database
+	if err := auth.EnsureSigningKey(); err != nil {
+		log.Fatal("Failed to initialize signing keys:", err)
+	}
+	
 	// Initialize the cache system
 	cache.InitCache()
 
 	// New Echo instance
 	e := echo.New()
 
+	// Resolve client IPs from X-Forwarded-For only when set by a trusted (private network) proxy,
+	// so login throttling cannot be bypassed with a spoofed header
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+
 	// Get allowed origins from environment variable or use default
 	allowedOrigins := []string{"http://localhost:5173", "http://127.0.0.1:5173"}
 	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
@@ -36,7 +65,7 @@ func main() {
 	e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
 		AllowOrigins: allowedOrigins,
 		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders: []string{"Content-Type", "Authorization", "Accept"},
+		AllowHeaders: []string{"Content-Type", "Authorization", "Accept", middleware.OrganizationHeader, auth.APIKeyHeader},
 		AllowCredentials: true,
 	}))
 
@@ -45,48 +74,313 @@ func main() {
 	projectRepo := repository.NewProjectRepository()
 	userRepo := repository.NewUserRepository()
 	logRepo := repository.NewLogRepository() // Keep log repository for background logging
+	sessionRepo := repository.NewSessionRepository()
+	resetRepo := repository.NewPasswordResetRepository()
+	mfaRepo := repository.NewMFARepository()
+	settingRepo := repository.NewSettingRepository()
+	throttleRepo := repository.NewLoginThrottleRepository()
+	roleRepo := repository.NewRoleRepository()
+	orgRepo := repository.NewOrganizationRepository()
+	apiKeyRepo := repository.NewAPIKeyRepository()
+	oidcRepo := repository.NewOIDCRepository()
+	registrationRepo := repository.NewRegistrationRepository()
+	skillRepo := repository.NewSkillRepository()
+	staffingRepo := repository.NewStaffingRepository()
+	timesheetRepo := repository.NewTimesheetRepository()
+	attendanceRepo := repository.NewAttendanceRepository()
+	payrollRepo := repository.NewPayrollRepository()
+	leaveRepo := repository.NewLeaveRepository()
+	shiftRepo := repository.NewShiftRepository()
+	crewRepo := repository.NewCrewRepository()
+
+	// Notifier used to deliver password reset links (prints to stdout for local development)
+	notifier := notify.NewLogNotifier()
+
+	// Identity provider used for single sign-on (disabled unless OIDC_ISSUER_URL and OIDC_CLIENT_ID are set)
+	oidcProvider := oidc.NewProvider(oidc.LoadConfig(), nil)
+
+	// Let the JWT middleware reject tokens of revoked sessions
+	auth.SetSessionValidator(sessionRepo)
+
+	// Let the JWT middleware accept personal API keys
+	auth.SetAPIKeyAuthenticator(apiKeyRepo)
+
+	// Resolve role permissions for the permission middleware
+	auth.SetPermissionResolver(roleRepo)
 
 	// Controller instances
 	workerCtrl := controller.NewWorkerController(workerRepo)
 	projectCtrl := controller.NewProjectController(projectRepo)
-	authCtrl := controller.NewAuthController(userRepo)
-	adminCtrl := controller.NewAdminController(userRepo, logRepo)
+	skillCtrl := controller.NewSkillController(skillRepo)
+	staffingCtrl := controller.NewStaffingController(staffingRepo)
+	timesheetCtrl := controller.NewTimesheetController(timesheetRepo)
+	attendanceCtrl := controller.NewAttendanceController(attendanceRepo)
+	payrollCtrl := controller.NewPayrollController(payrollRepo)
+	leaveCtrl := controller.NewLeaveController(leaveRepo)
+	shiftCtrl := controller.NewShiftController(shiftRepo, settingRepo)
+	crewCtrl := controller.NewCrewController(crewRepo, projectRepo)
+	authCtrl := controller.NewAuthController(userRepo, sessionRepo, resetRepo, settingRepo, throttleRepo, registrationRepo, notifier)
+	mfaCtrl := controller.NewMFAController(userRepo, mfaRepo, sessionRepo, settingRepo, throttleRepo)
+	orgCtrl := controller.NewOrganizationController(orgRepo, userRepo)
+	apiKeyCtrl := controller.NewAPIKeyController(apiKeyRepo)
+	signingKeyCtrl := controller.NewSigningKeyController(signingKeyRepo)
+	sessionCtrl := controller.NewSessionController(sessionRepo)
+	registrationCtrl := controller.NewRegistrationController(registrationRepo, settingRepo, userRepo, roleRepo, notifier)
+	oidcCtrl := controller.NewOIDCController(oidcProvider, oidcRepo, userRepo, roleRepo, sessionRepo, settingRepo)
+	adminCtrl := controller.NewAdminController(userRepo, logRepo, mfaRepo, sessionRepo, settingRepo, throttleRepo, roleRepo)
 
 	// Create activity logger middleware
 	activityLogger := middleware.NewActivityLogger(logRepo)
 
+	// Record every request an admin makes while impersonating a user
+	e.Use(activityLogger.LogImpersonation())
+
+	// Scope worker and project data to the selected organization, only its owners and admins can change it
+	orgScope := middleware.OrganizationScope(orgRepo)
+	canManageOrganization := middleware.RequireOrganizationRole(model.OrgRoleOwner, model.OrgRoleAdmin)
+
 	// Auth routes (public) with auth logging
 	authGroup := e.Group("/api/auth")
 	authGroup.POST("/login", authCtrl.Login, activityLogger.LogUserAuth(model.LogTypeLogin))
 	authGroup.POST("/register", authCtrl.Register, activityLogger.LogUserAuth(model.LogTypeRegister))
+	authGroup.POST("/refresh", authCtrl.Refresh)
+	authGroup.POST("/logout", authCtrl.Logout, auth.JWTMiddleware, activityLogger.LogUserAuth(model.LogTypeLogout))
+	authGroup.PUT("/password", authCtrl.ChangePassword, auth.PasswordChangeMiddleware, auth.BlockImpersonation, activityLogger.LogUserAuth(model.LogTypePasswordChange))
+	authGroup.GET("/password/policy", authCtrl.GetPasswordPolicy)
+	authGroup.POST("/password/reset", authCtrl.RequestPasswordReset)
+	authGroup.POST("/verify-email", authCtrl.VerifyEmail, activityLogger.LogUserAuth(model.LogTypeEmailVerify))
+	authGroup.POST("/verify-email/resend", authCtrl.ResendVerification)
+	authGroup.POST("/password/reset/confirm", authCtrl.ConfirmPasswordReset, activityLogger.LogUserAuth(model.LogTypePasswordReset))
+
+	// Session routes: list the signed-in devices and sign them out
+	authGroup.GET("/sessions", sessionCtrl.GetMySessions, auth.JWTMiddleware)
+	authGroup.DELETE("/sessions", sessionCtrl.RevokeMyOtherSessions, auth.JWTMiddleware, auth.BlockAPIKey, auth.BlockImpersonation)
+	authGroup.DELETE("/sessions/:id", sessionCtrl.RevokeMySession, auth.JWTMiddleware, auth.BlockAPIKey, auth.BlockImpersonation)
+
+	// Single sign-on routes: authorize returns the provider URL, the callback completes the login
+	authGroup.GET("/oidc/authorize", oidcCtrl.Authorize)
+	authGroup.POST("/oidc/callback", oidcCtrl.Callback, activityLogger.LogUserAuth(model.LogTypeLogin))
+
+	// MFA routes: verify completes a login, setup/enable also accept an enrollment challenge token
+	authGroup.POST("/mfa/verify", mfaCtrl.Verify, activityLogger.LogUserAuth(model.LogTypeLogin))
+	authGroup.POST("/mfa/setup", mfaCtrl.Setup, auth.MFASetupMiddleware, auth.BlockImpersonation)
+	authGroup.POST("/mfa/enable", mfaCtrl.Enable, auth.MFASetupMiddleware, auth.BlockImpersonation, activityLogger.LogUserAuth(model.LogTypeMFAEnable))
+	authGroup.POST("/mfa/disable", mfaCtrl.Disable, auth.JWTMiddleware, auth.BlockImpersonation, activityLogger.LogUserAuth(model.LogTypeMFADisable))
+
+	// Personal API key routes, the secret of a new key is only returned once
+	authGroup.GET("/api-keys", apiKeyCtrl.GetAPIKeys, auth.JWTMiddleware)
+	authGroup.POST("/api-keys", apiKeyCtrl.CreateAPIKey, auth.JWTMiddleware, auth.BlockImpersonation, activityLogger.LogUserAuth(model.LogTypeAPIKeyCreate))
+	authGroup.DELETE("/api-keys/:id", apiKeyCtrl.RevokeAPIKey, auth.JWTMiddleware, auth.BlockImpersonation, activityLogger.LogUserAuth(model.LogTypeAPIKeyRevoke))
+
+	// Permission checks used by the protected routes
+	canReadWorkers := auth.RequirePermission(model.PermWorkersRead)
+	canWriteWorkers := auth.RequirePermission(model.PermWorkersWrite)
+	canReadProjects := auth.RequirePermission(model.PermProjectsRead)
+	canWriteProjects := auth.RequirePermission(model.PermProjectsWrite)
+	canReadUsers := auth.RequirePermission(model.PermUsersRead)
+	canWriteUsers := auth.RequirePermission(model.PermUsersWrite)
+	canImpersonate := auth.RequirePermission(model.PermUsersImpersonate)
+	canManageRoles := auth.RequirePermission(model.PermRolesManage)
+	canReadLogs := auth.RequirePermission(model.PermLogsRead)
+	canReadTimesheets := auth.RequirePermission(model.PermTimesheetsRead)
+	canWriteTimesheets := auth.RequirePermission(model.PermTimesheetsWrite)
+	canApproveTimesheets := auth.RequirePermission(model.PermTimesheetsApprove)
+	canReadPayroll := auth.RequirePermission(model.PermPayrollRead)
+	canWritePayroll := auth.RequirePermission(model.PermPayrollWrite)
+	canReadLeave := auth.RequirePermission(model.PermLeaveRead)
+	canWriteLeave := auth.RequirePermission(model.PermLeaveWrite)
+	canApproveLeave := auth.RequirePermission(model.PermLeaveApprove)
+	canManageSettings := auth.RequirePermission(model.PermSettingsManage)
 
 	// Worker routes (protected) with CRUD logging
-	workers := e.Group("/api/workers", auth.JWTMiddleware, activityLogger.LogCRUDOperation(model.EntityTypeWorker))
-	workers.GET("", workerCtrl.GetAllWorkers)
-	workers.GET("/:id", workerCtrl.GetWorker)
-	workers.POST("", workerCtrl.CreateWorker)
-	workers.PUT("/:id", workerCtrl.UpdateWorker)
-	workers.DELETE("/:id", workerCtrl.DeleteWorker)
+	workers := e.Group("/api/workers", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeWorker))
+	workers.GET("", workerCtrl.GetAllWorkers, canReadWorkers)
+	workers.GET("/certifications/expiring", skillCtrl.GetExpiringCertifications, canReadWorkers)
+	workers.GET("/:id", workerCtrl.GetWorker, canReadWorkers)
+	workers.POST("", workerCtrl.CreateWorker, canWriteWorkers, canManageOrganization)
+	workers.PUT("/:id", workerCtrl.UpdateWorker, canWriteWorkers, canManageOrganization)
+	workers.DELETE("/:id", workerCtrl.DeleteWorker, canWriteWorkers, canManageOrganization)
+
+	// Worker position and compensation history routes
+	workers.GET("/:id/history", workerCtrl.GetHistory, canReadWorkers)
+	workers.GET("/:id/as-of", workerCtrl.GetAsOf, canReadWorkers)
+
+	// Worker skill and certification routes (protected) with CRUD logging
+	workers.GET("/:id/skills", skillCtrl.GetWorkerSkills, canReadWorkers)
+	workers.POST("/:id/skills", skillCtrl.AddWorkerSkill, canWriteWorkers, canManageOrganization)
+	workers.PUT("/:id/skills/:skillId", skillCtrl.UpdateWorkerSkill, canWriteWorkers, canManageOrganization)
+	workers.DELETE("/:id/skills/:skillId", skillCtrl.RemoveWorkerSkill, canWriteWorkers, canManageOrganization)
+
+	// Worker pay rate routes (protected) with CRUD logging
+	workers.GET("/:id/pay-rates", payrollCtrl.GetPayRates, canReadPayroll)
+	workers.POST("/:id/pay-rates", payrollCtrl.CreatePayRate, canWritePayroll, canManageOrganization)
+	workers.PUT("/:id/pay-rates/:rateId", payrollCtrl.UpdatePayRate, canWritePayroll, canManageOrganization)
+	workers.DELETE("/:id/pay-rates/:rateId", payrollCtrl.DeletePayRate, canWritePayroll, canManageOrganization)
+
+	// Worker leave balance and allowance routes
+	workers.GET("/:id/leave-balance", leaveCtrl.GetBalance, canReadLeave)
+	workers.PUT("/:id/leave-allowances", leaveCtrl.SetAllowance, canApproveLeave, canManageOrganization)
+
+	// Weekly shift roster of a worker
+	workers.GET("/:id/shifts", shiftCtrl.GetWorkerRoster, canReadWorkers, canReadProjects)
+
+	// Skill catalog routes (protected) with CRUD logging
+	skills := e.Group("/api/skills", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeSkill))
+	skills.GET("", skillCtrl.GetSkills, canReadWorkers)
+	skills.POST("", skillCtrl.CreateSkill, canWriteWorkers, canManageOrganization)
+	skills.PUT("/:id", skillCtrl.UpdateSkill, canWriteWorkers, canManageOrganization)
+	skills.DELETE("/:id", skillCtrl.DeleteSkill, canWriteWorkers, canManageOrganization)
+
+	// Crew routes (protected) with CRUD logging
+	crews := e.Group("/api/crews", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeCrew))
+	crews.GET("", crewCtrl.GetCrews, canReadWorkers)
+	crews.GET("/:id", crewCtrl.GetCrew, canReadWorkers)
+	crews.POST("", crewCtrl.CreateCrew, canWriteWorkers, canManageOrganization)
+	crews.PUT("/:id", crewCtrl.UpdateCrew, canWriteWorkers, canManageOrganization)
+	crews.DELETE("/:id", crewCtrl.DeleteCrew, canWriteWorkers, canManageOrganization)
 
 	// Project routes (protected) with CRUD logging
-	projects := e.Group("/api/projects", auth.JWTMiddleware, activityLogger.LogCRUDOperation(model.EntityTypeProject))
-	projects.GET("", projectCtrl.GetAllProjects)
-	projects.GET("/:id", projectCtrl.GetProject)
-	projects.POST("", projectCtrl.CreateProject)
-	projects.PUT("/:id", projectCtrl.UpdateProject)
-	projects.DELETE("/:id", projectCtrl.DeleteProject)
+	projects := e.Group("/api/projects", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeProject))
+	projects.GET("", projectCtrl.GetAllProjects, canReadProjects)
+	projects.GET("/:id", projectCtrl.GetProject, canReadProjects)
+	projects.POST("", projectCtrl.CreateProject, canWriteProjects, canManageOrganization)
+	projects.PUT("/:id", projectCtrl.UpdateProject, canWriteProjects, canManageOrganization)
+	projects.DELETE("/:id", projectCtrl.DeleteProject, canWriteProjects, canManageOrganization)
 
 	// Project-Worker relationship routes (protected) with CRUD logging
-	projects.POST("/:id/workers", projectCtrl.AssignWorkerToProject)
-	projects.GET("/:id/workers/available", projectCtrl.GetAvailableWorkers)
-	projects.DELETE("/:id/workers/:workerId", projectCtrl.UnassignWorkerFromProject)
-
-	// Admin routes (protected with admin role) with CRUD logging
-	admin := e.Group("/api/admin", auth.JWTMiddleware, auth.AdminOnly, activityLogger.LogCRUDOperation(model.EntityTypeUser))
-	admin.GET("/users", adminCtrl.GetAllUsers)
-	admin.PUT("/users/:id/status", adminCtrl.UpdateUserStatus)
-	admin.PUT("/users/:id/role", adminCtrl.UpdateUserRole)
-	admin.GET("/users/:id/activity", adminCtrl.GetUserActivity)
+	projects.POST("/:id/workers", projectCtrl.AssignWorkerToProject, canWriteProjects, canManageOrganization)
+	projects.GET("/:id/workers/available", projectCtrl.GetAvailableWorkers, canReadProjects, canReadWorkers)
+	projects.PUT("/:id/workers/:workerId", projectCtrl.UpdateAssignment, canWriteProjects, canManageOrganization)
+
+	// Dry-run allocation check, registered outside the group so it is not logged as a create
+	e.POST("/api/projects/:id/workers/check", projectCtrl.CheckAssignment, auth.JWTMiddleware, orgScope, canReadProjects)
+	projects.DELETE("/:id/workers/:workerId", projectCtrl.UnassignWorkerFromProject, canWriteProjects, canManageOrganization)
+
+	// Crew assignment routes, assigning or unassigning all members of a crew at once
+	projects.POST("/:id/crews", crewCtrl.AssignCrewToProject, canWriteProjects, canManageOrganization)
+	projects.DELETE("/:id/crews/:crewId", crewCtrl.UnassignCrewFromProject, canWriteProjects, canManageOrganization)
+
+	// Staffing requirement and gap analysis routes (protected) with CRUD logging
+	projects.GET("/staffing/gaps", staffingCtrl.GetAllGaps, canReadProjects)
+	projects.GET("/:id/staffing/gaps", staffingCtrl.GetProjectGaps, canReadProjects)
+	projects.GET("/:id/requirements", staffingCtrl.GetRequirements, canReadProjects)
+	projects.POST("/:id/requirements", staffingCtrl.CreateRequirement, canWriteProjects, canManageOrganization)
+	projects.PUT("/:id/requirements/:requirementId", staffingCtrl.UpdateRequirement, canWriteProjects, canManageOrganization)
+	projects.DELETE("/:id/requirements/:requirementId", staffingCtrl.DeleteRequirement, canWriteProjects, canManageOrganization)
+
+	// Daily attendance roster of a site
+	projects.GET("/:id/attendance", attendanceCtrl.GetRoster, canReadProjects, canReadTimesheets)
+
+	// Absence calendar of a site
+	projects.GET("/:id/absences", leaveCtrl.GetAbsences, canReadProjects, canReadLeave)
+
+	// Shift template and scheduling routes
+	projects.GET("/:id/shift-templates", shiftCtrl.GetTemplates, canReadProjects)
+	projects.POST("/:id/shift-templates", shiftCtrl.CreateTemplate, canWriteProjects, canManageOrganization)
+	projects.PUT("/:id/shift-templates/:templateId", shiftCtrl.UpdateTemplate, canWriteProjects, canManageOrganization)
+	projects.DELETE("/:id/shift-templates/:templateId", shiftCtrl.DeleteTemplate, canWriteProjects, canManageOrganization)
+	projects.GET("/:id/shifts", shiftCtrl.GetProjectRoster, canReadProjects)
+	projects.GET("/:id/shifts/:shiftId", shiftCtrl.GetShift, canReadProjects)
+	projects.POST("/:id/shifts", shiftCtrl.CreateShift, canWriteProjects, canManageOrganization)
+	projects.POST("/:id/shifts/copy-week", shiftCtrl.CopyWeek, canWriteProjects, canManageOrganization)
+	projects.PUT("/:id/shifts/:shiftId", shiftCtrl.UpdateShift, canWriteProjects, canManageOrganization)
+	projects.DELETE("/:id/shifts/:shiftId", shiftCtrl.DeleteShift, canWriteProjects, canManageOrganization)
+
+	// Timesheet routes (protected) with CRUD logging
+	timesheets := e.Group("/api/timesheets", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeTimesheet))
+	timesheets.GET("", timesheetCtrl.GetTimesheets, canReadTimesheets)
+	timesheets.GET("/:id", timesheetCtrl.GetTimesheet, canReadTimesheets)
+	timesheets.POST("", timesheetCtrl.CreateTimesheet, canWriteTimesheets)
+	timesheets.PUT("/:id", timesheetCtrl.UpdateTimesheet, canWriteTimesheets)
+	timesheets.DELETE("/:id", timesheetCtrl.DeleteTimesheet, canWriteTimesheets)
+	timesheets.POST("/weekly", timesheetCtrl.SaveWeek, canWriteTimesheets)
+
+	// Attendance routes (protected) with CRUD logging, punches are checked against the site's geofence
+	attendance := e.Group("/api/attendance", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeAttendance))
+	attendance.GET("/punches", attendanceCtrl.GetPunches, canReadTimesheets)
+	attendance.POST("/punches", attendanceCtrl.RecordPunch, canWriteTimesheets)
+	attendance.GET("/missing-clock-outs", attendanceCtrl.GetMissingClockOuts, canReadTimesheets)
+	attendance.POST("/convert", attendanceCtrl.ConvertPunches, canWriteTimesheets)
+
+	// Payroll run routes (protected) with CRUD logging, finalized runs are locked
+	payroll := e.Group("/api/payroll", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypePayroll))
+	payroll.GET("/runs", payrollCtrl.GetRuns, canReadPayroll)
+	payroll.GET("/runs/:id", payrollCtrl.GetRun, canReadPayroll)
+	payroll.GET("/runs/:id/export", payrollCtrl.ExportRun, canReadPayroll)
+	payroll.POST("/runs", payrollCtrl.CreateRun, canWritePayroll, canManageOrganization)
+	payroll.PUT("/runs/:id", payrollCtrl.UpdateRun, canWritePayroll, canManageOrganization)
+	payroll.DELETE("/runs/:id", payrollCtrl.DeleteRun, canWritePayroll, canManageOrganization)
+	e.POST("/api/payroll/runs/:id/finalize", payrollCtrl.FinalizeRun, auth.JWTMiddleware, orgScope, canWritePayroll, canManageOrganization, activityLogger.LogAction(model.LogTypeFinalize, model.EntityTypePayroll))
+
+	// Leave request routes (protected) with CRUD logging, only pending requests can be changed
+	leave := e.Group("/api/leave", auth.JWTMiddleware, orgScope, activityLogger.LogCRUDOperation(model.EntityTypeLeave))
+	leave.GET("", leaveCtrl.GetLeaveRequests, canReadLeave)
+	leave.GET("/:id", leaveCtrl.GetLeaveRequest, canReadLeave)
+	leave.POST("", leaveCtrl.CreateLeaveRequest, canWriteLeave)
+	leave.PUT("/:id", leaveCtrl.UpdateLeaveRequest, canWriteLeave)
+	leave.DELETE("/:id", leaveCtrl.DeleteLeaveRequest, canWriteLeave)
+
+	// Leave review routes, logged as approve, reject and cancel actions instead of CRUD
+	e.POST("/api/leave/:id/approve", leaveCtrl.ApproveLeaveRequest, auth.JWTMiddleware, orgScope, canApproveLeave, canManageOrganization, activityLogger.LogAction(model.LogTypeApprove, model.EntityTypeLeave))
+	e.POST("/api/leave/:id/reject", leaveCtrl.RejectLeaveRequest, auth.JWTMiddleware, orgScope, canApproveLeave, canManageOrganization, activityLogger.LogAction(model.LogTypeReject, model.EntityTypeLeave))
+	e.POST("/api/leave/:id/cancel", leaveCtrl.CancelLeaveRequest, auth.JWTMiddleware, orgScope, canWriteLeave, activityLogger.LogAction(model.LogTypeCancel, model.EntityTypeLeave))
+
+	// Timesheet review routes, logged as submit, approve and reject actions instead of CRUD
+	e.POST("/api/timesheets/submit", timesheetCtrl.SubmitTimesheets, auth.JWTMiddleware, orgScope, canWriteTimesheets, activityLogger.LogAction(model.LogTypeSubmit, model.EntityTypeTimesheet))
+	e.POST("/api/timesheets/approve", timesheetCtrl.ApproveTimesheets, auth.JWTMiddleware, orgScope, canApproveTimesheets, canManageOrganization, activityLogger.LogAction(model.LogTypeApprove, model.EntityTypeTimesheet))
+	e.POST("/api/timesheets/reject", timesheetCtrl.RejectTimesheets, auth.JWTMiddleware, orgScope, canApproveTimesheets, canManageOrganization, activityLogger.LogAction(model.LogTypeReject, model.EntityTypeTimesheet))
+
+	// Organization routes (protected, not available to API keys), membership is checked by the controller
+	orgs := e.Group("/api/organizations", auth.JWTMiddleware, auth.BlockAPIKey)
+	orgs.GET("", orgCtrl.GetMyOrganizations)
+	orgs.POST("", orgCtrl.CreateOrganization)
+	orgs.GET("/invitations", orgCtrl.GetMyInvitations)
+	orgs.POST("/invitations/:invitationId/accept", orgCtrl.AcceptInvitation)
+	orgs.POST("/invitations/:invitationId/decline", orgCtrl.DeclineInvitation)
+	orgs.GET("/:id/members", orgCtrl.GetMembers)
+	orgs.PUT("/:id/members/:userId", orgCtrl.UpdateMemberRole)
+	orgs.DELETE("/:id/members/:userId", orgCtrl.RemoveMember)
+	orgs.GET("/:id/invitations", orgCtrl.GetInvitations)
+	orgs.POST("/:id/invitations", orgCtrl.InviteUser)
+	orgs.DELETE("/:id/invitations/:invitationId", orgCtrl.RevokeInvitation)
+
+	// Admin routes (protected by per-route permissions) with CRUD logging
+	admin := e.Group("/api/admin", auth.JWTMiddleware, activityLogger.LogCRUDOperation(model.EntityTypeUser))
+	admin.GET("/users", adminCtrl.GetAllUsers, canReadUsers)
+	admin.PUT("/users/:id/status", adminCtrl.UpdateUserStatus, canWriteUsers)
+	admin.PUT("/users/:id/role", adminCtrl.UpdateUserRole, auth.BlockImpersonation, canManageRoles)
+	admin.GET("/users/:id/activity", adminCtrl.GetUserActivity, canReadLogs)
+	admin.DELETE("/users/:id/mfa", adminCtrl.ResetUserMFA, canWriteUsers)
+	admin.GET("/users/:id/sessions", sessionCtrl.GetUserSessions, canReadUsers)
+	admin.DELETE("/users/:id/sessions", sessionCtrl.RevokeAllUserSessions, canWriteUsers)
+	admin.DELETE("/users/:id/sessions/:sessionId", sessionCtrl.RevokeUserSession, canWriteUsers)
+	admin.GET("/settings/mfa", adminCtrl.GetMFASettings, canManageSettings)
+	admin.PUT("/settings/mfa", adminCtrl.UpdateMFASettings, canManageSettings)
+	admin.GET("/settings/registration", registrationCtrl.GetRegistrationSettings, canManageSettings)
+	admin.PUT("/settings/registration", registrationCtrl.UpdateRegistrationSettings, canManageSettings)
+	admin.GET("/settings/shifts", shiftCtrl.GetShiftSettings, canManageSettings)
+	admin.PUT("/settings/shifts", shiftCtrl.UpdateShiftSettings, canManageSettings)
+	admin.GET("/invites", registrationCtrl.GetInvites, canWriteUsers)
+	admin.POST("/invites", registrationCtrl.CreateInvite, canWriteUsers)
+	admin.DELETE("/invites/:id", registrationCtrl.RevokeInvite, canWriteUsers)
+	admin.GET("/lockouts", adminCtrl.GetLockouts, canReadUsers)
+	admin.DELETE("/lockouts/:id", adminCtrl.Unlock, canWriteUsers)
+
+	// Impersonation issues a short-lived token for acting as the user, logout ends it.
+	// Registered outside the admin group so it is logged as IMPERSONATE instead of a user update.
+	e.POST("/api/admin/users/:id/impersonate", adminCtrl.ImpersonateUser, auth.JWTMiddleware, auth.BlockImpersonation, canImpersonate, activityLogger.LogUserAuth(model.LogTypeImpersonate))
+
+	// Role and permission management routes
+	admin.GET("/permissions", adminCtrl.GetPermissions, canManageRoles)
+	admin.GET("/roles", adminCtrl.GetRoles, canManageRoles)
+	admin.POST("/roles", adminCtrl.CreateRole, auth.BlockImpersonation, canManageRoles)
+	admin.PUT("/roles/:id", adminCtrl.UpdateRole, auth.BlockImpersonation, canManageRoles)
+	admin.DELETE("/roles/:id", adminCtrl.DeleteRole, auth.BlockImpersonation, canManageRoles)
+
+	// Signing key routes, the key set is public so other services can verify access tokens
+	e.GET("/.well-known/jwks.json", signingKeyCtrl.JWKS)
+	admin.GET("/signing-keys", signingKeyCtrl.GetSigningKeys, canManageSettings)
+	admin.POST("/signing-keys/rotate", signingKeyCtrl.RotateSigningKeys, canManageSettings)
 
 	// Health check endpoint
 	e.GET("/health", func(c echo.Context) error {
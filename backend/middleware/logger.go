@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -33,6 +34,11 @@ func (l *ActivityLogger) LogCRUDOperation(entityType model.EntityType) echo.Midd
 				return err
 			}
 
+			// Forced over-allocations are always recorded, also for impersonated requests
+			if override, ok := c.Get("allocation_override").(string); ok {
+				l.logOverride(c, entityType, override)
+			}
+
 			// Don't log OPTIONS requests
 			if c.Request().Method == http.MethodOptions {
 				return nil
@@ -42,6 +48,11 @@ func (l *ActivityLogger) LogCRUDOperation(entityType model.EntityType) echo.Midd
 			if c.Request().Method == http.MethodGet {
 				return nil
 			}
+			
+			// Impersonated requests are recorded by LogImpersonation
+			if _, ok := c.Get("impersonator_id").(uint); ok {
+				return nil
+			}
 
 			// Get user information from context (set by JWTMiddleware)
 			userID, ok := c.Get("user_id").(uint)
@@ -107,6 +118,68 @@ func (l *ActivityLogger) LogCRUDOperation(entityType model.EntityType) echo.Midd
 	}
 }
 
+// LogAction logs a successful workflow action such as an approval. Handlers can describe the
+// action by setting "activity_description" in the context.
+func (l *ActivityLogger) LogAction(logType model.LogType, entityType model.EntityType) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Process the request
+			err := next(c)
+			if err != nil {
+				return err
+			}
+			
+			// Only successful actions are recorded
+			if c.Response().Status >= http.StatusBadRequest {
+				return nil
+			}
+			
+			// Impersonated requests are recorded by LogImpersonation
+			if _, ok := c.Get("impersonator_id").(uint); ok {
+				return nil
+			}
+			
+			userID, ok := c.Get("user_id").(uint)
+			if !ok {
+				return nil
+			}
+			username, _ := c.Get("username").(string)
+			
+			entityID := uint(0)
+			if id, parseErr := strconv.ParseUint(c.Param("id"), 10, 32); parseErr == nil {
+				entityID = uint(id)
+			}
+			
+			description, ok := c.Get("activity_description").(string)
+			if !ok {
+				description = fmt.Sprintf("%s %s", logType, entityType)
+			}
+			if len(description) > 255 {
+				description = description[:255]
+			}
+			
+			log := &model.ActivityLog{
+				UserID:      userID,
+				Username:    username,
+				LogType:     logType,
+				EntityType:  entityType,
+				EntityID:    entityID,
+				Description: description,
+			}
+			
+			// Store log asynchronously
+			go func(log *model.ActivityLog) {
+				err := l.logRepo.CreateLog(log)
+				if err != nil {
+					fmt.Printf("Failed to log action: %v\n", err)
+				}
+			}(log)
+			
+			return nil
+		}
+	}
+}
+
 // LogUserAuth logs user authentication events (login, logout, register)
 func (l *ActivityLogger) LogUserAuth(logType model.LogType) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -114,9 +187,18 @@ func (l *ActivityLogger) LogUserAuth(logType model.LogType) echo.MiddlewareFunc
 			// Process the request
 			err := next(c)
 			if err != nil {
+				// Record failed attempts reported by the auth handler
+				if failure, ok := c.Get("auth_failure").(map[string]interface{}); ok {
+					l.logAuthFailure(failure)
+				}
 				return err
 			}
 			
+			// Impersonated requests are recorded by LogImpersonation
+			if _, ok := c.Get("impersonator_id").(uint); ok {
+				return nil
+			}
+			
 			// Check response status to see if the auth operation was successful
 			// This assumes auth handlers set userID in response
 			resMap, ok := c.Get("auth_response").(map[string]interface{})
@@ -132,13 +214,21 @@ func (l *ActivityLogger) LogUserAuth(logType model.LogType) echo.MiddlewareFunc
 			
 			username, _ := resMap["username"].(string)
 			
+			// Handlers acting on another user name it and describe the event themselves
+			entityID, _ := resMap["entity_id"].(uint)
+			description, ok := resMap["description"].(string)
+			if !ok {
+				description = fmt.Sprintf("User %s: %s", logType, username)
+			}
+			
 			// Create simplified log entry for auth event (removed IP and UserAgent)
 			log := &model.ActivityLog{
 				UserID:      userID,
 				Username:    username,
 				LogType:     logType,
 				EntityType:  model.EntityTypeUser,
-				Description: fmt.Sprintf("User %s: %s", logType, username),
+				EntityID:    entityID,
+				Description: description,
 			}
 			
 			// Store log asynchronously
@@ -154,6 +244,141 @@ func (l *ActivityLogger) LogUserAuth(logType model.LogType) echo.MiddlewareFunc
 	}
 }
 
+// LogImpersonation logs every request made with an impersonation token, including reads and
+// rejected requests, recording both the impersonated user and the admin acting as them
+func (l *ActivityLogger) LogImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Process the request
+			err := next(c)
+			
+			// Identities are set by JWTMiddleware on impersonation tokens only
+			impersonatorID, ok := c.Get("impersonator_id").(uint)
+			if !ok {
+				return err
+			}
+			
+			userID, _ := c.Get("user_id").(uint)
+			username, _ := c.Get("username").(string)
+			impersonatorUsername, _ := c.Get("impersonator_username").(string)
+			
+			// Determine log type based on HTTP method
+			var logType model.LogType
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead:
+				logType = model.LogTypeRead
+			case http.MethodPost:
+				logType = model.LogTypeCreate
+			case http.MethodPut, http.MethodPatch:
+				logType = model.LogTypeUpdate
+			case http.MethodDelete:
+				logType = model.LogTypeDelete
+			default:
+				return err
+			}
+			
+			// Get entity ID from URL path parameter
+			entityID := uint(0)
+			if id, parseErr := strconv.ParseUint(c.Param("id"), 10, 32); parseErr == nil {
+				entityID = uint(id)
+			}
+			
+			// Report the status the client received, including rejected requests
+			status := c.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				status = httpErr.Code
+			} else if err != nil {
+				status = http.StatusInternalServerError
+			}
+			
+			description := fmt.Sprintf("%s %s as %s by %s: %d",
+				c.Request().Method, c.Request().URL.EscapedPath(), username, impersonatorUsername, status)
+			if len(description) > 255 {
+				description = description[:255]
+			}
+			
+			log := &model.ActivityLog{
+				UserID:               userID,
+				Username:             username,
+				ImpersonatorID:       &impersonatorID,
+				ImpersonatorUsername: impersonatorUsername,
+				LogType:              logType,
+				EntityType:           ExtractEntityTypeFromPath(c.Path()),
+				EntityID:             entityID,
+				Description:          description,
+			}
+			
+			// Store log asynchronously
+			go func(log *model.ActivityLog) {
+				err := l.logRepo.CreateLog(log)
+				if err != nil {
+					fmt.Printf("Failed to log impersonated activity: %v\n", err)
+				}
+			}(log)
+			
+			return err
+		}
+	}
+}
+
+// logOverride stores an operation that was forced past a validation warning
+func (l *ActivityLogger) logOverride(c echo.Context, entityType model.EntityType, description string) {
+	userID, _ := c.Get("user_id").(uint)
+	username, _ := c.Get("username").(string)
+	
+	entityID := uint(0)
+	if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+		entityID = uint(id)
+	}
+	
+	log := &model.ActivityLog{
+		UserID:      userID,
+		Username:    username,
+		LogType:     model.LogTypeAllocationOverride,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		Description: description,
+	}
+	if impersonatorID, ok := c.Get("impersonator_id").(uint); ok {
+		log.ImpersonatorID = &impersonatorID
+		log.ImpersonatorUsername, _ = c.Get("impersonator_username").(string)
+	}
+	
+	// Store log asynchronously
+	go func(log *model.ActivityLog) {
+		err := l.logRepo.CreateLog(log)
+		if err != nil {
+			fmt.Printf("Failed to log override: %v\n", err)
+		}
+	}(log)
+}
+
+// logAuthFailure stores a failed authentication attempt
+func (l *ActivityLogger) logAuthFailure(failure map[string]interface{}) {
+	userID, _ := failure["user_id"].(uint)
+	username, _ := failure["username"].(string)
+	ip, _ := failure["ip"].(string)
+	reason, _ := failure["reason"].(string)
+	
+	log := &model.ActivityLog{
+		UserID:      userID,
+		Username:    username,
+		LogType:     model.LogTypeLoginFailed,
+		EntityType:  model.EntityTypeUser,
+		EntityID:    userID,
+		Description: fmt.Sprintf("Failed login for %s from %s: %s", username, ip, reason),
+	}
+	
+	// Store log asynchronously
+	go func(log *model.ActivityLog) {
+		err := l.logRepo.CreateLog(log)
+		if err != nil {
+			fmt.Printf("Failed to log auth failure: %v\n", err)
+		}
+	}(log)
+}
+
 // ExtractEntityTypeFromPath determines the entity type from the URL path
 func ExtractEntityTypeFromPath(path string) model.EntityType {
 	path = strings.ToLower(path)
@@ -163,6 +388,18 @@ func ExtractEntityTypeFromPath(path string) model.EntityType {
 		return model.EntityTypeWorker
 	case strings.Contains(path, "/projects"):
 		return model.EntityTypeProject
+	case strings.Contains(path, "/skills"):
+		return model.EntityTypeSkill
+	case strings.Contains(path, "/timesheets"):
+		return model.EntityTypeTimesheet
+	case strings.Contains(path, "/attendance"):
+		return model.EntityTypeAttendance
+	case strings.Contains(path, "/payroll"):
+		return model.EntityTypePayroll
+	case strings.Contains(path, "/leave"):
+		return model.EntityTypeLeave
+	case strings.Contains(path, "/crews"):
+		return model.EntityTypeCrew
 	case strings.Contains(path, "/users"):
 		return model.EntityTypeUser
 	default:
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// OrganizationHeader selects the organization a request operates on
+const OrganizationHeader = "X-Organization-ID"
+
+// OrganizationScope resolves the organization of the request from the X-Organization-ID header,
+// defaulting to the user's personal organization, and verifies the user is a member of it.
+// It must run after JWTMiddleware.
+func OrganizationScope(orgRepo *repository.OrganizationRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := c.Get("user_id").(uint)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "User not authenticated")
+			}
+
+			var orgID uint
+			if header := c.Request().Header.Get(OrganizationHeader); header != "" {
+				id, err := strconv.ParseUint(header, 10, 32)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, "Invalid organization ID")
+				}
+				orgID = uint(id)
+			} else {
+				id, err := orgRepo.GetPersonalOrganizationID(userID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusForbidden, "No organization selected")
+				}
+				orgID = id
+			}
+
+			// Only members can access the organization's data
+			member, err := orgRepo.GetMembership(orgID, userID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, "Not a member of this organization")
+			}
+
+			c.Set("organization_id", orgID)
+			c.Set("organization_role", member.Role)
+
+			return next(c)
+		}
+	}
+}
+
+// RequireOrganizationRole allows the request only when the user holds one of the given roles in the
+// organization resolved by OrganizationScope. It must run after OrganizationScope.
+func RequireOrganizationRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("organization_role").(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient organization role")
+		}
+	}
+}
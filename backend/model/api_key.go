@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// APIKeyPrefix starts every API key secret so leaked keys are easy to recognize
+const APIKeyPrefix = "wms_"
+
+// APIKey represents a personal API key used by scripts and integrations.
+// Only the SHA-256 hash of the secret is stored, the secret is shown once on creation.
+// Scopes limit the key to a subset of the permissions of its owner's role.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"size:100;not null" validate:"required,min=1,max=100"`
+	Prefix     string     `json:"prefix" gorm:"size:20"` // Leading characters of the secret, for identification
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json" validate:"required,min=1"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
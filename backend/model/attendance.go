@@ -0,0 +1,92 @@
+package model
+
+import (
+	"time"
+)
+
+// Attendance punch types
+const (
+	PunchTypeIn  = "in"
+	PunchTypeOut = "out"
+)
+
+// Attendance punch states. Punches outside the site's geofence or by workers not assigned to the
+// project are kept but flagged for review, and are left out when punches are converted into hours.
+const (
+	PunchStatusAccepted = "accepted"
+	PunchStatusFlagged  = "flagged"
+)
+
+// Reasons for flagging a punch
+const (
+	PunchFlagOutsideGeofence = "outside_geofence"
+	PunchFlagNotAssigned     = "not_assigned"
+)
+
+// AttendancePunch is a clock-in or clock-out of a worker at a site, with the position reported by
+// the client and its distance to the site
+type AttendancePunch struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	WorkerID       uint      `json:"worker_id" gorm:"index;not null"`
+	ProjectID      uint      `json:"project_id" gorm:"index;not null"`
+	Type           string    `json:"type" gorm:"size:10;not null"`
+	Timestamp      time.Time `json:"timestamp" gorm:"index;not null"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Distance       float64   `json:"distance"` // Meters from the site
+	Status         string    `json:"status" gorm:"size:20;index;not null"`
+	FlagReason     string    `json:"flag_reason,omitempty" gorm:"size:50"`
+	UserID         uint      `json:"user_id" gorm:"index;not null"` // Recorded by
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	Worker         *Worker   `json:"worker,omitempty"`
+	Project        *Project  `json:"project,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AttendanceRecord summarizes the punches of one worker at a site on one day
+type AttendanceRecord struct {
+	WorkerID        uint              `json:"worker_id"`
+	WorkerName      string            `json:"worker_name"`
+	Position        string            `json:"position"`
+	Assigned        bool              `json:"assigned"` // Assigned to the project on that day
+	Status          string            `json:"status"`   // absent, clocked_in or clocked_out
+	ClockIn         *time.Time        `json:"clock_in,omitempty"`
+	ClockOut        *time.Time        `json:"clock_out,omitempty"`
+	Hours           float64           `json:"hours"` // Time between matched clock-ins and clock-outs
+	Flagged         int               `json:"flagged"`
+	MissingClockOut bool              `json:"missing_clock_out"` // A clock-in of a past day was never closed
+	Punches         []AttendancePunch `json:"punches"`
+}
+
+// Attendance record states
+const (
+	AttendanceAbsent     = "absent"
+	AttendanceClockedIn  = "clocked_in"
+	AttendanceClockedOut = "clocked_out"
+)
+
+// MissingClockOut is a clock-in that was not followed by a clock-out on the same day
+type MissingClockOut struct {
+	PunchID     uint      `json:"punch_id"`
+	WorkerID    uint      `json:"worker_id"`
+	WorkerName  string    `json:"worker_name"`
+	ProjectID   uint      `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	ClockIn     time.Time `json:"clock_in"`
+}
+
+// AttendanceConversion reports the timesheet entries written from attendance punches
+type AttendanceConversion struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Skipped []AttendanceSkip `json:"skipped"`
+	Entries []TimesheetEntry `json:"entries"`
+}
+
+// AttendanceSkip is a worker day whose punches could not be converted into hours
+type AttendanceSkip struct {
+	WorkerID  uint      `json:"worker_id"`
+	ProjectID uint      `json:"project_id"`
+	Date      time.Time `json:"date"`
+	Reason    string    `json:"reason"`
+}
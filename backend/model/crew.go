@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+)
+
+// Crew is a group of workers that moves between sites together, e.g. a formwork crew, led by one of its members
+type Crew struct {
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	Name           string       `json:"name" gorm:"size:100;uniqueIndex:idx_crews_org_name;not null" validate:"required,min=2,max=100"`
+	LeadID         *uint        `json:"lead_id" gorm:"index"` // Must be a member of the crew
+	Notes          string       `json:"notes" gorm:"size:500" validate:"max=500"`
+	OrganizationID uint         `json:"organization_id" gorm:"uniqueIndex:idx_crews_org_name;not null"`
+	UserID         uint         `json:"user_id" gorm:"index;not null"` // Created by
+	MemberIDs      []uint       `json:"member_ids" gorm:"-" validate:"max=200"`
+	Members        []CrewMember `json:"members,omitempty" gorm:"foreignKey:CrewID"`
+	Lead           *Worker      `json:"lead,omitempty" gorm:"foreignKey:LeadID"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// CrewMember places a worker in a crew. A worker belongs to at most one crew.
+type CrewMember struct {
+	CrewID         uint    `json:"crew_id" gorm:"primaryKey"`
+	WorkerID       uint    `json:"worker_id" gorm:"primaryKey;uniqueIndex"`
+	OrganizationID uint    `json:"organization_id" gorm:"index;not null"`
+	Worker         *Worker `json:"worker,omitempty"`
+}
+
+// WorkerAllocationConflict lists the over-allocated periods of one worker of a crew assignment
+type WorkerAllocationConflict struct {
+	WorkerID   uint                 `json:"worker_id"`
+	WorkerName string               `json:"worker_name"`
+	Conflicts  []AllocationConflict `json:"conflicts"`
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"time"
+)
+
+// Leave types
+const (
+	LeaveTypeVacation = "vacation"
+	LeaveTypeSick     = "sick"
+	LeaveTypeTraining = "training"
+	LeaveTypeUnpaid   = "unpaid"
+)
+
+// LeaveTypes lists every leave type in display order
+var LeaveTypes = []string{LeaveTypeVacation, LeaveTypeSick, LeaveTypeTraining, LeaveTypeUnpaid}
+
+// Leave request states. Requests are pending until approved or rejected, and pending or approved
+// requests can be cancelled.
+const (
+	LeaveStatusPending   = "pending"
+	LeaveStatusApproved  = "approved"
+	LeaveStatusRejected  = "rejected"
+	LeaveStatusCancelled = "cancelled"
+)
+
+// LeaveRequest is an absence of a worker from StartDate to EndDate, both included. A half day at
+// the start means the leave begins at noon, a half day at the end that it ends at noon.
+type LeaveRequest struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WorkerID       uint       `json:"worker_id" gorm:"index;not null" validate:"required"`
+	Type           string     `json:"type" gorm:"size:20;index;not null" validate:"required,oneof=vacation sick training unpaid"`
+	StartDate      time.Time  `json:"start_date" gorm:"index;not null" validate:"required"`
+	EndDate        time.Time  `json:"end_date" gorm:"index;not null" validate:"required,gtefield=StartDate"`
+	HalfDayStart   bool       `json:"half_day_start"`
+	HalfDayEnd     bool       `json:"half_day_end"`
+	Days           float64    `json:"days"` // Working days taken, Monday to Friday
+	Reason         string     `json:"reason" gorm:"size:500" validate:"max=500"`
+	Status         string     `json:"status" gorm:"size:20;index;not null;default:pending"`
+	ReviewedByID   *uint      `json:"reviewed_by_id,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote     string     `json:"review_note,omitempty" gorm:"size:255"`
+	UserID         uint       `json:"user_id" gorm:"index;not null"` // Requested by
+	OrganizationID uint       `json:"organization_id" gorm:"index;not null"`
+	Worker         *Worker    `json:"worker,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// DaysBetween returns the working days of the leave from from to until, both included. Half days
+// count as 0.5 and weekends are not counted.
+func (l LeaveRequest) DaysBetween(from, until time.Time) float64 {
+	if l.StartDate.After(from) {
+		from = l.StartDate
+	}
+	if l.EndDate.Before(until) {
+		until = l.EndDate
+	}
+
+	days := 0.0
+	for day := from; !day.After(until); day = day.AddDate(0, 0, 1) {
+		if day.UTC().Weekday() == time.Saturday || day.UTC().Weekday() == time.Sunday {
+			continue
+		}
+		if l.halfDayOn(day) {
+			days += 0.5
+		} else {
+			days++
+		}
+	}
+	return days
+}
+
+// FullDayOn reports whether the worker is absent for the whole of the given day
+func (l LeaveRequest) FullDayOn(date time.Time) bool {
+	return !date.Before(l.StartDate) && !date.After(l.EndDate) && !l.halfDayOn(date)
+}
+
+// halfDayOn reports whether only half of the given day is taken
+func (l LeaveRequest) halfDayOn(date time.Time) bool {
+	return (l.HalfDayStart && date.Equal(l.StartDate)) || (l.HalfDayEnd && date.Equal(l.EndDate))
+}
+
+// LeaveAllowance is the number of days of a leave type a worker may take in a year
+type LeaveAllowance struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	WorkerID       uint      `json:"worker_id" gorm:"uniqueIndex:idx_leave_allowance;not null"`
+	Year           int       `json:"year" gorm:"uniqueIndex:idx_leave_allowance;not null" validate:"required,min=2000,max=2100"`
+	Type           string    `json:"type" gorm:"uniqueIndex:idx_leave_allowance;size:20;not null" validate:"required,oneof=vacation sick training unpaid"`
+	Days           float64   `json:"days" validate:"min=0,max=366"`
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LeaveBalance is the leave of one type a worker has taken and may still take in a year.
+// Allowance and Remaining are empty when no allowance is set for the type.
+type LeaveBalance struct {
+	Type      string   `json:"type"`
+	Allowance *float64 `json:"allowance"`
+	Taken     float64  `json:"taken"`   // Approved days
+	Pending   float64  `json:"pending"` // Days awaiting approval
+	Remaining *float64 `json:"remaining"`
+}
+
+// Absence is approved leave of a worker assigned to a project, shown in the project's absence calendar
+type Absence struct {
+	LeaveID      uint      `json:"leave_id"`
+	WorkerID     uint      `json:"worker_id"`
+	WorkerName   string    `json:"worker_name"`
+	Type         string    `json:"type"`
+	StartDate    time.Time `json:"start_date"`
+	EndDate      time.Time `json:"end_date"`
+	HalfDayStart bool      `json:"half_day_start"`
+	HalfDayEnd   bool      `json:"half_day_end"`
+	Days         float64   `json:"days"` // Working days of the leave within the calendar range
+}
@@ -16,30 +16,60 @@ const (
 	LogTypeUpdate LogType = "UPDATE"
 	LogTypeDelete LogType = "DELETE"
 	
+	// Operation forced past a validation warning
+	LogTypeAllocationOverride LogType = "ALLOCATION_OVERRIDE"
+	
+	// Review workflow types
+	LogTypeSubmit   LogType = "SUBMIT"
+	LogTypeApprove  LogType = "APPROVE"
+	LogTypeReject   LogType = "REJECT"
+	LogTypeCancel   LogType = "CANCEL"
+	LogTypeFinalize LogType = "FINALIZE"
+	
 	// Auth operation types
-	LogTypeLogin    LogType = "LOGIN"
-	LogTypeLogout   LogType = "LOGOUT"
-	LogTypeRegister LogType = "REGISTER"
+	LogTypeLogin       LogType = "LOGIN"
+	LogTypeLoginFailed LogType = "LOGIN_FAILED"
+	LogTypeLogout      LogType = "LOGOUT"
+	LogTypeRegister    LogType = "REGISTER"
+	LogTypeEmailVerify LogType = "EMAIL_VERIFY"
+	LogTypeImpersonate LogType = "IMPERSONATE"
+	
+	// Credential operation types
+	LogTypePasswordChange LogType = "PASSWORD_CHANGE"
+	LogTypePasswordReset  LogType = "PASSWORD_RESET"
+	LogTypeMFAEnable      LogType = "MFA_ENABLE"
+	LogTypeMFADisable     LogType = "MFA_DISABLE"
+	LogTypeAPIKeyCreate   LogType = "API_KEY_CREATE"
+	LogTypeAPIKeyRevoke   LogType = "API_KEY_REVOKE"
 )
 
 // EntityType represents the type of entity being operated on
 type EntityType string
 
 const (
-	EntityTypeWorker  EntityType = "WORKER"
-	EntityTypeProject EntityType = "PROJECT"
-	EntityTypeUser    EntityType = "USER"
+	EntityTypeWorker     EntityType = "WORKER"
+	EntityTypeProject    EntityType = "PROJECT"
+	EntityTypeUser       EntityType = "USER"
+	EntityTypeSkill      EntityType = "SKILL"
+	EntityTypeTimesheet  EntityType = "TIMESHEET"
+	EntityTypeAttendance EntityType = "ATTENDANCE"
+	EntityTypePayroll    EntityType = "PAYROLL"
+	EntityTypeLeave      EntityType = "LEAVE"
+	EntityTypeCrew       EntityType = "CREW"
 )
 
-// ActivityLog represents a system activity log entry
+// ActivityLog represents a system activity log entry. Actions taken while an admin
+// impersonates a user record both identities.
 type ActivityLog struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	UserID      uint           `json:"user_id" gorm:"index" validate:"required"`
-	Username    string         `json:"username" gorm:"size:50"`
-	LogType     LogType        `json:"log_type" gorm:"size:20;index" validate:"required"`
-	EntityType  EntityType     `json:"entity_type" gorm:"size:20;index" validate:"required"`
-	EntityID    uint           `json:"entity_id" gorm:"index"`
-	Description string         `json:"description" gorm:"size:255"`
-	CreatedAt   time.Time      `json:"created_at" gorm:"index"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                   uint           `json:"id" gorm:"primaryKey"`
+	UserID               uint           `json:"user_id" gorm:"index" validate:"required"`
+	Username             string         `json:"username" gorm:"size:50"`
+	ImpersonatorID       *uint          `json:"impersonator_id,omitempty" gorm:"index"`
+	ImpersonatorUsername string         `json:"impersonator_username,omitempty" gorm:"size:50"`
+	LogType              LogType        `json:"log_type" gorm:"size:20;index" validate:"required"`
+	EntityType           EntityType     `json:"entity_type" gorm:"size:20;index" validate:"required"`
+	EntityID             uint           `json:"entity_id" gorm:"index"`
+	Description          string         `json:"description" gorm:"size:255"`
+	CreatedAt            time.Time      `json:"created_at" gorm:"index"`
+	DeletedAt            gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 } 
\ No newline at end of file
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// Login throttle subject kinds
+const (
+	ThrottleKindUsername = "username"
+	ThrottleKindIP       = "ip"
+	ThrottleKindMFA      = "mfa" // Failed second-factor codes, the subject is the user ID
+)
+
+// LoginThrottle tracks consecutive failed login attempts for a username, a client IP or the second factor of a user
+type LoginThrottle struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind" gorm:"size:20;uniqueIndex:idx_login_throttles_subject"`
+	Subject       string     `json:"subject" gorm:"size:100;uniqueIndex:idx_login_throttles_subject"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// MFARecoveryCode represents a hashed one-time recovery code for a user's second factor
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// OIDCLoginState represents a single sign-on login waiting for the provider callback.
+// It binds the callback to the PKCE code verifier and ID token nonce of the login.
+// Only the SHA-256 hash of the state parameter is stored.
+type OIDCLoginState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	Nonce        string    `json:"-" gorm:"size:64;not null"`
+	CodeVerifier string    `json:"-" gorm:"size:128;not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Issuer      string     `json:"issuer" gorm:"uniqueIndex:idx_user_identities_subject;size:255;not null"`
+	Subject     string     `json:"subject" gorm:"uniqueIndex:idx_user_identities_subject;size:255;not null"`
+	Email       string     `json:"email" gorm:"size:100"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization member roles
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+// Organization represents a tenant whose members share workers and projects
+type Organization struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	Name      string         `json:"name" gorm:"size:100" validate:"required,min=2,max=100"`
+	OwnerID   uint           `json:"owner_id" gorm:"index;not null"`
+	Personal  bool           `json:"personal" gorm:"default:false"` // Created automatically for every user
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// OrganizationMember represents a user's membership and role in an organization
+type OrganizationMember struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_org_members_org_user;not null"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_org_members_org_user;index;not null"`
+	Role           string    `json:"role" gorm:"size:20;not null" validate:"required,oneof=owner admin member"`
+	User           *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// OrganizationInvitation represents an invitation of an existing user into an organization
+type OrganizationInvitation struct {
+	ID             uint          `json:"id" gorm:"primaryKey"`
+	OrganizationID uint          `json:"organization_id" gorm:"index;not null"`
+	Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	UserID         uint          `json:"user_id" gorm:"index;not null"` // Invited user
+	InvitedByID    uint          `json:"invited_by_id" gorm:"not null"`
+	Role           string        `json:"role" gorm:"size:20;not null" validate:"required,oneof=admin member"`
+	Status         string        `json:"status" gorm:"size:20;index;default:pending"`
+	RespondedAt    *time.Time    `json:"responded_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordHistory stores the hash of a password a user had before, so that recent
+// passwords cannot be chosen again
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"index;not null"`
+	PasswordHash string    `json:"-" gorm:"size:255;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+)
+
+// Pay rate types
+const (
+	RateTypeHourly  = "hourly"
+	RateTypeDaily   = "daily"
+	RateTypeMonthly = "monthly"
+)
+
+// PayRate is the pay of a worker from its effective date until the next rate of the worker takes over
+type PayRate struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	WorkerID       uint      `json:"worker_id" gorm:"uniqueIndex:idx_pay_rate_worker_date;not null"`
+	RateType       string    `json:"rate_type" gorm:"size:10;not null" validate:"required,oneof=hourly daily monthly"`
+	Amount         float64   `json:"amount" validate:"required,gt=0"`
+	Currency       string    `json:"currency" gorm:"size:3;not null" validate:"required,iso4217"`
+	EffectiveFrom  time.Time `json:"effective_from" gorm:"uniqueIndex:idx_pay_rate_worker_date;not null" validate:"required"`
+	Notes          string    `json:"notes" gorm:"size:500" validate:"max=500"`
+	UserID         uint      `json:"user_id" gorm:"index;not null"` // Recorded by
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Payroll run states. Draft runs are recalculated on every change, finalized runs are locked.
+const (
+	PayrollStatusDraft     = "draft"
+	PayrollStatusFinalized = "finalized"
+)
+
+// PayrollRun computes the pay of all workers for a pay period from their approved hours and pay rates
+type PayrollRun struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	PeriodStart        time.Time      `json:"period_start" gorm:"index;not null" validate:"required"`
+	PeriodEnd          time.Time      `json:"period_end" gorm:"index;not null" validate:"required,gtefield=PeriodStart"`
+	OvertimeMultiplier float64        `json:"overtime_multiplier" gorm:"not null;default:1.5" validate:"omitempty,min=1,max=5"`
+	Status             string         `json:"status" gorm:"size:20;index;not null;default:draft"`
+	Notes              string         `json:"notes" gorm:"size:500" validate:"max=500"`
+	FinalizedByID      *uint          `json:"finalized_by_id,omitempty"`
+	FinalizedAt        *time.Time     `json:"finalized_at,omitempty"`
+	UserID             uint           `json:"user_id" gorm:"index;not null"` // Created by
+	OrganizationID     uint           `json:"organization_id" gorm:"index;not null"`
+	Lines              []PayrollLine  `json:"lines,omitempty" gorm:"foreignKey:PayrollRunID"`
+	Totals             []PayrollTotal `json:"totals" gorm:"-"` // Per currency, computed from the lines
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// PayrollLine is the pay of one worker under one pay rate during a payroll run. The worker's name,
+// position and rate are copied so that finalized runs do not change with later edits.
+type PayrollLine struct {
+	ID            uint    `json:"id" gorm:"primaryKey"`
+	PayrollRunID  uint    `json:"payroll_run_id" gorm:"index;not null"`
+	WorkerID      uint    `json:"worker_id" gorm:"index;not null"`
+	WorkerName    string  `json:"worker_name" gorm:"size:50"`
+	Position      string  `json:"position" gorm:"size:50"`
+	PayRateID     *uint   `json:"pay_rate_id"`
+	RateType      string  `json:"rate_type" gorm:"size:10"`
+	Rate          float64 `json:"rate"`
+	Currency      string  `json:"currency" gorm:"size:3"`
+	DaysWorked    int     `json:"days_worked"`
+	PaidDays      int     `json:"paid_days"` // Calendar days paid under a monthly rate
+	RegularHours  float64 `json:"regular_hours"`
+	OvertimeHours float64 `json:"overtime_hours"`
+	RegularPay    float64 `json:"regular_pay"`
+	OvertimePay   float64 `json:"overtime_pay"`
+	Total         float64 `json:"total"`
+	MissingRate   bool    `json:"missing_rate"` // Hours were approved before the worker had a pay rate
+}
+
+// PayrollTotal sums the lines of a payroll run paid in one currency
+type PayrollTotal struct {
+	Currency    string  `json:"currency"`
+	Workers     int     `json:"workers"`
+	RegularPay  float64 `json:"regular_pay"`
+	OvertimePay float64 `json:"overtime_pay"`
+	Total       float64 `json:"total"`
+}
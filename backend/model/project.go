@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,17 +9,34 @@ import (
 
 // Project represents a construction project with associated workers
 type Project struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" validate:"required,min=2,max=100"`
-	Description string         `json:"description" validate:"required,min=10,max=500"`
-	Status      string         `json:"status" validate:"required,oneof=active completed on_hold cancelled"`
-	StartDate   time.Time      `json:"start_date" validate:"required"`
-	EndDate     *time.Time     `json:"end_date"`
-	Latitude    float64        `json:"latitude" validate:"required,latitude"`
-	Longitude   float64        `json:"longitude" validate:"required,longitude"`
-	UserID      uint           `json:"user_id" gorm:"index" validate:"required"`
-	Workers     []Worker       `json:"workers" gorm:"many2many:worker_projects;joinForeignKey:ProjectID;joinReferences:WorkerID"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
-} 
\ No newline at end of file
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	Name           string          `json:"name" validate:"required,min=2,max=100"`
+	Description    string          `json:"description" validate:"required,min=10,max=500"`
+	Status         string          `json:"status" validate:"required,oneof=active completed on_hold cancelled"`
+	StartDate      time.Time       `json:"start_date" validate:"required"`
+	EndDate        *time.Time      `json:"end_date"`
+	Latitude       float64         `json:"latitude" validate:"required,latitude"`
+	Longitude      float64         `json:"longitude" validate:"required,longitude"`
+	GeofenceRadius int             `json:"geofence_radius" gorm:"not null;default:200" validate:"omitempty,min=10,max=10000"` // Meters around the site where attendance punches are accepted
+	UserID         uint            `json:"user_id" gorm:"index" validate:"required"` // Created by
+	OrganizationID uint            `json:"organization_id" gorm:"index" validate:"required"`
+	Workers        []Worker        `json:"workers" gorm:"many2many:worker_projects;joinForeignKey:ProjectID;joinReferences:WorkerID"`
+	Assignments    []WorkerProject `json:"assignments,omitempty" gorm:"foreignKey:ProjectID"` // Assignment details of the workers
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt  `json:"deleted_at" gorm:"index"`
+}
+
+// earthRadiusMeters is the mean radius of the earth used for distances between coordinates
+const earthRadiusMeters = 6371000
+
+// DistanceTo returns the great-circle distance in meters between the site and a position
+func (p Project) DistanceTo(latitude, longitude float64) float64 {
+	lat1 := p.Latitude * math.Pi / 180
+	lat2 := latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (longitude - p.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
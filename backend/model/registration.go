@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// EmailVerificationToken represents a single-use token that verifies a user's email
+// and activates the account. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RegistrationInvite represents an admin's invitation to create an account with a given email.
+// Only the SHA-256 hash of the token is stored.
+type RegistrationInvite struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Email       string     `json:"email" gorm:"size:100;index;not null" validate:"required,email"`
+	Role        string     `json:"role" gorm:"size:50;not null" validate:"required,max=50"` // Never the admin role
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	InvitedByID uint       `json:"invited_by_id" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
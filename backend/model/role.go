@@ -0,0 +1,108 @@
+package model
+
+import (
+	"time"
+)
+
+// Permission names. A permission is granted to users through their role.
+const (
+	PermWorkersRead       = "workers:read"
+	PermWorkersWrite      = "workers:write"
+	PermWorkersSalaryRead = "workers:salary:read"
+	PermProjectsRead      = "projects:read"
+	PermProjectsWrite     = "projects:write"
+	PermTimesheetsRead    = "timesheets:read"
+	PermTimesheetsWrite   = "timesheets:write"
+	PermTimesheetsApprove = "timesheets:approve"
+	PermPayrollRead       = "payroll:read"
+	PermPayrollWrite      = "payroll:write"
+	PermLeaveRead         = "leave:read"
+	PermLeaveWrite        = "leave:write"
+	PermLeaveApprove      = "leave:approve"
+	PermUsersRead         = "users:read"
+	PermUsersWrite        = "users:write"
+	PermUsersImpersonate  = "users:impersonate"
+	PermRolesManage       = "roles:manage"
+	PermLogsRead          = "logs:read"
+	PermSettingsManage    = "settings:manage"
+)
+
+// Built-in role names
+const (
+	RoleAdmin        = "admin"
+	RoleUser         = "user"
+	RoleSiteManager  = "site_manager"
+	RoleForeman      = "foreman"
+	RolePayrollClerk = "payroll_clerk"
+	RoleAuditor      = "auditor"
+)
+
+// Permission represents a named permission that can be bundled into roles
+type Permission struct {
+	Name        string `json:"name" gorm:"primaryKey;size:100"`
+	Description string `json:"description" gorm:"size:255"`
+}
+
+// Role represents a named bundle of permissions assigned to users
+type Role struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;size:50" validate:"required,min=2,max=50"`
+	Description string       `json:"description" gorm:"size:255" validate:"max=255"`
+	BuiltIn     bool         `json:"built_in" gorm:"default:false"` // Built-in roles cannot be deleted
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionName"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// AllPermissions is the catalog of permissions known to the system
+var AllPermissions = []Permission{
+	{Name: PermWorkersRead, Description: "View workers"},
+	{Name: PermWorkersWrite, Description: "Create, update and delete workers"},
+	{Name: PermWorkersSalaryRead, Description: "View worker salaries"},
+	{Name: PermProjectsRead, Description: "View projects and their assignments"},
+	{Name: PermProjectsWrite, Description: "Create, update and delete projects and assignments"},
+	{Name: PermTimesheetsRead, Description: "View timesheets"},
+	{Name: PermTimesheetsWrite, Description: "Record, edit and submit timesheet hours"},
+	{Name: PermTimesheetsApprove, Description: "Approve and reject submitted timesheets"},
+	{Name: PermPayrollRead, Description: "View pay rates and payroll runs"},
+	{Name: PermPayrollWrite, Description: "Manage pay rates and calculate and finalize payroll runs"},
+	{Name: PermLeaveRead, Description: "View leave requests, balances and absences"},
+	{Name: PermLeaveWrite, Description: "Request, edit and cancel leave"},
+	{Name: PermLeaveApprove, Description: "Approve and reject leave requests and set leave allowances"},
+	{Name: PermUsersRead, Description: "View user accounts"},
+	{Name: PermUsersWrite, Description: "Activate, deactivate and unlock user accounts"},
+	{Name: PermUsersImpersonate, Description: "Act as another user for support"},
+	{Name: PermRolesManage, Description: "Manage roles and assign them to users"},
+	{Name: PermLogsRead, Description: "View activity logs"},
+	{Name: PermSettingsManage, Description: "Manage system settings"},
+}
+
+// DefaultRoles are created on startup when missing. The admin role always holds every permission.
+var DefaultRoles = []Role{
+	{Name: RoleAdmin, Description: "Full access to the system"},
+	{Name: RoleUser, Description: "Manages own workers and projects", Permissions: permissions(
+		PermWorkersRead, PermWorkersWrite, PermWorkersSalaryRead, PermProjectsRead, PermProjectsWrite,
+		PermTimesheetsRead, PermTimesheetsWrite, PermTimesheetsApprove, PermPayrollRead, PermPayrollWrite,
+		PermLeaveRead, PermLeaveWrite, PermLeaveApprove)},
+	{Name: RoleSiteManager, Description: "Manages workers and projects on site", Permissions: permissions(
+		PermWorkersRead, PermWorkersWrite, PermProjectsRead, PermProjectsWrite,
+		PermTimesheetsRead, PermTimesheetsWrite, PermTimesheetsApprove,
+		PermLeaveRead, PermLeaveWrite, PermLeaveApprove)},
+	{Name: RoleForeman, Description: "Views crews and projects and records their hours", Permissions: permissions(
+		PermWorkersRead, PermProjectsRead, PermTimesheetsRead, PermTimesheetsWrite, PermLeaveRead, PermLeaveWrite)},
+	{Name: RolePayrollClerk, Description: "Views workers, their salaries and hours and runs payroll", Permissions: permissions(
+		PermWorkersRead, PermWorkersSalaryRead, PermProjectsRead, PermTimesheetsRead, PermPayrollRead, PermPayrollWrite,
+		PermLeaveRead)},
+	{Name: RoleAuditor, Description: "Read-only access including activity logs", Permissions: permissions(
+		PermWorkersRead, PermWorkersSalaryRead, PermProjectsRead, PermTimesheetsRead, PermPayrollRead, PermLeaveRead,
+		PermUsersRead, PermLogsRead)},
+}
+
+// permissions builds a permission list from names
+func permissions(names ...string) []Permission {
+	perms := make([]Permission, len(names))
+	for i, name := range names {
+		perms[i] = Permission{Name: name}
+	}
+	return perms
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// Session represents a login session. Every refresh token issued after a
+// successful login belongs to the same session (the refresh token family),
+// so revoking the session invalidates all of them at once.
+type Session struct {
+	ID             string     `json:"id" gorm:"primaryKey;size:64"`
+	UserID         uint       `json:"user_id" gorm:"index;not null"`
+	ImpersonatorID *uint      `json:"impersonator_id,omitempty" gorm:"index"` // Admin acting as the user, see ImpersonateUser
+	IPAddress      string     `json:"ip_address" gorm:"size:45"`              // Client IP of the most recent request
+	UserAgent      string     `json:"user_agent" gorm:"size:255"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Current        bool       `json:"current" gorm:"-"` // Set when listing the sessions of the requesting user
+}
+
+// RefreshToken represents a single-use refresh token belonging to a session.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	SessionID string     `json:"session_id" gorm:"index;size:64;not null"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// Setting keys
+const (
+	// SettingRequireAdminMFA forces users with the admin role to enroll in MFA
+	SettingRequireAdminMFA = "require_admin_mfa"
+	// SettingRegistrationMode controls who can create an account
+	SettingRegistrationMode = "registration_mode"
+	// SettingShiftMinRestHours is the minimum rest time between two shifts of a worker, in hours
+	SettingShiftMinRestHours = "shift_min_rest_hours"
+)
+
+// Registration modes
+const (
+	RegistrationOpen       = "open"        // Anyone can register and verifies their email
+	RegistrationInviteOnly = "invite_only" // Registration requires an invite from an admin
+	RegistrationDisabled   = "disabled"    // No new accounts can be created
+)
+
+// Setting represents a system-wide configuration value managed by admins
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"size:255"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
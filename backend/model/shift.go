@@ -0,0 +1,112 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// ShiftTimeLayout is the format of shift start and end times
+const ShiftTimeLayout = "15:04"
+
+// Reasons a worker cannot work a shift
+const (
+	ShiftConflictNotAssigned = "not_assigned" // Not assigned to the project on the shift's date
+	ShiftConflictOnLeave     = "on_leave"     // Approved leave on the shift's date
+	ShiftConflictOverlap     = "overlap"      // Another shift overlaps
+	ShiftConflictRest        = "rest"         // Less than the minimum rest time to or from another shift
+)
+
+// ShiftPosition is a position a shift needs and how many workers it needs in it
+type ShiftPosition struct {
+	Position  string `json:"position" validate:"required,max=50"`
+	Headcount int    `json:"headcount" validate:"required,min=1,max=500"`
+}
+
+// ShiftTemplate is a reusable shift of a project, e.g. an early shift from 06:00 to 14:00
+type ShiftTemplate struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	ProjectID      uint            `json:"project_id" gorm:"index;not null"`
+	Name           string          `json:"name" gorm:"size:50;not null" validate:"required,max=50"`
+	StartTime      string          `json:"start_time" gorm:"size:5;not null" validate:"required,datetime=15:04"`
+	EndTime        string          `json:"end_time" gorm:"size:5;not null" validate:"required,datetime=15:04"` // Before the start time for overnight shifts
+	Positions      []ShiftPosition `json:"positions" gorm:"serializer:json" validate:"max=20,dive"`
+	OrganizationID uint            `json:"organization_id" gorm:"index;not null"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Shift is a scheduled shift of a project on a date with the positions it needs and the workers working it
+type Shift struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	ProjectID      uint            `json:"project_id" gorm:"index;not null"`
+	TemplateID     *uint           `json:"template_id" gorm:"index"`
+	Date           time.Time       `json:"date" gorm:"index;not null" validate:"required"`
+	StartTime      string          `json:"start_time" gorm:"size:5;not null" validate:"omitempty,datetime=15:04"` // Taken from the template when empty
+	EndTime        string          `json:"end_time" gorm:"size:5;not null" validate:"omitempty,datetime=15:04"`
+	Positions      []ShiftPosition `json:"positions" gorm:"serializer:json" validate:"max=20,dive"`
+	Notes          string          `json:"notes" gorm:"size:500" validate:"max=500"`
+	UserID         uint            `json:"user_id" gorm:"index;not null"` // Scheduled by
+	OrganizationID uint            `json:"organization_id" gorm:"index;not null"`
+	Workers        []ShiftWorker   `json:"workers" gorm:"foreignKey:ShiftID" validate:"max=200,dive"`
+	Unfilled       []ShiftPosition `json:"unfilled" gorm:"-"` // Required positions without enough workers
+	Project        *Project        `json:"project,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ShiftWorker is a worker scheduled on a shift in one of its positions
+type ShiftWorker struct {
+	ID       uint    `json:"id" gorm:"primaryKey"`
+	ShiftID  uint    `json:"shift_id" gorm:"uniqueIndex:idx_shift_worker;not null"`
+	WorkerID uint    `json:"worker_id" gorm:"uniqueIndex:idx_shift_worker;index;not null" validate:"required"`
+	Position string  `json:"position" gorm:"size:50" validate:"max=50"` // The worker's own position when empty
+	Worker   *Worker `json:"worker,omitempty"`
+}
+
+// Start returns the time the shift begins
+func (s Shift) Start() time.Time {
+	return shiftTime(s.Date, s.StartTime)
+}
+
+// End returns the time the shift ends, on the next day when it ends before it starts
+func (s Shift) End() time.Time {
+	end := shiftTime(s.Date, s.EndTime)
+	if !end.After(s.Start()) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// UnfilledPositions returns the required positions with fewer workers than needed and how many are missing
+func (s Shift) UnfilledPositions() []ShiftPosition {
+	unfilled := make([]ShiftPosition, 0)
+	for _, required := range s.Positions {
+		missing := required.Headcount
+		for _, worker := range s.Workers {
+			if strings.EqualFold(strings.TrimSpace(worker.Position), strings.TrimSpace(required.Position)) {
+				missing--
+			}
+		}
+		if missing > 0 {
+			unfilled = append(unfilled, ShiftPosition{Position: required.Position, Headcount: missing})
+		}
+	}
+	return unfilled
+}
+
+// shiftTime combines the day of date with a time of day in ShiftTimeLayout
+func shiftTime(date time.Time, clock string) time.Time {
+	day := date.UTC()
+	parsed, _ := time.Parse(ShiftTimeLayout, clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+}
+
+// ShiftConflict is a reason a worker cannot work a shift
+type ShiftConflict struct {
+	WorkerID  uint      `json:"worker_id"`
+	Date      time.Time `json:"date"`
+	Reason    string    `json:"reason"`
+	ShiftID   *uint     `json:"shift_id,omitempty"`   // The other shift for overlap and rest conflicts
+	LeaveID   *uint     `json:"leave_id,omitempty"`   // The leave request for on_leave conflicts
+	RestHours *float64  `json:"rest_hours,omitempty"` // The rest time left for rest conflicts
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// SigningKey represents an asymmetric key pair used to sign access tokens.
+// The newest unretired key signs new tokens; retired keys keep verifying
+// the tokens they signed until those have expired.
+type SigningKey struct {
+	ID         string     `json:"kid" gorm:"primaryKey;size:64"`
+	Algorithm  string     `json:"alg" gorm:"size:10;not null"`          // "RS256" or "EdDSA"
+	PrivateKey string     `json:"-" gorm:"type:text;not null"`          // PEM encoded PKCS #8
+	PublicKey  string     `json:"public_key" gorm:"type:text;not null"` // PEM encoded PKIX
+	RetiredAt  *time.Time `json:"retired_at" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+// Skill kinds. Certifications are skills that are proven by a certificate and usually expire.
+const (
+	SkillKindSkill         = "skill"
+	SkillKindCertification = "certification"
+)
+
+// Skill is an entry in an organization's catalog of skills and certification types,
+// e.g. "Mobile crane operator" or "First aid"
+type Skill struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Name           string    `json:"name" gorm:"size:100;uniqueIndex:idx_skills_org_name" validate:"required,min=2,max=100"`
+	Kind           string    `json:"kind" gorm:"size:20;not null" validate:"required,oneof=skill certification"`
+	Description    string    `json:"description" gorm:"size:255" validate:"max=255"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_skills_org_name;not null" validate:"required"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WorkerSkill records that a worker holds a skill or certification. A skill without
+// an expiry date never expires.
+type WorkerSkill struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	WorkerID          uint       `json:"worker_id" gorm:"index;not null"`
+	SkillID           uint       `json:"skill_id" gorm:"index;not null" validate:"required"`
+	IssueDate         *time.Time `json:"issue_date"`
+	ExpiryDate        *time.Time `json:"expiry_date" gorm:"index"`
+	IssuingBody       string     `json:"issuing_body" gorm:"size:100" validate:"max=100"`
+	CertificateNumber string     `json:"certificate_number" gorm:"size:100" validate:"max=100"`
+	OrganizationID    uint       `json:"organization_id" gorm:"index;not null"`
+	Skill             *Skill     `json:"skill,omitempty"`
+	Worker            *Worker    `json:"worker,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
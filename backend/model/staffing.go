@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+)
+
+// StaffingRequirement states how many workers with a position or a skill a project needs
+// during a date range. Exactly one of Position and SkillID is set.
+type StaffingRequirement struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	ProjectID      uint      `json:"project_id" gorm:"index;not null"`
+	Position       string    `json:"position" gorm:"size:50" validate:"omitempty,min=2,max=50"`
+	SkillID        *uint     `json:"skill_id" gorm:"index"`
+	Headcount      int       `json:"headcount" gorm:"not null" validate:"required,min=1,max=1000"`
+	StartDate      time.Time `json:"start_date" validate:"required"`
+	EndDate        time.Time `json:"end_date" validate:"required,gtefield=StartDate"`
+	Notes          string    `json:"notes" gorm:"size:500" validate:"max=500"`
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	Skill          *Skill    `json:"skill,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// StaffingGap compares one requirement with the assigned workers matching it during a week
+type StaffingGap struct {
+	RequirementID uint   `json:"requirement_id"`
+	Position      string `json:"position,omitempty"`
+	SkillID       *uint  `json:"skill_id,omitempty"`
+	SkillName     string `json:"skill_name,omitempty"`
+	Required      int    `json:"required"`
+	Assigned      int    `json:"assigned"`  // Assigned workers matching the requirement
+	Shortfall     int    `json:"shortfall"` // Workers still missing
+	Surplus       int    `json:"surplus"`   // Matching workers beyond the headcount
+}
+
+// StaffingWeek summarizes the staffing of a project during the week starting on WeekStart (a Monday)
+type StaffingWeek struct {
+	WeekStart    time.Time     `json:"week_start"`
+	Required     int           `json:"required"`
+	Assigned     int           `json:"assigned"` // All workers assigned to the project and not on leave all week
+	OnLeave      int           `json:"on_leave"` // Assigned workers on approved leave all week
+	Shortfall    int           `json:"shortfall"`
+	Surplus      int           `json:"surplus"`
+	Unmatched    int           `json:"unmatched"` // Assigned workers matching no requirement of the week
+	Requirements []StaffingGap `json:"requirements"`
+}
+
+// ProjectStaffing is the weekly gap analysis of one project
+type ProjectStaffing struct {
+	ProjectID   uint           `json:"project_id"`
+	ProjectName string         `json:"project_name"`
+	Weeks       []StaffingWeek `json:"weeks"`
+}
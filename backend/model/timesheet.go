@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// Timesheet entry states. Entries are recorded as drafts, submitted for review and then
+// approved or rejected. Rejected entries can be corrected and submitted again.
+const (
+	TimesheetStatusDraft     = "draft"
+	TimesheetStatusSubmitted = "submitted"
+	TimesheetStatusApproved  = "approved"
+	TimesheetStatusRejected  = "rejected"
+)
+
+// TimesheetEntry records the hours a worker spent on a project on one day. The date is stored
+// as midnight UTC.
+type TimesheetEntry struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	WorkerID        uint       `json:"worker_id" gorm:"uniqueIndex:idx_timesheet_worker_project_date;not null" validate:"required"`
+	ProjectID       uint       `json:"project_id" gorm:"uniqueIndex:idx_timesheet_worker_project_date;index;not null" validate:"required"`
+	Date            time.Time  `json:"date" gorm:"uniqueIndex:idx_timesheet_worker_project_date;index;not null" validate:"required"`
+	RegularHours    float64    `json:"regular_hours" validate:"min=0,max=24"`
+	OvertimeHours   float64    `json:"overtime_hours" validate:"min=0,max=24"`
+	Notes           string     `json:"notes" gorm:"size:500" validate:"max=500"`
+	Status          string     `json:"status" gorm:"size:20;index;not null;default:draft"`
+	ReviewedByID    *uint      `json:"reviewed_by_id,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty" gorm:"size:255"`
+	UserID          uint       `json:"user_id" gorm:"index;not null"` // Recorded by
+	OrganizationID  uint       `json:"organization_id" gorm:"index;not null"`
+	Worker          *Worker    `json:"worker,omitempty"`
+	Project         *Project   `json:"project,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Editable reports whether the hours of the entry can still be changed
+func (e TimesheetEntry) Editable() bool {
+	return e.Status == TimesheetStatusDraft || e.Status == TimesheetStatusRejected
+}
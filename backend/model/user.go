@@ -8,14 +8,19 @@ import (
 
 // User represents a system user with authentication and role information
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Username     string         `json:"username" gorm:"uniqueIndex;size:50" validate:"required,min=3,max=50"`
-	Email        string         `json:"email" gorm:"uniqueIndex;size:100" validate:"required,email"`
-	PasswordHash string         `json:"-" gorm:"size:255" validate:"required"` // Not exposed in JSON
-	Role         string         `json:"role" gorm:"default:user" validate:"required,oneof=user admin"`
-	Active       bool           `json:"active" gorm:"default:true"`
-	LastLogin    *time.Time     `json:"last_login"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Username          string         `json:"username" gorm:"uniqueIndex;size:50" validate:"required,min=3,max=50"`
+	Email             string         `json:"email" gorm:"uniqueIndex;size:100" validate:"required,email"`
+	PasswordHash      string         `json:"-" gorm:"size:255" validate:"required"` // Not exposed in JSON
+	PasswordChangedAt *time.Time     `json:"password_changed_at"`
+	Role              string         `json:"role" gorm:"default:user" validate:"required,max=50"` // Name of a Role
+	Active            bool           `json:"active" gorm:"default:true"`
+	EmailVerified     bool           `json:"email_verified"`
+	MFAEnabled        bool           `json:"mfa_enabled" gorm:"default:false"`
+	MFASecret         string         `json:"-" gorm:"size:64"`  // Base32 TOTP secret, pending until MFA is enabled
+	MFALastStep       int64          `json:"-" gorm:"default:0"` // Last accepted TOTP time step, prevents code replay
+	LastLogin         *time.Time     `json:"last_login"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 } 
\ No newline at end of file
@@ -8,14 +8,18 @@ import (
 
 // Worker represents a construction worker with associated projects
 type Worker struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" validate:"required,min=2,max=50"`
-	Age       int            `json:"age" validate:"required,min=18,max=100"`
-	Position  string         `json:"position" validate:"required,min=2,max=50"`
-	Salary    int            `json:"salary" validate:"required,min=0"`
-	UserID    uint           `json:"user_id" gorm:"index" validate:"required"`
-	Projects  []Project      `json:"projects" gorm:"many2many:worker_projects;joinForeignKey:WorkerID;joinReferences:ProjectID"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt  `json:"deleted_at" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Name           string         `json:"name" validate:"required,min=2,max=50"`
+	Age            int            `json:"age" validate:"required,min=18,max=100"`
+	Position       string         `json:"position" validate:"required,min=2,max=50"`
+	Salary         int            `json:"salary" validate:"required,min=0"`
+	UserID         uint           `json:"user_id" gorm:"index" validate:"required"` // Created by
+	OrganizationID uint           `json:"organization_id" gorm:"index" validate:"required"`
+	Projects       []Project      `json:"projects" gorm:"many2many:worker_projects;joinForeignKey:WorkerID;joinReferences:ProjectID"`
+	Skills         []WorkerSkill  `json:"skills,omitempty" gorm:"foreignKey:WorkerID"`
+	ChangeReason   string         `json:"change_reason,omitempty" gorm:"-" validate:"max=255"` // Why the position or salary changed, kept in the history
+	EffectiveDate  *time.Time     `json:"effective_date,omitempty" gorm:"-"`                   // When the position or salary change takes effect, today by default
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
\ No newline at end of file
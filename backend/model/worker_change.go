@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// Fields of a worker whose changes are kept in the worker's history
+const (
+	WorkerFieldPosition = "position"
+	WorkerFieldSalary   = "salary"
+	WorkerFieldPayRate  = "pay_rate"
+)
+
+// WorkerChange records a change to the position or compensation of a worker, taking effect on
+// EffectiveDate. An empty OldValue marks the first value, an empty NewValue a removed pay rate.
+type WorkerChange struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	WorkerID       uint      `json:"worker_id" gorm:"index;not null"`
+	Field          string    `json:"field" gorm:"size:20;index;not null"`
+	OldValue       string    `json:"old_value" gorm:"size:100"`
+	NewValue       string    `json:"new_value" gorm:"size:100"`
+	EffectiveDate  time.Time `json:"effective_date" gorm:"index;not null"`
+	Reason         string    `json:"reason" gorm:"size:255"`
+	UserID         uint      `json:"user_id" gorm:"index;not null"` // Changed by
+	OrganizationID uint      `json:"organization_id" gorm:"index;not null"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// WorkerSnapshot is the position and compensation of a worker on a date
+type WorkerSnapshot struct {
+	WorkerID uint      `json:"worker_id"`
+	Name     string    `json:"name"`
+	Date     time.Time `json:"date"`
+	Position string    `json:"position"`
+	Salary   int       `json:"salary"`
+	PayRate  *PayRate  `json:"pay_rate"` // Rate in effect on the date, if any
+}
@@ -1,14 +1,51 @@
 package model
 
+import (
+	"time"
+)
+
 // WorkerProject represents the many-to-many relationship between workers and projects
-// with an additional user_id field to enforce data isolation between users
+// with an additional organization_id field to enforce data isolation between organizations.
+// An assignment without start or end date is open on that side.
 type WorkerProject struct {
-	WorkerID  uint `gorm:"primaryKey"`
-	ProjectID uint `gorm:"primaryKey"`
-	UserID    uint `gorm:"index;not null"` // Used to enforce user isolation
+	WorkerID       uint       `json:"worker_id" gorm:"primaryKey"`
+	ProjectID      uint       `json:"project_id" gorm:"primaryKey"`
+	StartDate      *time.Time `json:"start_date" gorm:"index"`
+	EndDate        *time.Time `json:"end_date" gorm:"index"`
+	Allocation     int        `json:"allocation" gorm:"not null;default:100" validate:"min=1,max=100"` // Percentage of the worker's time
+	Role           string     `json:"role" gorm:"size:50" validate:"max=50"`                           // Role on the project, e.g. "Foreman"
+	Notes          string     `json:"notes" gorm:"size:500" validate:"max=500"`
+	CrewID         *uint      `json:"crew_id" gorm:"index"`          // Crew the worker was assigned with, if any
+	UserID         uint       `json:"user_id" gorm:"index;not null"` // User who created the assignment
+	OrganizationID uint       `json:"organization_id" gorm:"index"`  // Used to enforce organization isolation
+	Worker         *Worker    `json:"worker,omitempty" gorm:"foreignKey:WorkerID"`
 }
 
 // TableName overrides the default table name
 func (WorkerProject) TableName() string {
 	return "worker_projects"
-} 
\ No newline at end of file
+}
+
+// ActiveOn reports whether the assignment covers the given date
+func (a WorkerProject) ActiveOn(date time.Time) bool {
+	if a.StartDate != nil && a.StartDate.After(date) {
+		return false
+	}
+	return a.EndDate == nil || !a.EndDate.Before(date)
+}
+
+// AllocationShare is the part of a worker's time taken by one project
+type AllocationShare struct {
+	ProjectID   uint   `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	Allocation  int    `json:"allocation"`
+}
+
+// AllocationConflict is a period in which a worker would be allocated more than 100 percent.
+// A missing start or end date means the period is open on that side.
+type AllocationConflict struct {
+	StartDate  *time.Time        `json:"start_date"`
+	EndDate    *time.Time        `json:"end_date"`
+	Allocation int               `json:"allocation"` // Total percentage including the checked assignment
+	Projects   []AllocationShare `json:"projects"`
+}
@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Message represents a notification addressed to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers notifications such as password reset links to users.
+// Implementations can send emails, SMS or simply print the message.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// LogNotifier writes notifications to stdout, intended for local development
+type LogNotifier struct{}
+
+// NewLogNotifier creates a new LogNotifier instance
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Send prints the message to stdout
+func (n *LogNotifier) Send(msg Message) error {
+	_, err := fmt.Fprintf(os.Stdout, "[%s] notification to %s\nSubject: %s\n%s\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
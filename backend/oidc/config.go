@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RoleMapping maps a value of the role claim to an application role
+type RoleMapping struct {
+	ClaimValue string
+	Role       string
+}
+
+// Config holds the settings of the OpenID Connect provider used for single sign-on
+type Config struct {
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	RoleClaim     string        // Claim holding the user's groups or roles, e.g. "groups"
+	RoleMappings  []RoleMapping // First matching mapping wins
+	DefaultRole   string        // Role of auto-provisioned users when no mapping matches
+	AutoProvision bool          // Create users that don't exist yet
+}
+
+// Enabled reports whether single sign-on is configured
+func (c Config) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// LoadConfig reads the provider settings from the OIDC_* environment variables
+func LoadConfig() Config {
+	return Config{
+		IssuerURL:     strings.TrimSuffix(os.Getenv("OIDC_ISSUER_URL"), "/"),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   getEnv("OIDC_REDIRECT_URL", "http://localhost:5173/auth/callback"),
+		Scopes:        strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		RoleClaim:     os.Getenv("OIDC_ROLE_CLAIM"),
+		RoleMappings:  parseRoleMappings(os.Getenv("OIDC_ROLE_MAPPING")),
+		DefaultRole:   getEnv("OIDC_DEFAULT_ROLE", "user"),
+		AutoProvision: getEnvBool("OIDC_AUTO_PROVISION", true),
+	}
+}
+
+// parseRoleMappings parses "claim-value=role" pairs separated by commas
+func parseRoleMappings(value string) []RoleMapping {
+	var mappings []RoleMapping
+	for _, pair := range strings.Split(value, ",") {
+		claimValue, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		claimValue, role = strings.TrimSpace(claimValue), strings.TrimSpace(role)
+		if claimValue != "" && role != "" {
+			mappings = append(mappings, RoleMapping{ClaimValue: claimValue, Role: role})
+		}
+	}
+	return mappings
+}
+
+// Helper function to get environment variables
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool reads a boolean environment variable, falling back to the default when unset or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
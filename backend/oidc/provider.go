@@ -0,0 +1,463 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// metadataRefreshInterval is how long discovered metadata and keys are cached
+	metadataRefreshInterval = time.Hour
+
+	// keysMissInterval limits key set reloads triggered by an unknown key ID
+	keysMissInterval = time.Minute
+
+	// maxResponseSize limits the size of provider responses
+	maxResponseSize = 1 << 20
+)
+
+var (
+	// ErrInvalidIDToken is returned when an ID token fails validation
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrProvisioningDisabled is returned when a user would have to be created while auto-provisioning is off
+	ErrProvisioningDisabled = errors.New("auto-provisioning is disabled")
+)
+
+// usernameInvalidChars matches characters removed from usernames derived from provider claims
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Metadata is the subset of the provider's discovery document used by the login flow
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the response of the token endpoint
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims holds the validated claims of an ID token
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+	Raw               jwt.MapClaims
+}
+
+// Account describes the user provisioned for a provider account that is not linked yet
+type Account struct {
+	Username string // Derived from the claims, the caller appends a number when it is taken
+	Email    string
+	Role     string // Mapped from the role claim, the default role otherwise
+}
+
+// Provider runs the authorization code flow with PKCE against an OpenID Connect provider.
+// The discovery document and signing keys are fetched lazily and cached.
+type Provider struct {
+	config     Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	metadata  *Metadata
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewProvider creates a provider for the given configuration. Requests to the provider are sent
+// with httpClient, or with a client with a 10 second timeout when it is nil.
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+// Config returns the provider configuration
+func (p *Provider) Config() Config {
+	return p.config
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL the browser is sent to for signing in at the provider
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	metadata, err := p.discover(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	// Public clients only send their ID, confidential clients authenticate with client_secret_basic
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token TokenResponse
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response contains no ID token")
+	}
+	return &token, nil
+}
+
+// VerifyIDToken validates the signature, issuer, audience, lifetime and nonce of an ID token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, raw, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if !raw.VerifyIssuer(metadata.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if !raw.VerifyAudience(p.config.ClientID, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+	if _, ok := raw["exp"]; !ok {
+		return nil, fmt.Errorf("%w: missing expiry", ErrInvalidIDToken)
+	}
+	if azp, ok := raw["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	}
+	if claimNonce, _ := raw["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	claims := &Claims{Raw: raw}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.PreferredUsername, _ = raw["preferred_username"].(string)
+	claims.Name, _ = raw["name"].(string)
+	switch verified := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// MapRole returns the application role for the role claim, or "" when no mapping matches
+func (p *Provider) MapRole(claims *Claims) string {
+	if p.config.RoleClaim == "" {
+		return ""
+	}
+
+	// The claim may be a single value or a list of values
+	var values []string
+	switch value := claims.Raw[p.config.RoleClaim].(type) {
+	case string:
+		values = []string{value}
+	case []interface{}:
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, mapping := range p.config.RoleMappings {
+		for _, value := range values {
+			if value == mapping.ClaimValue {
+				return mapping.Role
+			}
+		}
+	}
+	return ""
+}
+
+// NewAccount returns the user to provision for the claims of a provider account that is not
+// linked yet, or ErrProvisioningDisabled when auto-provisioning is off
+func (p *Provider) NewAccount(claims *Claims) (*Account, error) {
+	if !p.config.AutoProvision {
+		return nil, ErrProvisioningDisabled
+	}
+
+	role := p.MapRole(claims)
+	if role == "" {
+		role = p.config.DefaultRole
+	}
+
+	// The preferred username or the local part of the email, limited to safe characters
+	username := claims.PreferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+	username = usernameInvalidChars.ReplaceAllString(username, "")
+	if len(username) > 45 {
+		username = username[:45]
+	}
+	if len(username) < 3 {
+		username = "user" + username
+	}
+
+	return &Account{Username: username, Email: claims.Email, Role: role}, nil
+}
+
+// discover returns the provider metadata, fetching it and the key set when stale or forced
+func (p *Provider) discover(ctx context.Context, force bool) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && !force && time.Since(p.fetchedAt) < metadataRefreshInterval {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.IssuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata Metadata
+	if err := p.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("provider discovery failed: %w", err)
+	}
+
+	// The discovery document must belong to the configured issuer
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.config.IssuerURL {
+		return nil, fmt.Errorf("provider discovery failed: issuer %q does not match %q", metadata.Issuer, p.config.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("provider discovery failed: incomplete metadata")
+	}
+
+	keys, err := p.fetchKeys(ctx, metadata.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	p.metadata, p.keys, p.fetchedAt = &metadata, keys, time.Now()
+	return p.metadata, nil
+}
+
+// key returns the verification key with the given ID, reloading the key set once if it is unknown
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := lookupKey(p.keys, kid)
+	stale := time.Since(p.fetchedAt) >= keysMissInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if _, err := p.discover(ctx, true); err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		key, ok = lookupKey(p.keys, kid)
+		p.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// lookupKey finds a key by ID. Tokens without a key ID are accepted when the set has a single key.
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// jsonWebKey is a key of the provider's JWK set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// fetchKeys downloads and decodes the provider's signing keys
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("fetching provider keys failed: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Skip key types we cannot use instead of failing the whole set
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA, P-256/P-384 or Ed25519 public key
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.KeyType)
+}
+
+// do sends a request and decodes a JSON response
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,395 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID     = "studio"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:5173/auth/callback"
+	testKeyID        = "test-key"
+)
+
+// mockGrant is an authorization code issued by the mock provider
+type mockGrant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+// mockProvider is a local OpenID Connect provider serving discovery, authorization, token and key endpoints
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// issuer overrides the issuer announced in the discovery document
+	issuer string
+	// claims adjusts the claims of issued ID tokens
+	claims func(jwt.MapClaims)
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	m := &mockProvider{t: t, key: key, grants: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("/authorize", m.handleAuthorize)
+	mux.HandleFunc("/token", m.handleToken)
+	mux.HandleFunc("/keys", m.handleKeys)
+	m.server = httptest.NewTLSServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// provider returns a provider configured for the mock, trusting its test certificate
+func (m *mockProvider) provider(configure func(*Config)) *Provider {
+	config := Config{
+		IssuerURL:     m.server.URL,
+		ClientID:      testClientID,
+		ClientSecret:  testClientSecret,
+		RedirectURL:   testRedirectURL,
+		Scopes:        []string{"openid", "email", "profile"},
+		RoleClaim:     "groups",
+		RoleMappings:  []RoleMapping{{ClaimValue: "site-managers", Role: "site_manager"}},
+		DefaultRole:   "user",
+		AutoProvision: true,
+	}
+	if configure != nil {
+		configure(&config)
+	}
+	return NewProvider(config, m.server.Client())
+}
+
+func (m *mockProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.server.URL
+	if m.issuer != "" {
+		issuer = m.issuer
+	}
+	writeJSON(w, map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": m.server.URL + "/authorize",
+		"token_endpoint":         m.server.URL + "/token",
+		"jwks_uri":               m.server.URL + "/keys",
+	})
+}
+
+// handleAuthorize signs the user in right away and redirects back with a code
+func (m *mockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.grants[code] = mockGrant{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: query.Get("redirect_uri"),
+	}
+	m.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// handleToken redeems a code once, checking the client credentials, redirect URI and PKCE verifier
+func (m *mockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || secret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") || grant.challenge != CodeChallenge(r.PostForm.Get("code_verifier")) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, TokenResponse{
+		AccessToken: "access-token",
+		TokenType:   "Bearer",
+		IDToken:     m.idToken(grant.nonce),
+		ExpiresIn:   300,
+	})
+}
+
+func (m *mockProvider) handleKeys(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	writeJSON(w, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(m.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// idToken issues an ID token for a user of the provider, adjusted by the claims hook
+func (m *mockProvider) idToken(nonce string) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                m.server.URL,
+		"sub":                "user-1",
+		"aud":                testClientID,
+		"azp":                testClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              nonce,
+		"email":              "jane.doe@example.com",
+		"email_verified":     true,
+		"preferred_username": "jane.doe",
+		"name":               "Jane Doe",
+		"groups":             []string{"staff", "site-managers"},
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+	return m.sign(claims, m.key, testKeyID)
+}
+
+func (m *mockProvider) sign(claims jwt.MapClaims, key *rsa.PrivateKey, kid string) string {
+	m.t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatalf("signing ID token: %v", err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// login runs the browser part of the flow and returns the code sent to the redirect URL
+func (m *mockProvider) login(t *testing.T, authURL, state string) string {
+	t.Helper()
+
+	client := *m.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization request: status %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("redirect location: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), testRedirectURL) || location.Query().Get("state") != state {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+	return location.Query().Get("code")
+}
+
+func TestLoginFlow(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.provider(nil)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, mock.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL %s does not use the discovered endpoint", authURL)
+	}
+	parsed, _ := url.Parse(authURL)
+	if got := parsed.Query().Get("code_challenge"); got != CodeChallenge("verifier-1") {
+		t.Errorf("code_challenge = %q, want the S256 challenge of the verifier", got)
+	}
+
+	code := mock.login(t, authURL, "state-1")
+	token, err := provider.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Issuer != mock.server.URL || claims.Subject != "user-1" || claims.Email != "jane.doe@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if role := provider.MapRole(claims); role != "site_manager" {
+		t.Errorf("MapRole = %q, want site_manager", role)
+	}
+
+	// Codes are single-use
+	if _, err := provider.Exchange(ctx, code, "verifier-1"); err == nil {
+		t.Error("Exchange accepted a code twice")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	mock := newMockProvider(t)
+	mock.issuer = "https://other.example.com"
+
+	if _, err := mock.provider(nil).AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Fatal("AuthCodeURL accepted a discovery document of another issuer")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.provider(nil)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code := mock.login(t, authURL, "state-1")
+
+	if _, err := provider.Exchange(ctx, code, "another-verifier"); err == nil {
+		t.Fatal("Exchange succeeded with the wrong PKCE verifier")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.provider(nil)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		nonce  string
+		claims func(jwt.MapClaims)
+		key    *rsa.PrivateKey
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "missing nonce", claims: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "other audience", claims: func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{name: "other authorized party", claims: func(c jwt.MapClaims) { c["azp"] = "other-client" }},
+		{name: "other issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://other.example.com" }},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "missing expiry", claims: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "missing subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{name: "unknown key", key: otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.claims = tt.claims
+			defer func() { mock.claims = nil }()
+
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			rawIDToken := mock.idToken("nonce-1")
+			if tt.key != nil {
+				parsed, _, err := new(jwt.Parser).ParseUnverified(rawIDToken, jwt.MapClaims{})
+				if err != nil {
+					t.Fatalf("parsing ID token: %v", err)
+				}
+				rawIDToken = mock.sign(parsed.Claims.(jwt.MapClaims), tt.key, testKeyID)
+			}
+
+			_, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("VerifyIDToken error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+
+	// The unmodified token is accepted, so the failures above come from the changed claims
+	if _, err := provider.VerifyIDToken(ctx, mock.idToken("nonce-1"), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken rejected a valid token: %v", err)
+	}
+}
+
+func TestNewAccount(t *testing.T) {
+	mock := newMockProvider(t)
+
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		claims    Claims
+		want      *Account
+		wantErr   error
+	}{
+		{
+			name:   "mapped role",
+			claims: Claims{Email: "jane.doe@example.com", PreferredUsername: "jane.doe", Raw: jwt.MapClaims{"groups": []interface{}{"staff", "site-managers"}}},
+			want:   &Account{Username: "jane.doe", Email: "jane.doe@example.com", Role: "site_manager"},
+		},
+		{
+			name:   "default role and username from email",
+			claims: Claims{Email: "john+site@example.com", Raw: jwt.MapClaims{"groups": "staff"}},
+			want:   &Account{Username: "johnsite", Email: "john+site@example.com", Role: "user"},
+		},
+		{
+			name:   "short username",
+			claims: Claims{Email: "al@example.com", PreferredUsername: "al", Raw: jwt.MapClaims{}},
+			want:   &Account{Username: "useral", Email: "al@example.com", Role: "user"},
+		},
+		{
+			name:      "auto-provisioning disabled",
+			configure: func(c *Config) { c.AutoProvision = false },
+			claims:    Claims{Email: "jane.doe@example.com", Raw: jwt.MapClaims{}},
+			wantErr:   ErrProvisioningDisabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := mock.provider(tt.configure).NewAccount(&tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAccount error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && *account != *tt.want {
+				t.Errorf("NewAccount = %+v, want %+v", *account, *tt.want)
+			}
+		})
+	}
+}
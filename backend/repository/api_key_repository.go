@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrAPIKeyInvalid is returned when an API key is unknown, revoked, expired or its owner is inactive
+var ErrAPIKeyInvalid = errors.New("invalid API key")
+
+// apiKeyLastUsedResolution limits how often the last-used timestamp of a key is written
+const apiKeyLastUsedResolution = time.Minute
+
+// APIKeyRepository handles database operations for personal API keys
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository instance
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{
+		db: config.DB,
+	}
+}
+
+// Create stores a new API key
+func (r *APIKeyRepository) Create(key *model.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetUserKeys retrieves the API keys of a user, newest first
+func (r *APIKeyRepository) GetUserKeys(userID uint) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke revokes an API key of a user
+func (r *APIKeyRepository) Revoke(id, userID uint) error {
+	result := r.db.Model(&model.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves an active API key and its owner from the hash of the secret
+// and records when the key was last used
+func (r *APIKeyRepository) AuthenticateAPIKey(keyHash string) (*model.APIKey, *model.User, error) {
+	var key model.APIKey
+	if err := r.db.Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAPIKeyInvalid
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	var user model.User
+	if err := r.db.First(&user, key.UserID).Error; err != nil || !user.Active {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	// Only write the timestamp once per resolution window to keep requests cheap
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution {
+		r.db.Model(&key).Update("last_used_at", now)
+	}
+
+	return &key, &user, nil
+}
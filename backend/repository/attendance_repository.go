@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrNoSite is returned when a punch names no project and the worker is not assigned to any site that day
+var ErrNoSite = errors.New("worker is not assigned to any site on that date")
+
+// AttendanceRepository handles database operations for attendance punches
+type AttendanceRepository struct {
+	db *gorm.DB
+}
+
+// NewAttendanceRepository creates a new AttendanceRepository instance
+func NewAttendanceRepository() *AttendanceRepository {
+	return &AttendanceRepository{
+		db: config.DB,
+	}
+}
+
+// RecordPunch stores a clock-in or clock-out. Without a project the punch goes to the nearest site the
+// worker is assigned to that day. Punches outside the site's geofence or by workers not assigned to
+// the project are flagged.
+func (r *AttendanceRepository) RecordPunch(punch *model.AttendancePunch, orgID uint, userID uint) error {
+	worker := &model.Worker{}
+	if err := r.db.Where("id = ? AND organization_id = ?", punch.WorkerID, orgID).First(worker).Error; err != nil {
+		return err
+	}
+
+	date := dateOnly(punch.Timestamp.UTC())
+	var sites []model.Project
+	err := activeAssignments(r.db.Model(&model.Project{}), date).
+		Joins("JOIN worker_projects ON worker_projects.project_id = projects.id").
+		Where("worker_projects.worker_id = ? AND projects.organization_id = ?", punch.WorkerID, orgID).
+		Find(&sites).Error
+	if err != nil {
+		return err
+	}
+
+	var project *model.Project
+	assigned := false
+	if punch.ProjectID == 0 {
+		if len(sites) == 0 {
+			return ErrNoSite
+		}
+		for i := range sites {
+			if project == nil || sites[i].DistanceTo(punch.Latitude, punch.Longitude) < project.DistanceTo(punch.Latitude, punch.Longitude) {
+				project = &sites[i]
+			}
+		}
+		assigned = true
+	} else {
+		for i := range sites {
+			if sites[i].ID == punch.ProjectID {
+				project = &sites[i]
+				assigned = true
+			}
+		}
+		if project == nil {
+			project = &model.Project{}
+			if err := r.db.Where("id = ? AND organization_id = ?", punch.ProjectID, orgID).First(project).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	punch.ID = 0
+	punch.ProjectID = project.ID
+	punch.Distance = math.Round(project.DistanceTo(punch.Latitude, punch.Longitude))
+	punch.Status = model.PunchStatusAccepted
+	punch.FlagReason = ""
+	switch {
+	case !assigned:
+		punch.Status = model.PunchStatusFlagged
+		punch.FlagReason = model.PunchFlagNotAssigned
+	case punch.Distance > float64(project.GeofenceRadius):
+		punch.Status = model.PunchStatusFlagged
+		punch.FlagReason = model.PunchFlagOutsideGeofence
+	}
+	punch.UserID = userID
+	punch.OrganizationID = orgID
+
+	return r.db.Omit("Worker", "Project").Create(punch).Error
+}
+
+// GetPunches retrieves the punches of an organization, newest first. Supported filters are
+// "worker_id", "project_id", "status", "from" and "until".
+func (r *AttendanceRepository) GetPunches(orgID uint, filters map[string]interface{}, page int, pageSize int) ([]model.AttendancePunch, int64, error) {
+	var punches []model.AttendancePunch
+	var total int64
+	query := r.db.Model(&model.AttendancePunch{}).Where("organization_id = ?", orgID)
+
+	// Apply filters
+	for key, value := range filters {
+		switch key {
+		case "worker_id", "project_id", "status":
+			query = query.Where(key+" = ?", value)
+		case "from":
+			query = query.Where("timestamp >= ?", value)
+		case "until":
+			query = query.Where("timestamp < ?", value.(time.Time).AddDate(0, 0, 1))
+		}
+	}
+
+	// Count total records (before pagination)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	if page > 0 && pageSize > 0 {
+		offset := (page - 1) * pageSize
+		query = query.Offset(offset).Limit(pageSize)
+	}
+
+	err := query.Preload("Worker").Preload("Project").
+		Order("timestamp DESC, id DESC").
+		Find(&punches).Error
+	return punches, total, err
+}
+
+// GetRoster lists the workers assigned to or punching at a site on one day with their punches
+func (r *AttendanceRepository) GetRoster(projectID uint, orgID uint, date time.Time) ([]model.AttendanceRecord, error) {
+	project := &model.Project{}
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(project).Error; err != nil {
+		return nil, err
+	}
+
+	date = dateOnly(date)
+	var assignments []model.WorkerProject
+	err := activeAssignments(r.db.Preload("Worker"), date).
+		Where("project_id = ? AND organization_id = ?", projectID, orgID).
+		Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var punches []model.AttendancePunch
+	err = r.db.Preload("Worker").
+		Where("project_id = ? AND organization_id = ? AND timestamp >= ? AND timestamp < ?", projectID, orgID, date, date.AddDate(0, 0, 1)).
+		Order("timestamp, id").
+		Find(&punches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]*model.AttendanceRecord)
+	for _, assignment := range assignments {
+		if assignment.Worker == nil {
+			continue
+		}
+		records[assignment.WorkerID] = &model.AttendanceRecord{
+			WorkerID:   assignment.WorkerID,
+			WorkerName: assignment.Worker.Name,
+			Position:   assignment.Worker.Position,
+			Assigned:   true,
+			Punches:    []model.AttendancePunch{},
+		}
+	}
+	for _, punch := range punches {
+		record, ok := records[punch.WorkerID]
+		if !ok {
+			if punch.Worker == nil {
+				continue
+			}
+			record = &model.AttendanceRecord{
+				WorkerID:   punch.WorkerID,
+				WorkerName: punch.Worker.Name,
+				Position:   punch.Worker.Position,
+				Punches:    []model.AttendancePunch{},
+			}
+			records[punch.WorkerID] = record
+		}
+		punch.Worker = nil
+		record.Punches = append(record.Punches, punch)
+	}
+
+	today := dateOnly(time.Now().UTC())
+	roster := make([]model.AttendanceRecord, 0, len(records))
+	for _, record := range records {
+		summarizeAttendance(record, date.Before(today))
+		roster = append(roster, *record)
+	}
+	sort.Slice(roster, func(i, j int) bool {
+		if roster[i].WorkerName != roster[j].WorkerName {
+			return roster[i].WorkerName < roster[j].WorkerName
+		}
+		return roster[i].WorkerID < roster[j].WorkerID
+	})
+	return roster, nil
+}
+
+// GetMissingClockOuts lists the clock-ins between from and until that were not followed by a
+// clock-out on the same day. Today is left out because the workers may still be on site.
+func (r *AttendanceRepository) GetMissingClockOuts(orgID uint, projectID uint, from time.Time, until time.Time) ([]model.MissingClockOut, error) {
+	end := dateOnly(until).AddDate(0, 0, 1)
+	if today := dateOnly(time.Now().UTC()); end.After(today) {
+		end = today
+	}
+
+	query := r.db.Preload("Worker").Preload("Project").
+		Where("organization_id = ? AND timestamp >= ? AND timestamp < ?", orgID, dateOnly(from), end)
+	if projectID != 0 {
+		query = query.Where("project_id = ?", projectID)
+	}
+	var punches []model.AttendancePunch
+	if err := query.Order("timestamp, id").Find(&punches).Error; err != nil {
+		return nil, err
+	}
+
+	missing := []model.MissingClockOut{}
+	for _, day := range groupPunches(punches) {
+		_, open, _ := pairPunches(day)
+		for _, punch := range open {
+			entry := model.MissingClockOut{
+				PunchID:   punch.ID,
+				WorkerID:  punch.WorkerID,
+				ProjectID: punch.ProjectID,
+				ClockIn:   punch.Timestamp,
+			}
+			if punch.Worker != nil {
+				entry.WorkerName = punch.Worker.Name
+			}
+			if punch.Project != nil {
+				entry.ProjectName = punch.Project.Name
+			}
+			missing = append(missing, entry)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].ClockIn.Before(missing[j].ClockIn)
+	})
+	return missing, nil
+}
+
+// ConvertToTimesheets turns the accepted punches between from and until into draft timesheet
+// entries, one per worker, site and day. Hours beyond regularHours a day count as overtime. Days
+// with unmatched punches, without assignment, with submitted or approved entries or paid by a
+// finalized payroll run are skipped.
+func (r *AttendanceRepository) ConvertToTimesheets(orgID uint, userID uint, projectID uint, from time.Time, until time.Time, regularHours float64) (*model.AttendanceConversion, error) {
+	query := r.db.Where("organization_id = ? AND status = ? AND timestamp >= ? AND timestamp < ?",
+		orgID, model.PunchStatusAccepted, dateOnly(from), dateOnly(until).AddDate(0, 0, 1))
+	if projectID != 0 {
+		query = query.Where("project_id = ?", projectID)
+	}
+	var punches []model.AttendancePunch
+	if err := query.Order("timestamp, id").Find(&punches).Error; err != nil {
+		return nil, err
+	}
+
+	result := &model.AttendanceConversion{
+		Skipped: []model.AttendanceSkip{},
+		Entries: []model.TimesheetEntry{},
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, day := range groupPunches(punches) {
+			first := day[0]
+			skip := model.AttendanceSkip{
+				WorkerID:  first.WorkerID,
+				ProjectID: first.ProjectID,
+				Date:      dateOnly(first.Timestamp.UTC()),
+			}
+
+			pairs, open, stray := pairPunches(day)
+			switch {
+			case len(open) > 0:
+				skip.Reason = "missing clock-out"
+			case len(stray) > 0:
+				skip.Reason = "clock-out without clock-in"
+			}
+			if skip.Reason != "" {
+				result.Skipped = append(result.Skipped, skip)
+				continue
+			}
+
+			hours := workedHours(pairs)
+			if hours == 0 {
+				continue
+			}
+			entry := model.TimesheetEntry{
+				WorkerID:       skip.WorkerID,
+				ProjectID:      skip.ProjectID,
+				Date:           skip.Date,
+				RegularHours:   math.Min(hours, regularHours),
+				OvertimeHours:  math.Round((hours-math.Min(hours, regularHours))*100) / 100,
+				Notes:          "Recorded from attendance punches",
+				Status:         model.TimesheetStatusDraft,
+				UserID:         userID,
+				OrganizationID: orgID,
+			}
+
+			created, err := saveTimesheetEntry(tx, &entry)
+			switch {
+			case errors.Is(err, ErrTimesheetLocked), errors.Is(err, ErrNotAssigned), errors.Is(err, ErrTooManyHours), errors.Is(err, ErrPayPeriodFinalized):
+				skip.Reason = err.Error()
+				result.Skipped = append(result.Skipped, skip)
+				continue
+			case err != nil:
+				return err
+			}
+
+			if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+			result.Entries = append(result.Entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// punchPair is a clock-in matched with the clock-out that follows it
+type punchPair struct {
+	in  model.AttendancePunch
+	out model.AttendancePunch
+}
+
+// groupPunches splits punches ordered by time into the punches of each worker at each site per day
+func groupPunches(punches []model.AttendancePunch) [][]model.AttendancePunch {
+	type dayKey struct {
+		workerID  uint
+		projectID uint
+		date      time.Time
+	}
+
+	var keys []dayKey
+	days := make(map[dayKey][]model.AttendancePunch)
+	for _, punch := range punches {
+		key := dayKey{punch.WorkerID, punch.ProjectID, dateOnly(punch.Timestamp.UTC())}
+		if _, ok := days[key]; !ok {
+			keys = append(keys, key)
+		}
+		days[key] = append(days[key], punch)
+	}
+
+	grouped := make([][]model.AttendancePunch, 0, len(keys))
+	for _, key := range keys {
+		grouped = append(grouped, days[key])
+	}
+	return grouped
+}
+
+// pairPunches matches the punches of one worker at one site on one day, ordered by time, into
+// clock-in and clock-out pairs. A clock-in followed by another clock-in or by nothing stays open,
+// a clock-out without a clock-in before it is stray.
+func pairPunches(punches []model.AttendancePunch) (pairs []punchPair, open []model.AttendancePunch, stray []model.AttendancePunch) {
+	var clockIn *model.AttendancePunch
+	for i := range punches {
+		switch punches[i].Type {
+		case model.PunchTypeIn:
+			if clockIn != nil {
+				open = append(open, *clockIn)
+			}
+			clockIn = &punches[i]
+		case model.PunchTypeOut:
+			if clockIn == nil {
+				stray = append(stray, punches[i])
+				continue
+			}
+			pairs = append(pairs, punchPair{in: *clockIn, out: punches[i]})
+			clockIn = nil
+		}
+	}
+	if clockIn != nil {
+		open = append(open, *clockIn)
+	}
+	return pairs, open, stray
+}
+
+// workedHours sums the time between the clock-ins and clock-outs, rounded to hundredths of an hour
+func workedHours(pairs []punchPair) float64 {
+	var worked time.Duration
+	for _, pair := range pairs {
+		worked += pair.out.Timestamp.Sub(pair.in.Timestamp)
+	}
+	return math.Round(worked.Hours()*100) / 100
+}
+
+// summarizeAttendance fills in the status, times and hours of a roster record from its punches.
+// An open clock-in on a past day is reported as a missing clock-out.
+func summarizeAttendance(record *model.AttendanceRecord, pastDay bool) {
+	record.Status = model.AttendanceAbsent
+	for _, punch := range record.Punches {
+		if punch.Status == model.PunchStatusFlagged {
+			record.Flagged++
+		}
+	}
+	if len(record.Punches) == 0 {
+		return
+	}
+
+	pairs, open, _ := pairPunches(record.Punches)
+	record.Hours = workedHours(pairs)
+
+	for i := range record.Punches {
+		punch := record.Punches[i]
+		if punch.Type == model.PunchTypeIn && record.ClockIn == nil {
+			record.ClockIn = &punch.Timestamp
+		}
+		if punch.Type == model.PunchTypeOut {
+			record.ClockOut = &punch.Timestamp
+		}
+	}
+
+	record.Status = model.AttendanceClockedOut
+	if record.Punches[len(record.Punches)-1].Type == model.PunchTypeIn {
+		record.Status = model.AttendanceClockedIn
+	}
+	record.MissingClockOut = pastDay && len(open) > 0
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCrewExists is returned when a crew with the same name already exists in the organization
+	ErrCrewExists = errors.New("crew already exists")
+	// ErrCrewLead is returned when the lead of a crew is not one of its members
+	ErrCrewLead = errors.New("crew lead is not a member of the crew")
+	// ErrCrewMember is returned when a worker already belongs to another crew
+	ErrCrewMember = errors.New("worker already belongs to another crew")
+	// ErrCrewEmpty is returned when assigning a crew without members
+	ErrCrewEmpty = errors.New("crew has no members")
+)
+
+// CrewRepository handles database operations for crews and their project assignments
+type CrewRepository struct {
+	db *gorm.DB
+}
+
+// NewCrewRepository creates a new CrewRepository instance
+func NewCrewRepository() *CrewRepository {
+	return &CrewRepository{
+		db: config.DB,
+	}
+}
+
+// GetAll retrieves the crews of an organization with their lead and members
+func (r *CrewRepository) GetAll(orgID uint) ([]model.Crew, error) {
+	var crews []model.Crew
+	err := r.db.Preload("Lead").Preload("Members.Worker").
+		Where("organization_id = ?", orgID).
+		Order("name").
+		Find(&crews).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range crews {
+		crews[i].MemberIDs = memberIDs(crews[i].Members)
+	}
+	return crews, nil
+}
+
+// GetByID retrieves a crew by ID within an organization
+func (r *CrewRepository) GetByID(id uint, orgID uint) (*model.Crew, error) {
+	var crew model.Crew
+	err := r.db.Preload("Lead").Preload("Members.Worker").
+		Where("id = ? AND organization_id = ?", id, orgID).
+		First(&crew).Error
+	if err != nil {
+		return nil, err
+	}
+	crew.MemberIDs = memberIDs(crew.Members)
+	return &crew, nil
+}
+
+// Create adds a crew with the workers listed in its MemberIDs
+func (r *CrewRepository) Create(crew *model.Crew, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		crew.ID = 0
+		crew.OrganizationID = orgID
+		crew.UserID = userID
+
+		if err := checkCrew(tx, crew); err != nil {
+			return err
+		}
+		if err := tx.Omit("Members", "Lead").Create(crew).Error; err != nil {
+			return err
+		}
+		return saveCrewMembers(tx, crew)
+	})
+}
+
+// Update changes a crew and replaces its members. Existing project assignments are not changed.
+func (r *CrewRepository) Update(crew *model.Crew, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.Crew{}
+		if err := tx.Where("id = ? AND organization_id = ?", crew.ID, orgID).First(existing).Error; err != nil {
+			return err
+		}
+
+		// Keep the creating user, owning organization and creation time
+		crew.OrganizationID = existing.OrganizationID
+		crew.UserID = existing.UserID
+		crew.CreatedAt = existing.CreatedAt
+
+		if err := checkCrew(tx, crew); err != nil {
+			return err
+		}
+		if err := tx.Omit("Members", "Lead").Save(crew).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("crew_id = ?", crew.ID).Delete(&model.CrewMember{}).Error; err != nil {
+			return err
+		}
+		return saveCrewMembers(tx, crew)
+	})
+}
+
+// Delete removes a crew. Its workers stay assigned to their projects without a crew.
+func (r *CrewRepository) Delete(id uint, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		crew := &model.Crew{}
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(crew).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("crew_id = ?", crew.ID).Delete(&model.CrewMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.WorkerProject{}).Where("crew_id = ?", crew.ID).Update("crew_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(crew).Error
+	})
+}
+
+// AssignToProject assigns every member of a crew to a project in one transaction, with the dates,
+// allocation, role and notes of the given assignment. Nothing is assigned when any member is
+// already on the project. The members are checked for over-allocation in the same transaction;
+// their conflicts are returned with ErrOverAllocated unless force is set, in which case the
+// assignments are saved and the conflicts are returned for the audit log.
+func (r *CrewRepository) AssignToProject(crewID uint, assignment model.WorkerProject, orgID uint, userID uint, force bool) ([]model.WorkerAllocationConflict, error) {
+	conflicts := make([]model.WorkerAllocationConflict, 0)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND organization_id = ?", assignment.ProjectID, orgID).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND organization_id = ?", crewID, orgID).First(&model.Crew{}).Error; err != nil {
+			return err
+		}
+
+		var members []model.CrewMember
+		if err := tx.Preload("Worker").Where("crew_id = ?", crewID).Order("worker_id").Find(&members).Error; err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return ErrCrewEmpty
+		}
+
+		var assigned int64
+		err := tx.Model(&model.WorkerProject{}).
+			Where("project_id = ? AND worker_id IN ?", assignment.ProjectID, memberIDs(members)).
+			Count(&assigned).Error
+		if err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return ErrAlreadyAssigned
+		}
+
+		assignments := make([]model.WorkerProject, 0, len(members))
+		for _, member := range members {
+			crew := crewID
+			memberAssignment := model.WorkerProject{
+				WorkerID:       member.WorkerID,
+				ProjectID:      assignment.ProjectID,
+				StartDate:      assignment.StartDate,
+				EndDate:        assignment.EndDate,
+				Allocation:     assignment.Allocation,
+				Role:           assignment.Role,
+				Notes:          assignment.Notes,
+				CrewID:         &crew,
+				UserID:         userID,
+				OrganizationID: orgID,
+			}
+
+			memberConflicts, err := checkAllocation(tx, &memberAssignment, orgID)
+			if err != nil {
+				return err
+			}
+			if len(memberConflicts) > 0 {
+				conflict := model.WorkerAllocationConflict{WorkerID: member.WorkerID, Conflicts: memberConflicts}
+				if member.Worker != nil {
+					conflict.WorkerName = member.Worker.Name
+				}
+				conflicts = append(conflicts, conflict)
+			}
+			assignments = append(assignments, memberAssignment)
+		}
+		if len(conflicts) > 0 && !force {
+			return ErrOverAllocated
+		}
+
+		return tx.Omit("Worker").Create(&assignments).Error
+	})
+	return conflicts, err
+}
+
+// UnassignFromProject removes the workers assigned to a project with a crew, and reports how many were removed.
+// Workers assigned to the project on their own are kept.
+func (r *CrewRepository) UnassignFromProject(projectID, crewID, orgID uint) (int64, error) {
+	var removed int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND organization_id = ?", projectID, orgID).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("project_id = ? AND crew_id = ? AND organization_id = ?", projectID, crewID, orgID).
+			Delete(&model.WorkerProject{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		removed = result.RowsAffected
+		return nil
+	})
+	return removed, err
+}
+
+// checkCrew verifies that the crew's name is free, that its members belong to the organization and
+// no other crew, and that its lead is one of them
+func checkCrew(tx *gorm.DB, crew *model.Crew) error {
+	var count int64
+	err := tx.Model(&model.Crew{}).
+		Where("organization_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", crew.OrganizationID, crew.Name, crew.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCrewExists
+	}
+
+	crew.MemberIDs = uniqueIDs(crew.MemberIDs)
+	if crew.LeadID != nil && !containsID(crew.MemberIDs, *crew.LeadID) {
+		return ErrCrewLead
+	}
+	if len(crew.MemberIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&model.Worker{}).Where("id IN ? AND organization_id = ?", crew.MemberIDs, crew.OrganizationID).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(crew.MemberIDs) {
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := tx.Model(&model.CrewMember{}).Where("worker_id IN ? AND crew_id <> ?", crew.MemberIDs, crew.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCrewMember
+	}
+	return nil
+}
+
+// saveCrewMembers creates the memberships of the workers listed in the crew's MemberIDs
+func saveCrewMembers(tx *gorm.DB, crew *model.Crew) error {
+	crew.Members = make([]model.CrewMember, 0, len(crew.MemberIDs))
+	for _, workerID := range crew.MemberIDs {
+		crew.Members = append(crew.Members, model.CrewMember{CrewID: crew.ID, WorkerID: workerID, OrganizationID: crew.OrganizationID})
+	}
+	if len(crew.Members) == 0 {
+		return nil
+	}
+	return tx.Omit("Worker").Create(&crew.Members).Error
+}
+
+// memberIDs returns the worker IDs of crew members
+func memberIDs(members []model.CrewMember) []uint {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.WorkerID)
+	}
+	return ids
+}
+
+// containsID reports whether ids includes id
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
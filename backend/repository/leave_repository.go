@@ -0,0 +1,381 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLeaveOverlap is returned when a worker already has pending or approved leave on some of the days
+	ErrLeaveOverlap = errors.New("worker already has leave on some of these days")
+	// ErrLeaveNoDays is returned when a leave request covers no working day
+	ErrLeaveNoDays = errors.New("leave request covers no working day")
+	// ErrLeaveStatus is returned when a leave request is not in the state an action expects
+	ErrLeaveStatus = errors.New("leave request is not in the expected state")
+	// ErrLeaveAllowance is returned when approving leave would exceed the worker's allowance for the year
+	ErrLeaveAllowance = errors.New("leave would exceed the worker's allowance")
+)
+
+// LeaveRepository handles database operations for leave requests and allowances
+type LeaveRepository struct {
+	db *gorm.DB
+}
+
+// NewLeaveRepository creates a new LeaveRepository instance
+func NewLeaveRepository() *LeaveRepository {
+	return &LeaveRepository{
+		db: config.DB,
+	}
+}
+
+// GetAll retrieves the leave requests of an organization, latest first. Supported filters are
+// "worker_id", "type", "status", "from" and "until"; the latter two keep leave overlapping that range.
+func (r *LeaveRepository) GetAll(orgID uint, filters map[string]interface{}, page int, pageSize int) ([]model.LeaveRequest, int64, error) {
+	var leaves []model.LeaveRequest
+	var total int64
+	query := r.db.Model(&model.LeaveRequest{}).Where("organization_id = ?", orgID)
+
+	// Apply filters
+	for key, value := range filters {
+		switch key {
+		case "worker_id", "type", "status":
+			query = query.Where(key+" = ?", value)
+		case "from":
+			query = query.Where("end_date >= ?", value)
+		case "until":
+			query = query.Where("start_date <= ?", value)
+		}
+	}
+
+	// Count total records (before pagination)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	if page > 0 && pageSize > 0 {
+		offset := (page - 1) * pageSize
+		query = query.Offset(offset).Limit(pageSize)
+	}
+
+	err := query.Preload("Worker").
+		Order("start_date DESC, worker_id").
+		Find(&leaves).Error
+	return leaves, total, err
+}
+
+// GetByID retrieves a leave request by ID within an organization
+func (r *LeaveRepository) GetByID(id uint, orgID uint) (*model.LeaveRequest, error) {
+	var leave model.LeaveRequest
+	err := r.db.Preload("Worker").
+		Where("id = ? AND organization_id = ?", id, orgID).First(&leave).Error
+	if err != nil {
+		return nil, err
+	}
+	return &leave, nil
+}
+
+// Create records a pending leave request
+func (r *LeaveRepository) Create(leave *model.LeaveRequest, orgID uint, userID uint) error {
+	if err := r.db.Where("id = ? AND organization_id = ?", leave.WorkerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return err
+	}
+
+	leave.ID = 0
+	leave.Status = model.LeaveStatusPending
+	leave.ReviewedByID = nil
+	leave.ReviewedAt = nil
+	leave.ReviewNote = ""
+	leave.UserID = userID
+	leave.OrganizationID = orgID
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkLeave(tx, leave); err != nil {
+			return err
+		}
+		return tx.Omit("Worker").Create(leave).Error
+	})
+}
+
+// Update changes a pending leave request
+func (r *LeaveRepository) Update(leave *model.LeaveRequest, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.LeaveRequest{}
+		if err := tx.Where("id = ? AND organization_id = ?", leave.ID, orgID).First(existing).Error; err != nil {
+			return err
+		}
+		if existing.Status != model.LeaveStatusPending {
+			return ErrLeaveStatus
+		}
+		if err := tx.Where("id = ? AND organization_id = ?", leave.WorkerID, orgID).First(&model.Worker{}).Error; err != nil {
+			return err
+		}
+
+		// Keep the status, requesting user, owning organization and creation time
+		leave.Status = existing.Status
+		leave.ReviewedByID = nil
+		leave.ReviewedAt = nil
+		leave.ReviewNote = ""
+		leave.UserID = existing.UserID
+		leave.OrganizationID = existing.OrganizationID
+		leave.CreatedAt = existing.CreatedAt
+
+		if err := checkLeave(tx, leave); err != nil {
+			return err
+		}
+		return tx.Omit("Worker").Save(leave).Error
+	})
+}
+
+// Delete removes a pending leave request. Reviewed requests are kept and can only be cancelled.
+func (r *LeaveRepository) Delete(id uint, orgID uint) error {
+	leave := &model.LeaveRequest{}
+	if err := r.db.Where("id = ? AND organization_id = ?", id, orgID).First(leave).Error; err != nil {
+		return err
+	}
+	if leave.Status != model.LeaveStatusPending {
+		return ErrLeaveStatus
+	}
+	return r.db.Delete(leave).Error
+}
+
+// Approve approves a pending leave request, provided the worker's allowance for the leave type
+// is not exceeded in any year the leave falls in
+func (r *LeaveRepository) Approve(id uint, orgID uint, reviewerID uint, note string) (*model.LeaveRequest, error) {
+	return r.review(id, orgID, reviewerID, note, model.LeaveStatusApproved)
+}
+
+// Reject rejects a pending leave request
+func (r *LeaveRepository) Reject(id uint, orgID uint, reviewerID uint, note string) (*model.LeaveRequest, error) {
+	return r.review(id, orgID, reviewerID, note, model.LeaveStatusRejected)
+}
+
+// Cancel withdraws a pending or approved leave request, returning its days to the worker's balance
+func (r *LeaveRepository) Cancel(id uint, orgID uint) (*model.LeaveRequest, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		leave := &model.LeaveRequest{}
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(leave).Error; err != nil {
+			return err
+		}
+		if leave.Status != model.LeaveStatusPending && leave.Status != model.LeaveStatusApproved {
+			return ErrLeaveStatus
+		}
+		return tx.Model(leave).Update("status", model.LeaveStatusCancelled).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id, orgID)
+}
+
+// review moves a pending leave request to approved or rejected
+func (r *LeaveRepository) review(id uint, orgID uint, reviewerID uint, note string, status string) (*model.LeaveRequest, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		leave := &model.LeaveRequest{}
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(leave).Error; err != nil {
+			return err
+		}
+		if leave.Status != model.LeaveStatusPending {
+			return ErrLeaveStatus
+		}
+		if status == model.LeaveStatusApproved {
+			if err := checkAllowance(tx, leave); err != nil {
+				return err
+			}
+		}
+		return tx.Model(leave).Updates(map[string]interface{}{
+			"status":         status,
+			"reviewed_by_id": reviewerID,
+			"reviewed_at":    time.Now(),
+			"review_note":    note,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id, orgID)
+}
+
+// GetBalance reports the leave of every type a worker has taken, has pending and may still take in a year
+func (r *LeaveRepository) GetBalance(workerID uint, orgID uint, year int) ([]model.LeaveBalance, error) {
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return nil, err
+	}
+
+	var allowances []model.LeaveAllowance
+	if err := r.db.Where("worker_id = ? AND year = ? AND organization_id = ?", workerID, year, orgID).Find(&allowances).Error; err != nil {
+		return nil, err
+	}
+
+	yearStart, yearEnd := yearRange(year)
+	var leaves []model.LeaveRequest
+	err := r.db.Where("worker_id = ? AND organization_id = ? AND status IN ?", workerID, orgID,
+		[]string{model.LeaveStatusPending, model.LeaveStatusApproved}).
+		Where("start_date <= ? AND end_date >= ?", yearEnd, yearStart).
+		Find(&leaves).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]model.LeaveBalance, 0, len(model.LeaveTypes))
+	for _, leaveType := range model.LeaveTypes {
+		balance := model.LeaveBalance{Type: leaveType}
+		for _, leave := range leaves {
+			if leave.Type != leaveType {
+				continue
+			}
+			if leave.Status == model.LeaveStatusApproved {
+				balance.Taken += leave.DaysBetween(yearStart, yearEnd)
+			} else {
+				balance.Pending += leave.DaysBetween(yearStart, yearEnd)
+			}
+		}
+		for _, allowance := range allowances {
+			if allowance.Type == leaveType {
+				days := allowance.Days
+				remaining := days - balance.Taken
+				balance.Allowance = &days
+				balance.Remaining = &remaining
+			}
+		}
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+// SetAllowance sets the days of a leave type a worker may take in a year, replacing any earlier allowance
+func (r *LeaveRepository) SetAllowance(allowance *model.LeaveAllowance, orgID uint) error {
+	if err := r.db.Where("id = ? AND organization_id = ?", allowance.WorkerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.LeaveAllowance{}
+		err := tx.Where("worker_id = ? AND year = ? AND type = ?", allowance.WorkerID, allowance.Year, allowance.Type).
+			First(existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		allowance.OrganizationID = orgID
+		if err == nil {
+			allowance.ID = existing.ID
+			allowance.CreatedAt = existing.CreatedAt
+		} else {
+			allowance.ID = 0
+		}
+		return tx.Save(allowance).Error
+	})
+}
+
+// GetAbsences retrieves the approved leave between from and until of the workers assigned to a
+// project during that range, ordered by start date
+func (r *LeaveRepository) GetAbsences(projectID uint, orgID uint, from, until time.Time) ([]model.Absence, error) {
+	// Verify project belongs to organization
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(&model.Project{}).Error; err != nil {
+		return nil, err
+	}
+
+	assigned := r.db.Table("worker_projects").Select("1").
+		Where("worker_projects.worker_id = leave_requests.worker_id AND worker_projects.project_id = ?", projectID).
+		Where("worker_projects.start_date IS NULL OR worker_projects.start_date <= ?", until).
+		Where("worker_projects.end_date IS NULL OR worker_projects.end_date >= ?", from)
+
+	var leaves []model.LeaveRequest
+	err := r.db.Preload("Worker").
+		Where("organization_id = ? AND status = ?", orgID, model.LeaveStatusApproved).
+		Where("start_date <= ? AND end_date >= ?", until, from).
+		Where("EXISTS (?)", assigned).
+		Order("start_date, worker_id").
+		Find(&leaves).Error
+	if err != nil {
+		return nil, err
+	}
+
+	absences := make([]model.Absence, 0, len(leaves))
+	for _, leave := range leaves {
+		if leave.Worker == nil {
+			// Worker was deleted
+			continue
+		}
+		absences = append(absences, model.Absence{
+			LeaveID:      leave.ID,
+			WorkerID:     leave.WorkerID,
+			WorkerName:   leave.Worker.Name,
+			Type:         leave.Type,
+			StartDate:    leave.StartDate,
+			EndDate:      leave.EndDate,
+			HalfDayStart: leave.HalfDayStart,
+			HalfDayEnd:   leave.HalfDayEnd,
+			Days:         leave.DaysBetween(from, until),
+		})
+	}
+	return absences, nil
+}
+
+// checkLeave normalizes the dates of a leave request, counts its working days and verifies that
+// it does not overlap other pending or approved leave of the worker
+func checkLeave(tx *gorm.DB, leave *model.LeaveRequest) error {
+	leave.StartDate = dateOnly(leave.StartDate)
+	leave.EndDate = dateOnly(leave.EndDate)
+	leave.Days = leave.DaysBetween(leave.StartDate, leave.EndDate)
+	if leave.Days == 0 {
+		return ErrLeaveNoDays
+	}
+
+	var overlapping int64
+	err := tx.Model(&model.LeaveRequest{}).
+		Where("worker_id = ? AND id <> ? AND status IN ?", leave.WorkerID, leave.ID,
+			[]string{model.LeaveStatusPending, model.LeaveStatusApproved}).
+		Where("start_date <= ? AND end_date >= ?", leave.EndDate, leave.StartDate).
+		Count(&overlapping).Error
+	if err != nil {
+		return err
+	}
+	if overlapping > 0 {
+		return ErrLeaveOverlap
+	}
+	return nil
+}
+
+// checkAllowance verifies that approving the leave keeps the worker within their allowance for
+// the leave type in every year the leave falls in. Years without an allowance are not limited.
+func checkAllowance(tx *gorm.DB, leave *model.LeaveRequest) error {
+	for year := leave.StartDate.Year(); year <= leave.EndDate.Year(); year++ {
+		allowance := &model.LeaveAllowance{}
+		err := tx.Where("worker_id = ? AND year = ? AND type = ?", leave.WorkerID, year, leave.Type).First(allowance).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		yearStart, yearEnd := yearRange(year)
+		var approved []model.LeaveRequest
+		err = tx.Where("worker_id = ? AND type = ? AND status = ? AND id <> ?", leave.WorkerID, leave.Type, model.LeaveStatusApproved, leave.ID).
+			Where("start_date <= ? AND end_date >= ?", yearEnd, yearStart).
+			Find(&approved).Error
+		if err != nil {
+			return err
+		}
+
+		taken := leave.DaysBetween(yearStart, yearEnd)
+		for _, other := range approved {
+			taken += other.DaysBetween(yearStart, yearEnd)
+		}
+		if taken > allowance.Days {
+			return ErrLeaveAllowance
+		}
+	}
+	return nil
+}
+
+// yearRange returns the first and last day of a year
+func yearRange(year int) (time.Time, time.Time) {
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+}
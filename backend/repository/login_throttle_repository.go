@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleRepository handles database operations for failed login counters
+type LoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository creates a new LoginThrottleRepository instance
+func NewLoginThrottleRepository() *LoginThrottleRepository {
+	return &LoginThrottleRepository{
+		db: config.DB,
+	}
+}
+
+// Get retrieves the counter of a subject, returning nil if it has no recorded failures
+func (r *LoginThrottleRepository) Get(kind, subject string) (*model.LoginThrottle, error) {
+	var throttle model.LoginThrottle
+	if err := r.db.Where("kind = ? AND subject = ?", kind, subject).First(&throttle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// RecordFailure increments the failure counter of a subject and locks it once maxFailures is reached.
+// Failures older than window are forgotten before counting the new one.
+func (r *LoginThrottleRepository) RecordFailure(kind, subject string, maxFailures int, window, lockout time.Duration) (*model.LoginThrottle, error) {
+	var throttle model.LoginThrottle
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kind = ? AND subject = ?", kind, subject).
+			First(&throttle).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		locked := throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil)
+		if !locked && now.Sub(throttle.LastFailureAt) > window {
+			throttle.Failures = 0
+			throttle.LockedUntil = nil
+		}
+
+		throttle.Kind = kind
+		throttle.Subject = subject
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if throttle.Failures >= maxFailures && !locked {
+			lockedUntil := now.Add(lockout)
+			throttle.LockedUntil = &lockedUntil
+		}
+
+		return tx.Save(&throttle).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Reset clears the failure counter of a subject
+func (r *LoginThrottleRepository) Reset(kind, subject string) error {
+	return r.db.Where("kind = ? AND subject = ?", kind, subject).Delete(&model.LoginThrottle{}).Error
+}
+
+// GetLocked retrieves all subjects that are currently locked
+func (r *LoginThrottleRepository) GetLocked() ([]model.LoginThrottle, error) {
+	var throttles []model.LoginThrottle
+	err := r.db.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&throttles).Error
+	return throttles, err
+}
+
+// Unlock removes the lock and failure counter with the given ID
+func (r *LoginThrottleRepository) Unlock(id uint) error {
+	result := r.db.Where("id = ?", id).Delete(&model.LoginThrottle{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
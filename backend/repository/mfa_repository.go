@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrTOTPReplayed is returned when a TOTP code of an already used time step is presented again
+var ErrTOTPReplayed = errors.New("totp code already used")
+
+// MFARepository handles database operations for TOTP enrollment and recovery codes
+type MFARepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFARepository instance
+func NewMFARepository() *MFARepository {
+	return &MFARepository{
+		db: config.DB,
+	}
+}
+
+// SetPendingSecret stores a new TOTP secret that becomes active once enrollment is verified
+func (r *MFARepository) SetPendingSecret(userID uint, secret string) error {
+	return r.db.Model(&model.User{}).Where("id = ? AND mfa_enabled = ?", userID, false).
+		Updates(map[string]interface{}{"mfa_secret": secret, "mfa_last_step": 0}).Error
+}
+
+// Enable turns on MFA for a user and replaces their recovery codes
+func (r *MFARepository) Enable(userID uint, step int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"mfa_enabled": true, "mfa_last_step": step}).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// Disable turns off MFA for a user and removes their secret and recovery codes
+func (r *MFARepository) Disable(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"mfa_enabled": false, "mfa_secret": "", "mfa_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error
+	})
+}
+
+// RecordStep stores the TOTP time step that was just used.
+// It returns ErrTOTPReplayed if the step is not newer than the last accepted one.
+func (r *MFARepository) RecordStep(userID uint, step int64) error {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND mfa_last_step < ?", userID, step).
+		Update("mfa_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTOTPReplayed
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code of the user, reporting whether one matched
+func (r *MFARepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnusedRecoveryCodes returns the number of recovery codes the user can still use
+func (r *MFARepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// replaceRecoveryCodes deletes the existing recovery codes of a user and stores new ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]model.MFARecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, model.MFARecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
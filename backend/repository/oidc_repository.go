@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrOIDCStateInvalid is returned when a login state is unknown, expired or already used
+var ErrOIDCStateInvalid = errors.New("invalid or expired login state")
+
+// OIDCRepository handles database operations for single sign-on logins and linked identities
+type OIDCRepository struct {
+	db *gorm.DB
+}
+
+// NewOIDCRepository creates a new OIDCRepository instance
+func NewOIDCRepository() *OIDCRepository {
+	return &OIDCRepository{
+		db: config.DB,
+	}
+}
+
+// CreateState stores a pending login and removes expired ones
+func (r *OIDCRepository) CreateState(state *model.OIDCLoginState) error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&model.OIDCLoginState{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(state).Error
+}
+
+// ConsumeState deletes the pending login with the given state hash and returns it.
+// A state can only be consumed once and only before it expires.
+func (r *OIDCRepository) ConsumeState(hash string) (*model.OIDCLoginState, error) {
+	var state model.OIDCLoginState
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ?", hash).First(&state).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOIDCStateInvalid
+			}
+			return err
+		}
+
+		result := tx.Where("id = ? AND expires_at > ?", state.ID, time.Now()).Delete(&model.OIDCLoginState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOIDCStateInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetIdentity retrieves the identity of a provider account
+func (r *OIDCRepository) GetIdentity(issuer, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateIdentity links a provider account to a user
+func (r *OIDCRepository) CreateIdentity(identity *model.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// RecordLogin updates the last login time and email of an identity
+func (r *OIDCRepository) RecordLogin(identity *model.UserIdentity) error {
+	now := time.Now()
+	identity.LastLoginAt = &now
+	return r.db.Model(identity).Updates(map[string]interface{}{
+		"last_login_at": now,
+		"email":         identity.Email,
+	}).Error
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAlreadyMember is returned when inviting a user who already belongs to the organization
+	ErrAlreadyMember = errors.New("user is already a member")
+	// ErrInvitationNotPending is returned when responding to an invitation that was already handled
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+	// ErrOwnerMembership is returned when removing or demoting the organization owner
+	ErrOwnerMembership = errors.New("owner membership cannot be changed")
+)
+
+// Membership represents an organization together with the current user's role in it
+type Membership struct {
+	model.Organization
+	Role string `json:"role"`
+}
+
+// OrganizationRepository handles database operations for organizations, members and invitations
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository creates a new OrganizationRepository instance
+func NewOrganizationRepository() *OrganizationRepository {
+	return &OrganizationRepository{
+		db: config.DB,
+	}
+}
+
+// Create creates an organization with the given user as its owner
+func (r *OrganizationRepository) Create(org *model.Organization) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return createOrganization(tx, org)
+	})
+}
+
+// GetByID retrieves an organization by ID
+func (r *OrganizationRepository) GetByID(id uint) (*model.Organization, error) {
+	var org model.Organization
+	if err := r.db.First(&org, id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetUserOrganizations retrieves all organizations a user belongs to, with the user's role
+func (r *OrganizationRepository) GetUserOrganizations(userID uint) ([]Membership, error) {
+	var memberships []Membership
+	err := r.db.Model(&model.Organization{}).
+		Select("organizations.*, organization_members.role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.personal DESC, organizations.name").
+		Scan(&memberships).Error
+	return memberships, err
+}
+
+// GetMembership retrieves the membership of a user in an organization
+func (r *OrganizationRepository) GetMembership(orgID, userID uint) (*model.OrganizationMember, error) {
+	var member model.OrganizationMember
+	err := r.db.Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.organization_id = ? AND organization_members.user_id = ?", orgID, userID).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetPersonalOrganizationID retrieves the ID of the user's personal organization
+func (r *OrganizationRepository) GetPersonalOrganizationID(userID uint) (uint, error) {
+	var org model.Organization
+	if err := r.db.Where("owner_id = ? AND personal = ?", userID, true).First(&org).Error; err != nil {
+		return 0, err
+	}
+	return org.ID, nil
+}
+
+// GetMembers retrieves the members of an organization with their user accounts
+func (r *OrganizationRepository) GetMembers(orgID uint) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	err := r.db.Preload("User").
+		Where("organization_id = ?", orgID).
+		Order("created_at").
+		Find(&members).Error
+	for i := range members {
+		if members[i].User != nil {
+			members[i].User.PasswordHash = ""
+		}
+	}
+	return members, err
+}
+
+// UpdateMemberRole changes the role of a member. The owner's role cannot be changed.
+func (r *OrganizationRepository) UpdateMemberRole(orgID, userID uint, role string) error {
+	member, err := r.GetMembership(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.OrgRoleOwner || role == model.OrgRoleOwner {
+		return ErrOwnerMembership
+	}
+	return r.db.Model(member).Update("role", role).Error
+}
+
+// RemoveMember removes a user from an organization. The owner cannot be removed.
+func (r *OrganizationRepository) RemoveMember(orgID, userID uint) error {
+	member, err := r.GetMembership(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.OrgRoleOwner {
+		return ErrOwnerMembership
+	}
+	return r.db.Delete(member).Error
+}
+
+// CreateInvitation invites an existing user into an organization
+func (r *OrganizationRepository) CreateInvitation(invitation *model.OrganizationInvitation) error {
+	if _, err := r.GetMembership(invitation.OrganizationID, invitation.UserID); err == nil {
+		return ErrAlreadyMember
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Replace any earlier pending invitation of the same user
+		if err := tx.Model(&model.OrganizationInvitation{}).
+			Where("organization_id = ? AND user_id = ? AND status = ?", invitation.OrganizationID, invitation.UserID, model.InvitationPending).
+			Update("status", model.InvitationRevoked).Error; err != nil {
+			return err
+		}
+		invitation.Status = model.InvitationPending
+		return tx.Create(invitation).Error
+	})
+}
+
+// GetInvitations retrieves the invitations of an organization
+func (r *OrganizationRepository) GetInvitations(orgID uint) ([]model.OrganizationInvitation, error) {
+	var invitations []model.OrganizationInvitation
+	err := r.db.Where("organization_id = ?", orgID).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// GetPendingInvitationsForUser retrieves the pending invitations addressed to a user
+func (r *OrganizationRepository) GetPendingInvitationsForUser(userID uint) ([]model.OrganizationInvitation, error) {
+	var invitations []model.OrganizationInvitation
+	err := r.db.Preload("Organization").
+		Where("user_id = ? AND status = ?", userID, model.InvitationPending).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// GetInvitation retrieves an invitation by ID
+func (r *OrganizationRepository) GetInvitation(id uint) (*model.OrganizationInvitation, error) {
+	var invitation model.OrganizationInvitation
+	if err := r.db.First(&invitation, id).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// RespondToInvitation accepts or declines a pending invitation. Accepting creates the membership.
+func (r *OrganizationRepository) RespondToInvitation(invitation *model.OrganizationInvitation, accept bool) error {
+	status := model.InvitationDeclined
+	if accept {
+		status = model.InvitationAccepted
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.OrganizationInvitation{}).
+			Where("id = ? AND status = ?", invitation.ID, model.InvitationPending).
+			Updates(map[string]interface{}{"status": status, "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotPending
+		}
+		invitation.Status = status
+		invitation.RespondedAt = &now
+
+		if !accept {
+			return nil
+		}
+		return tx.Create(&model.OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         invitation.UserID,
+			Role:           invitation.Role,
+		}).Error
+	})
+}
+
+// RevokeInvitation revokes a pending invitation of an organization
+func (r *OrganizationRepository) RevokeInvitation(orgID, invitationID uint) error {
+	result := r.db.Model(&model.OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND status = ?", invitationID, orgID, model.InvitationPending).
+		Update("status", model.InvitationRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotPending
+	}
+	return nil
+}
+
+// createOrganization stores an organization and its owner membership within a transaction
+func createOrganization(tx *gorm.DB, org *model.Organization) error {
+	if err := tx.Create(org).Error; err != nil {
+		return err
+	}
+	return tx.Create(&model.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         org.OwnerID,
+		Role:           model.OrgRoleOwner,
+	}).Error
+}
+
+// createPersonalOrganization creates the personal organization of a new user
+func createPersonalOrganization(tx *gorm.DB, user *model.User) error {
+	return createOrganization(tx, &model.Organization{
+		Name:     fmt.Sprintf("%s's organization", user.Username),
+		OwnerID:  user.ID,
+		Personal: true,
+	})
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrResetTokenInvalid is returned when a reset token is unknown, expired or already used
+var ErrResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// PasswordResetRepository handles database operations for password reset tokens
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new PasswordResetRepository instance
+func NewPasswordResetRepository() *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db: config.DB,
+	}
+}
+
+// CreateToken stores a new reset token and invalidates any earlier unused token of the user
+func (r *PasswordResetRepository) CreateToken(token *model.PasswordResetToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// GetValidToken retrieves an unused and unexpired reset token by the hash of its value
+func (r *PasswordResetRepository) GetValidToken(hash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResetTokenInvalid
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeToken marks the reset token with the given hash as used and returns it.
+// A token can only be consumed once and only before it expires.
+func (r *PasswordResetRepository) ConsumeToken(hash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrResetTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&model.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,581 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// Standard working time used to turn daily and monthly rates into an hourly rate for overtime
+const (
+	standardHoursPerDay   = 8
+	standardHoursPerMonth = 40 * 52 / 12.0
+)
+
+var (
+	// ErrPayRateExists is returned when the worker already has a pay rate starting on the same date
+	ErrPayRateExists = errors.New("pay rate already exists for that date")
+	// ErrPayrollLocked is returned when changing a finalized payroll run
+	ErrPayrollLocked = errors.New("payroll run is finalized")
+	// ErrPayrollOverlap is returned when a pay period overlaps a finalized payroll run
+	ErrPayrollOverlap = errors.New("pay period overlaps a finalized payroll run")
+	// ErrPayrollMissingRate is returned when finalizing a run with hours of workers without a pay rate
+	ErrPayrollMissingRate = errors.New("payroll run has workers without a pay rate")
+)
+
+// PayrollRepository handles database operations for pay rates and payroll runs
+type PayrollRepository struct {
+	db *gorm.DB
+}
+
+// NewPayrollRepository creates a new PayrollRepository instance
+func NewPayrollRepository() *PayrollRepository {
+	return &PayrollRepository{
+		db: config.DB,
+	}
+}
+
+// GetPayRates retrieves the pay rates of a worker, the latest first
+func (r *PayrollRepository) GetPayRates(workerID uint, orgID uint) ([]model.PayRate, error) {
+	var rates []model.PayRate
+	err := r.db.Where("worker_id = ? AND organization_id = ?", workerID, orgID).
+		Order("effective_from DESC").
+		Find(&rates).Error
+	return rates, err
+}
+
+// GetPayRateOn retrieves the pay rate of a worker in effect on a date
+func (r *PayrollRepository) GetPayRateOn(workerID uint, orgID uint, date time.Time) (*model.PayRate, error) {
+	var rate model.PayRate
+	err := r.db.Where("worker_id = ? AND organization_id = ? AND effective_from <= ?", workerID, orgID, dateOnly(date)).
+		Order("effective_from DESC").
+		First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// CreatePayRate adds a pay rate to a worker of the organization
+func (r *PayrollRepository) CreatePayRate(rate *model.PayRate, orgID uint, userID uint) error {
+	var worker model.Worker
+	if err := r.db.Where("id = ? AND organization_id = ?", rate.WorkerID, orgID).First(&worker).Error; err != nil {
+		return err
+	}
+
+	rate.ID = 0
+	rate.EffectiveFrom = dateOnly(rate.EffectiveFrom)
+	rate.Currency = strings.ToUpper(rate.Currency)
+	rate.UserID = userID
+	rate.OrganizationID = orgID
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkPayRateDate(tx, rate); err != nil {
+			return err
+		}
+		if err := tx.Create(rate).Error; err != nil {
+			return err
+		}
+		return recordPayRateChange(tx, nil, rate, userID)
+	})
+}
+
+// UpdatePayRate changes a pay rate of a worker. Finalized payroll runs keep the rate they were calculated with.
+func (r *PayrollRepository) UpdatePayRate(rate *model.PayRate, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.PayRate{}
+		err := tx.Where("id = ? AND worker_id = ? AND organization_id = ?", rate.ID, rate.WorkerID, orgID).First(existing).Error
+		if err != nil {
+			return err
+		}
+
+		// Keep the recording user, owning organization and creation time
+		rate.EffectiveFrom = dateOnly(rate.EffectiveFrom)
+		rate.Currency = strings.ToUpper(rate.Currency)
+		rate.UserID = existing.UserID
+		rate.OrganizationID = existing.OrganizationID
+		rate.CreatedAt = existing.CreatedAt
+
+		if err := checkPayRateDate(tx, rate); err != nil {
+			return err
+		}
+		if err := tx.Save(rate).Error; err != nil {
+			return err
+		}
+		return recordPayRateChange(tx, existing, rate, userID)
+	})
+}
+
+// DeletePayRate removes a pay rate of a worker
+func (r *PayrollRepository) DeletePayRate(workerID uint, id uint, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		rate := &model.PayRate{}
+		if err := tx.Where("id = ? AND worker_id = ? AND organization_id = ?", id, workerID, orgID).First(rate).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(rate).Error; err != nil {
+			return err
+		}
+		return recordPayRateChange(tx, rate, nil, userID)
+	})
+}
+
+// GetRuns retrieves the payroll runs of an organization with their totals, the latest period first
+func (r *PayrollRepository) GetRuns(orgID uint, page int, pageSize int) ([]model.PayrollRun, int64, error) {
+	var runs []model.PayrollRun
+	var total int64
+	query := r.db.Model(&model.PayrollRun{}).Where("organization_id = ?", orgID)
+
+	// Count total records (before pagination)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	if page > 0 && pageSize > 0 {
+		offset := (page - 1) * pageSize
+		query = query.Offset(offset).Limit(pageSize)
+	}
+
+	if err := query.Order("period_start DESC, id DESC").Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Totals are summed from the lines of all listed runs at once
+	ids := make([]uint, len(runs))
+	for i := range runs {
+		ids[i] = runs[i].ID
+	}
+	var lines []model.PayrollLine
+	if err := r.db.Where("payroll_run_id IN ?", ids).Find(&lines).Error; err != nil {
+		return nil, 0, err
+	}
+	linesByRun := make(map[uint][]model.PayrollLine)
+	for _, line := range lines {
+		linesByRun[line.PayrollRunID] = append(linesByRun[line.PayrollRunID], line)
+	}
+	for i := range runs {
+		runs[i].Totals = payrollTotals(linesByRun[runs[i].ID])
+	}
+
+	return runs, total, nil
+}
+
+// GetRun retrieves a payroll run with its lines and totals
+func (r *PayrollRepository) GetRun(id uint, orgID uint) (*model.PayrollRun, error) {
+	var run model.PayrollRun
+	err := r.db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("worker_name, worker_id, id")
+	}).Where("id = ? AND organization_id = ?", id, orgID).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	run.Totals = payrollTotals(run.Lines)
+	return &run, nil
+}
+
+// CreateRun creates a draft payroll run and calculates its lines
+func (r *PayrollRepository) CreateRun(run *model.PayrollRun, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		run.ID = 0
+		run.PeriodStart = dateOnly(run.PeriodStart)
+		run.PeriodEnd = dateOnly(run.PeriodEnd)
+		run.Status = model.PayrollStatusDraft
+		run.FinalizedByID = nil
+		run.FinalizedAt = nil
+		run.UserID = userID
+		run.OrganizationID = orgID
+
+		if err := checkPayPeriod(tx, run); err != nil {
+			return err
+		}
+		if err := tx.Omit("Lines").Create(run).Error; err != nil {
+			return err
+		}
+		return calculateRun(tx, run)
+	})
+}
+
+// UpdateRun changes the period, overtime multiplier or notes of a draft run and recalculates it,
+// which also picks up hours approved since the last calculation
+func (r *PayrollRepository) UpdateRun(run *model.PayrollRun, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.PayrollRun{}
+		if err := tx.Where("id = ? AND organization_id = ?", run.ID, orgID).First(existing).Error; err != nil {
+			return err
+		}
+		if existing.Status != model.PayrollStatusDraft {
+			return ErrPayrollLocked
+		}
+
+		// Keep the creator, owning organization and creation time
+		run.PeriodStart = dateOnly(run.PeriodStart)
+		run.PeriodEnd = dateOnly(run.PeriodEnd)
+		run.Status = model.PayrollStatusDraft
+		run.FinalizedByID = nil
+		run.FinalizedAt = nil
+		run.UserID = existing.UserID
+		run.OrganizationID = existing.OrganizationID
+		run.CreatedAt = existing.CreatedAt
+
+		if err := checkPayPeriod(tx, run); err != nil {
+			return err
+		}
+		if err := tx.Omit("Lines").Save(run).Error; err != nil {
+			return err
+		}
+		return calculateRun(tx, run)
+	})
+}
+
+// DeleteRun removes a draft payroll run with its lines
+func (r *PayrollRepository) DeleteRun(id uint, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		run := &model.PayrollRun{}
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(run).Error; err != nil {
+			return err
+		}
+		if run.Status != model.PayrollStatusDraft {
+			return ErrPayrollLocked
+		}
+		if err := tx.Where("payroll_run_id = ?", run.ID).Delete(&model.PayrollLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(run).Error
+	})
+}
+
+// FinalizeRun recalculates a draft run one last time and locks it
+func (r *PayrollRepository) FinalizeRun(id uint, orgID uint, userID uint) (*model.PayrollRun, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		run := &model.PayrollRun{}
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(run).Error; err != nil {
+			return err
+		}
+		if run.Status != model.PayrollStatusDraft {
+			return ErrPayrollLocked
+		}
+		if err := checkPayPeriod(tx, run); err != nil {
+			return err
+		}
+		if err := calculateRun(tx, run); err != nil {
+			return err
+		}
+		for _, line := range run.Lines {
+			if line.MissingRate {
+				return ErrPayrollMissingRate
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(run).Updates(map[string]interface{}{
+			"status":          model.PayrollStatusFinalized,
+			"finalized_by_id": userID,
+			"finalized_at":    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRun(id, orgID)
+}
+
+// checkPayRateDate verifies that no other rate of the worker starts on the same date
+func checkPayRateDate(db *gorm.DB, rate *model.PayRate) error {
+	var count int64
+	err := db.Model(&model.PayRate{}).
+		Where("worker_id = ? AND effective_from = ? AND id <> ?", rate.WorkerID, rate.EffectiveFrom, rate.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPayRateExists
+	}
+	return nil
+}
+
+// recordPayRateChange adds a history row for a created (before is nil), changed or removed (after
+// is nil) pay rate. The rate's notes are kept as the reason.
+func recordPayRateChange(tx *gorm.DB, before *model.PayRate, after *model.PayRate, userID uint) error {
+	change := model.WorkerChange{
+		Field:  model.WorkerFieldPayRate,
+		UserID: userID,
+	}
+	if before != nil {
+		change.OldValue = formatPayRate(before)
+		change.WorkerID = before.WorkerID
+		change.EffectiveDate = before.EffectiveFrom
+		change.OrganizationID = before.OrganizationID
+	}
+	if after != nil {
+		change.NewValue = formatPayRate(after)
+		change.WorkerID = after.WorkerID
+		change.EffectiveDate = after.EffectiveFrom
+		change.OrganizationID = after.OrganizationID
+		change.Reason = after.Notes
+	}
+	if change.OldValue == change.NewValue {
+		return nil
+	}
+	return tx.Create(&change).Error
+}
+
+// formatPayRate describes a pay rate for the worker history, e.g. "25.00 EUR hourly"
+func formatPayRate(rate *model.PayRate) string {
+	return fmt.Sprintf("%.2f %s %s", rate.Amount, rate.Currency, rate.RateType)
+}
+
+// checkPayPeriod verifies that the period of a run does not overlap a finalized run, so hours are never paid twice
+func checkPayPeriod(tx *gorm.DB, run *model.PayrollRun) error {
+	var count int64
+	err := tx.Model(&model.PayrollRun{}).
+		Where("organization_id = ? AND status = ? AND id <> ?", run.OrganizationID, model.PayrollStatusFinalized, run.ID).
+		Where("period_start <= ? AND period_end >= ?", run.PeriodEnd, run.PeriodStart).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPayrollOverlap
+	}
+	return nil
+}
+
+// calculateRun replaces the lines of a run with the pay computed from the approved hours and pay
+// rates of the organization's workers during the run's period
+func calculateRun(tx *gorm.DB, run *model.PayrollRun) error {
+	if err := tx.Where("payroll_run_id = ?", run.ID).Delete(&model.PayrollLine{}).Error; err != nil {
+		return err
+	}
+
+	var workers []model.Worker
+	if err := tx.Where("organization_id = ?", run.OrganizationID).Find(&workers).Error; err != nil {
+		return err
+	}
+
+	var rates []model.PayRate
+	err := tx.Where("organization_id = ? AND effective_from <= ?", run.OrganizationID, run.PeriodEnd).
+		Order("worker_id, effective_from").
+		Find(&rates).Error
+	if err != nil {
+		return err
+	}
+
+	var entries []model.TimesheetEntry
+	err = tx.Where("organization_id = ? AND status = ? AND date >= ? AND date <= ?",
+		run.OrganizationID, model.TimesheetStatusApproved, run.PeriodStart, run.PeriodEnd).
+		Order("worker_id, date").
+		Find(&entries).Error
+	if err != nil {
+		return err
+	}
+
+	run.Lines = payrollLines(run, workers, rates, entries)
+	if len(run.Lines) > 0 {
+		if err := tx.Create(&run.Lines).Error; err != nil {
+			return err
+		}
+	}
+	run.Totals = payrollTotals(run.Lines)
+	return nil
+}
+
+// payrollLines computes one line per worker and pay rate in effect during the run's period. Hourly
+// rates pay the approved hours, daily rates the days with regular hours and monthly rates every
+// calendar day of the period as a share of its month. Overtime is paid at the hourly equivalent of
+// the rate times the run's overtime multiplier. Hours without a rate get a line marked MissingRate.
+func payrollLines(run *model.PayrollRun, workers []model.Worker, rates []model.PayRate, entries []model.TimesheetEntry) []model.PayrollLine {
+	type lineKey struct {
+		workerID uint
+		rateID   uint
+	}
+	type lineTotals struct {
+		days       map[time.Time]bool
+		paidDays   int
+		monthShare float64
+		regular    float64
+		overtime   float64
+	}
+
+	workerByID := make(map[uint]model.Worker, len(workers))
+	for _, worker := range workers {
+		workerByID[worker.ID] = worker
+	}
+	ratesByWorker := make(map[uint][]model.PayRate)
+	rateByID := make(map[uint]model.PayRate, len(rates))
+	for _, rate := range rates {
+		ratesByWorker[rate.WorkerID] = append(ratesByWorker[rate.WorkerID], rate)
+		rateByID[rate.ID] = rate
+	}
+
+	var keys []lineKey
+	totals := make(map[lineKey]*lineTotals)
+	totalsFor := func(key lineKey) *lineTotals {
+		if t, ok := totals[key]; ok {
+			return t
+		}
+		keys = append(keys, key)
+		totals[key] = &lineTotals{days: make(map[time.Time]bool)}
+		return totals[key]
+	}
+
+	// Approved hours, counted under the rate in effect on their day
+	for _, entry := range entries {
+		if _, ok := workerByID[entry.WorkerID]; !ok {
+			continue
+		}
+		key := lineKey{workerID: entry.WorkerID}
+		if rate := rateOn(ratesByWorker[entry.WorkerID], entry.Date); rate != nil {
+			key.rateID = rate.ID
+		}
+		t := totalsFor(key)
+		t.regular += entry.RegularHours
+		t.overtime += entry.OvertimeHours
+		if entry.RegularHours > 0 {
+			t.days[dateOnly(entry.Date)] = true
+		}
+	}
+
+	// Monthly rates pay every calendar day of the period, worked or not
+	for workerID, workerRates := range ratesByWorker {
+		if _, ok := workerByID[workerID]; !ok {
+			continue
+		}
+		for day := run.PeriodStart; !day.After(run.PeriodEnd); day = day.AddDate(0, 0, 1) {
+			rate := rateOn(workerRates, day)
+			if rate == nil || rate.RateType != model.RateTypeMonthly {
+				continue
+			}
+			t := totalsFor(lineKey{workerID: workerID, rateID: rate.ID})
+			t.paidDays++
+			t.monthShare += 1 / float64(daysInMonth(day))
+		}
+	}
+
+	lines := make([]model.PayrollLine, 0, len(keys))
+	for _, key := range keys {
+		worker := workerByID[key.workerID]
+		t := totals[key]
+		line := model.PayrollLine{
+			PayrollRunID:  run.ID,
+			WorkerID:      worker.ID,
+			WorkerName:    worker.Name,
+			Position:      worker.Position,
+			DaysWorked:    len(t.days),
+			PaidDays:      t.paidDays,
+			RegularHours:  roundAmount(t.regular),
+			OvertimeHours: roundAmount(t.overtime),
+		}
+
+		rate, ok := rateByID[key.rateID]
+		if !ok {
+			line.MissingRate = true
+			lines = append(lines, line)
+			continue
+		}
+		rateID := rate.ID
+		line.PayRateID = &rateID
+		line.RateType = rate.RateType
+		line.Rate = rate.Amount
+		line.Currency = rate.Currency
+
+		hourly := rate.Amount
+		switch rate.RateType {
+		case model.RateTypeHourly:
+			line.RegularPay = t.regular * rate.Amount
+		case model.RateTypeDaily:
+			line.RegularPay = float64(len(t.days)) * rate.Amount
+			hourly = rate.Amount / standardHoursPerDay
+		case model.RateTypeMonthly:
+			line.RegularPay = t.monthShare * rate.Amount
+			hourly = rate.Amount / standardHoursPerMonth
+		}
+		line.RegularPay = roundAmount(line.RegularPay)
+		line.OvertimePay = roundAmount(t.overtime * hourly * run.OvertimeMultiplier)
+		line.Total = roundAmount(line.RegularPay + line.OvertimePay)
+		lines = append(lines, line)
+	}
+
+	// By worker, then hours without a rate first and the rates in the order they took effect
+	effective := func(line model.PayrollLine) time.Time {
+		if line.PayRateID == nil {
+			return time.Time{}
+		}
+		return rateByID[*line.PayRateID].EffectiveFrom
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].WorkerName != lines[j].WorkerName {
+			return lines[i].WorkerName < lines[j].WorkerName
+		}
+		if lines[i].WorkerID != lines[j].WorkerID {
+			return lines[i].WorkerID < lines[j].WorkerID
+		}
+		return effective(lines[i]).Before(effective(lines[j]))
+	})
+	return lines
+}
+
+// payrollTotals sums payroll lines per currency. Lines without a rate are left out.
+func payrollTotals(lines []model.PayrollLine) []model.PayrollTotal {
+	var currencies []string
+	totals := make(map[string]*model.PayrollTotal)
+	workers := make(map[string]map[uint]bool)
+	for _, line := range lines {
+		if line.MissingRate {
+			continue
+		}
+		total, ok := totals[line.Currency]
+		if !ok {
+			currencies = append(currencies, line.Currency)
+			total = &model.PayrollTotal{Currency: line.Currency}
+			totals[line.Currency] = total
+			workers[line.Currency] = make(map[uint]bool)
+		}
+		workers[line.Currency][line.WorkerID] = true
+		total.RegularPay += line.RegularPay
+		total.OvertimePay += line.OvertimePay
+		total.Total += line.Total
+	}
+
+	sort.Strings(currencies)
+	result := make([]model.PayrollTotal, 0, len(currencies))
+	for _, currency := range currencies {
+		total := totals[currency]
+		total.Workers = len(workers[currency])
+		total.RegularPay = roundAmount(total.RegularPay)
+		total.OvertimePay = roundAmount(total.OvertimePay)
+		total.Total = roundAmount(total.Total)
+		result = append(result, *total)
+	}
+	return result
+}
+
+// rateOn returns the rate in effect on a date from rates ordered by effective date
+func rateOn(rates []model.PayRate, date time.Time) *model.PayRate {
+	var current *model.PayRate
+	for i := range rates {
+		if rates[i].EffectiveFrom.After(date) {
+			break
+		}
+		current = &rates[i]
+	}
+	return current
+}
+
+// daysInMonth returns the number of days in the month of date
+func daysInMonth(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// roundAmount rounds to hundredths
+func roundAmount(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -1,9 +1,22 @@
 package repository
 
 import (
+	"errors"
+	"sort"
+	"time"
+
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAlreadyAssigned is returned when a worker is already assigned to the project
+	ErrAlreadyAssigned = errors.New("worker is already assigned to the project")
+	// ErrOverAllocated is returned when an assignment would allocate a worker more than 100 percent
+	// and is not forced
+	ErrOverAllocated = errors.New("worker would be allocated more than 100 percent")
 )
 
 type ProjectRepository struct {
@@ -16,30 +29,37 @@ func NewProjectRepository() *ProjectRepository {
 	}
 }
 
-// Create creates a new project
+// Create creates a new project. Assignments are managed through AddWorker.
 func (r *ProjectRepository) Create(project *model.Project) error {
-	return r.db.Create(project).Error
+	return r.db.Omit("Assignments").Create(project).Error
 }
 
-// GetByID retrieves a project by ID and user ID
-func (r *ProjectRepository) GetByID(id uint, userID uint) (*model.Project, error) {
+// GetByID retrieves a project by ID within an organization. When activeOn is set only the
+// assignments active on that date are reported, otherwise all of them.
+func (r *ProjectRepository) GetByID(id uint, orgID uint, activeOn *time.Time) (*model.Project, error) {
 	var project model.Project
-	// Use preload with a custom join query to check both worker's user_id and join table's user_id
-	err := r.db.Preload("Workers", func(db *gorm.DB) *gorm.DB {
-		return db.Joins("JOIN worker_projects ON worker_projects.worker_id = workers.id").
-			Where("workers.user_id = ? AND worker_projects.user_id = ?", userID, userID)
-	}).Where("id = ? AND user_id = ?", id, userID).First(&project).Error
+	// Only preload workers and assignments of the same organization
+	err := r.db.Preload("Workers", "organization_id = ?", orgID).
+		Preload("Assignments", func(db *gorm.DB) *gorm.DB {
+			db = db.Where("worker_projects.organization_id = ?", orgID)
+			if activeOn != nil {
+				db = activeAssignments(db, *activeOn)
+			}
+			return db.Order("worker_projects.start_date, worker_projects.worker_id")
+		}).
+		Preload("Assignments.Worker").
+		Where("id = ? AND organization_id = ?", id, orgID).First(&project).Error
 	if err != nil {
 		return nil, err
 	}
 	return &project, nil
 }
 
-// GetAll retrieves all projects with optional filtering and sorting for a specific user
-func (r *ProjectRepository) GetAll(userID uint, filters map[string]interface{}, sortBy string, sortOrder string, page int, pageSize int) ([]model.Project, int64, error) {
+// GetAll retrieves all projects with optional filtering and sorting for an organization
+func (r *ProjectRepository) GetAll(orgID uint, filters map[string]interface{}, sortBy string, sortOrder string, page int, pageSize int) ([]model.Project, int64, error) {
 	var projects []model.Project
 	var total int64
-	query := r.db.Model(&model.Project{}).Where("user_id = ?", userID)
+	query := r.db.Model(&model.Project{}).Where("organization_id = ?", orgID)
 
 	// Apply filters
 	for key, value := range filters {
@@ -76,49 +96,81 @@ func (r *ProjectRepository) GetAll(userID uint, filters map[string]interface{},
 		query = query.Offset(offset).Limit(pageSize)
 	}
 
-	// Add user_id condition to the preloaded Workers to ensure we only get workers belonging to the current user
-	// Also ensure the worker_projects join table has the correct user_id
-	err := query.Preload("Workers", func(db *gorm.DB) *gorm.DB {
-		return db.Joins("JOIN worker_projects ON worker_projects.worker_id = workers.id").
-			Where("workers.user_id = ? AND worker_projects.user_id = ?", userID, userID)
-	}).Find(&projects).Error
+	// Only preload workers of the same organization
+	err := query.Preload("Workers", "organization_id = ?", orgID).Find(&projects).Error
 	
 	return projects, total, err
 }
 
-// GetAllWorkers retrieves all workers for a specific user
-func (r *ProjectRepository) GetAllWorkers(userID uint, page int, pageSize int) ([]model.Worker, int64, error) {
+// GetAvailableWorkers retrieves the workers of an organization that are not assigned to the project,
+// with the same filters, sorting and pagination as WorkerRepository.GetAll
+func (r *ProjectRepository) GetAvailableWorkers(projectID uint, orgID uint, filters map[string]interface{}, sortBy string, sortOrder string, page int, pageSize int) ([]model.Worker, int64, error) {
+	// Verify project belongs to organization
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(&model.Project{}).Error; err != nil {
+		return nil, 0, err
+	}
+
 	var workers []model.Worker
 	var total int64
-	query := r.db.Model(&model.Worker{}).Where("user_id = ?", userID)
+	query := r.db.Model(&model.Worker{}).Where("organization_id = ?", orgID)
+
+	// Apply filters, always leaving out the workers already on the project
+	available := make(map[string]interface{}, len(filters)+1)
+	for key, value := range filters {
+		available[key] = value
+	}
+	available["exclude_project"] = projectID
+	query = applyWorkerFilters(r.db, query, available)
+
+	// Without an availability range, workers on approved leave today are not available
+	if available["available_from"] == nil && available["available_until"] == nil {
+		today := dateOnly(time.Now())
+		query = query.Where("NOT EXISTS (?)", leaveQuery(r.db, today, today, true))
+	}
 
 	// Count total records (before pagination)
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// Apply sorting
+	if sortBy != "" {
+		order := sortBy
+		if sortOrder == "desc" {
+			order += " DESC"
+		}
+		query = query.Order(order)
+	}
+
 	// Apply pagination
 	if page > 0 && pageSize > 0 {
 		offset := (page - 1) * pageSize
 		query = query.Offset(offset).Limit(pageSize)
 	}
 
-	err := query.Preload("Projects", "user_id = ?", userID).Find(&workers).Error
+	err := query.Preload("Projects", "organization_id = ?", orgID).Find(&workers).Error
 	return workers, total, err
 }
 
-// Update updates a project
-func (r *ProjectRepository) Update(project *model.Project, userID uint) error {
-	// First check if this project belongs to the user
-	result := r.db.Where("id = ? AND user_id = ?", project.ID, userID).First(&model.Project{})
+// Update updates a project. Workers newly listed in its Workers are assigned full time without
+// dates; when that would allocate any of them more than 100 percent nothing is saved and the
+// conflicts are returned with ErrOverAllocated.
+func (r *ProjectRepository) Update(project *model.Project, orgID uint, userID uint) ([]model.WorkerAllocationConflict, error) {
+	// First check if this project belongs to the organization
+	existing := &model.Project{}
+	result := r.db.Where("id = ? AND organization_id = ?", project.ID, orgID).First(existing)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
+	
+	// Keep the creator and owning organization
+	project.UserID = existing.UserID
+	project.OrganizationID = existing.OrganizationID
 
 	// Create a transaction to handle the update
 	tx := r.db.Begin()
 	if tx.Error != nil {
-		return tx.Error
+		return nil, tx.Error
 	}
 
 	// Defer a function to handle transaction completion
@@ -129,82 +181,340 @@ func (r *ProjectRepository) Update(project *model.Project, userID uint) error {
 	}()
 
 	// First, update the project attributes without touching associations
-	if err := tx.Model(project).Omit("Workers").Updates(project).Error; err != nil {
+	if err := tx.Model(project).Omit("Workers", "Assignments", "UserID", "OrganizationID", "CreatedAt").Updates(project).Error; err != nil {
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
+	var conflicts []model.WorkerAllocationConflict
+
 	// If there are workers to update, handle that separately
 	// This approach avoids the automatic M2M association handling that would cause the null user_id issue
 	if len(project.Workers) > 0 {
-		// Clear existing associations
-		if err := tx.Where("project_id = ?", project.ID).Delete(&model.WorkerProject{}).Error; err != nil {
+		workerIDs := make([]uint, 0, len(project.Workers))
+		for _, worker := range project.Workers {
+			workerIDs = append(workerIDs, worker.ID)
+		}
+
+		// Remove the workers no longer in the list, the others keep their assignment details
+		if err := tx.Where("project_id = ? AND worker_id NOT IN ?", project.ID, workerIDs).Delete(&model.WorkerProject{}).Error; err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
 		}
 
-		// Re-add worker associations with the correct user_id
-		for _, worker := range project.Workers {
+		// Add the new workers with the correct organization_id
+		for _, workerID := range workerIDs {
+			var assigned int64
+			if err := tx.Model(&model.WorkerProject{}).Where("project_id = ? AND worker_id = ?", project.ID, workerID).Count(&assigned).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if assigned > 0 {
+				continue
+			}
+
+			// Only workers of the same organization can be assigned
+			worker := &model.Worker{}
+			if err := tx.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
 			workerProject := &model.WorkerProject{
-				WorkerID:  worker.ID,
-				ProjectID: project.ID,
-				UserID:    userID,
+				WorkerID:       workerID,
+				ProjectID:      project.ID,
+				Allocation:     100,
+				UserID:         userID,
+				OrganizationID: orgID,
+			}
+			workerConflicts, err := checkAllocation(tx, workerProject, orgID)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if len(workerConflicts) > 0 {
+				conflicts = append(conflicts, model.WorkerAllocationConflict{WorkerID: workerID, WorkerName: worker.Name, Conflicts: workerConflicts})
+				continue
 			}
-			if err := tx.Create(workerProject).Error; err != nil {
+			if err := tx.Omit("Worker").Create(workerProject).Error; err != nil {
 				tx.Rollback()
-				return err
+				return nil, err
 			}
 		}
 	}
-	
-	return tx.Commit().Error
+
+	// Workers that would be over-allocated have to be assigned through AddWorker, which can be forced
+	if len(conflicts) > 0 {
+		tx.Rollback()
+		return conflicts, ErrOverAllocated
+	}
+
+	return nil, tx.Commit().Error
 }
 
+
 // Delete deletes a project
-func (r *ProjectRepository) Delete(id uint, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.Project{}).Error
+func (r *ProjectRepository) Delete(id uint, orgID uint) error {
+	return r.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&model.Project{}).Error
+}
+
+// AddWorker assigns a worker to a project (ensuring both belong to the organization). The allocation
+// check runs in the same transaction; when the worker would be allocated more than 100 percent the
+// conflicts are returned with ErrOverAllocated unless force is set, in which case the assignment is
+// saved and the conflicts are returned for the audit log.
+func (r *ProjectRepository) AddWorker(assignment *model.WorkerProject, orgID, userID uint, force bool) ([]model.AllocationConflict, error) {
+	var conflicts []model.AllocationConflict
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var assigned int64
+		if err := tx.Model(&model.WorkerProject{}).Where("project_id = ? AND worker_id = ?", assignment.ProjectID, assignment.WorkerID).Count(&assigned).Error; err != nil {
+			return err
+		}
+		if assigned > 0 {
+			return ErrAlreadyAssigned
+		}
+
+		// Verifies that the project and the worker belong to the organization
+		var err error
+		conflicts, err = checkAllocation(tx, assignment, orgID)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 && !force {
+			return ErrOverAllocated
+		}
+
+		// Create the join record with organization_id and the creating user
+		assignment.UserID = userID
+		assignment.OrganizationID = orgID
+
+		// Use the custom join table to create the relationship
+		return tx.Omit("Worker").Create(assignment).Error
+	})
+	return conflicts, err
+}
+
+// GetAssignment retrieves the assignment of a worker to a project within an organization
+func (r *ProjectRepository) GetAssignment(projectID, workerID, orgID uint) (*model.WorkerProject, error) {
+	var assignment model.WorkerProject
+	err := r.db.Preload("Worker").
+		Where("project_id = ? AND worker_id = ? AND organization_id = ?", projectID, workerID, orgID).
+		First(&assignment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// UpdateAssignment changes the dates, allocation, role and notes of an assignment. Like AddWorker it
+// checks the allocation in the same transaction and returns the conflicts, with ErrOverAllocated
+// unless force is set.
+func (r *ProjectRepository) UpdateAssignment(assignment *model.WorkerProject, orgID uint, force bool) ([]model.AllocationConflict, error) {
+	var conflicts []model.AllocationConflict
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.WorkerProject{}
+		err := tx.Where("project_id = ? AND worker_id = ? AND organization_id = ?", assignment.ProjectID, assignment.WorkerID, orgID).
+			First(existing).Error
+		if err != nil {
+			return err
+		}
+
+		// Keep the creating user and owning organization
+		assignment.UserID = existing.UserID
+		assignment.OrganizationID = existing.OrganizationID
+
+		conflicts, err = checkAllocation(tx, assignment, orgID)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 && !force {
+			return ErrOverAllocated
+		}
+
+		return tx.Model(&model.WorkerProject{}).
+			Where("project_id = ? AND worker_id = ? AND organization_id = ?", assignment.ProjectID, assignment.WorkerID, orgID).
+			Updates(map[string]interface{}{
+				"start_date": assignment.StartDate,
+				"end_date":   assignment.EndDate,
+				"allocation": assignment.Allocation,
+				"role":       assignment.Role,
+				"notes":      assignment.Notes,
+			}).Error
+	})
+	return conflicts, err
 }
 
-// AddWorker adds a worker to a project (ensuring both belong to the user)
-func (r *ProjectRepository) AddWorker(projectID, workerID, userID uint) error {
-	// Verify project belongs to user
+// RemoveWorker removes a worker from a project (ensuring both belong to the organization)
+func (r *ProjectRepository) RemoveWorker(projectID, workerID, orgID uint) error {
+	// Verify project belongs to organization
 	project := &model.Project{}
-	if err := r.db.Where("id = ? AND user_id = ?", projectID, userID).First(project).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(project).Error; err != nil {
 		return err
 	}
 	
-	// Verify worker belongs to user
+	// Verify worker belongs to organization
 	worker := &model.Worker{}
-	if err := r.db.Where("id = ? AND user_id = ?", workerID, userID).First(worker).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
 		return err
 	}
 	
-	// Create the join record with user_id
-	workerProject := &model.WorkerProject{
-		WorkerID:  workerID,
-		ProjectID: projectID,
-		UserID:    userID,
-	}
-	
-	// Use the custom join table to create the relationship
-	return r.db.Create(workerProject).Error
+	// Delete the join record that has the appropriate worker_id, project_id AND organization_id
+	return r.db.Where("worker_id = ? AND project_id = ? AND organization_id = ?", 
+		workerID, projectID, orgID).Delete(&model.WorkerProject{}).Error
+}
+
+// allocationRow is another assignment of a worker, as used by the allocation check
+type allocationRow struct {
+	ProjectID   uint
+	ProjectName string
+	StartDate   *time.Time
+	EndDate     *time.Time
+	Allocation  int
+}
+
+// CheckAllocation reports the periods in which the worker of the assignment would be allocated
+// more than 100 percent together with their assignments to other projects. Assignments to
+// completed and cancelled projects are ignored.
+func (r *ProjectRepository) CheckAllocation(assignment *model.WorkerProject, orgID uint) ([]model.AllocationConflict, error) {
+	return checkAllocation(r.db, assignment, orgID)
 }
 
-// RemoveWorker removes a worker from a project (ensuring both belong to the user)
-func (r *ProjectRepository) RemoveWorker(projectID, workerID, userID uint) error {
-	// Verify project belongs to user
+// checkAllocation runs the allocation check of CheckAllocation on the given connection, so that
+// assignments can be checked and saved in one transaction. The worker row stays locked until the
+// transaction ends.
+func checkAllocation(tx *gorm.DB, assignment *model.WorkerProject, orgID uint) ([]model.AllocationConflict, error) {
 	project := &model.Project{}
-	if err := r.db.Where("id = ? AND user_id = ?", projectID, userID).First(project).Error; err != nil {
-		return err
+	if err := tx.Select("id", "name").Where("id = ? AND organization_id = ?", assignment.ProjectID, orgID).First(project).Error; err != nil {
+		return nil, err
 	}
-	
-	// Verify worker belongs to user
-	worker := &model.Worker{}
-	if err := r.db.Where("id = ? AND user_id = ?", workerID, userID).First(worker).Error; err != nil {
-		return err
+	// Lock the worker so that concurrent assignments of the same worker are checked one after the other
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND organization_id = ?", assignment.WorkerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return nil, err
 	}
-	
-	// Delete the join record that has the appropriate worker_id, project_id AND user_id
-	return r.db.Where("worker_id = ? AND project_id = ? AND user_id = ?", 
-		workerID, projectID, userID).Delete(&model.WorkerProject{}).Error
-} 
\ No newline at end of file
+
+	// Other assignments of the worker overlapping the checked one
+	query := tx.Table("worker_projects").
+		Select("worker_projects.project_id, projects.name AS project_name, worker_projects.start_date, worker_projects.end_date, worker_projects.allocation").
+		Joins("JOIN projects ON projects.id = worker_projects.project_id AND projects.deleted_at IS NULL").
+		Where("worker_projects.worker_id = ? AND worker_projects.organization_id = ? AND worker_projects.project_id <> ?",
+			assignment.WorkerID, orgID, assignment.ProjectID).
+		Where("projects.status NOT IN ?", []string{"completed", "cancelled"})
+	if assignment.StartDate != nil {
+		query = query.Where("worker_projects.end_date IS NULL OR worker_projects.end_date >= ?", *assignment.StartDate)
+	}
+	if assignment.EndDate != nil {
+		query = query.Where("worker_projects.start_date IS NULL OR worker_projects.start_date <= ?", *assignment.EndDate)
+	}
+
+	var others []allocationRow
+	if err := query.Order("worker_projects.project_id").Scan(&others).Error; err != nil {
+		return nil, err
+	}
+
+	checked := allocationRow{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		StartDate:   assignment.StartDate,
+		EndDate:     assignment.EndDate,
+		Allocation:  assignment.Allocation,
+	}
+	return allocationConflicts(checked, others), nil
+}
+
+// allocationConflicts splits the period of the checked assignment wherever another assignment
+// starts or ends and returns the parts allocated more than 100 percent, merging adjacent parts
+// with the same projects
+func allocationConflicts(checked allocationRow, others []allocationRow) []model.AllocationConflict {
+	// Open ends are represented by the earliest and latest possible dates
+	openEnd := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	start, end := time.Time{}, openEnd
+	if checked.StartDate != nil {
+		start = *checked.StartDate
+	}
+	if checked.EndDate != nil {
+		end = *checked.EndDate
+	}
+
+	// The days on which the set of active assignments changes
+	points := []time.Time{start}
+	for _, other := range others {
+		if other.StartDate != nil && other.StartDate.After(start) && !other.StartDate.After(end) {
+			points = append(points, *other.StartDate)
+		}
+		if other.EndDate != nil {
+			next := other.EndDate.AddDate(0, 0, 1)
+			if next.After(start) && !next.After(end) {
+				points = append(points, next)
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Before(points[j]) })
+
+	conflicts := make([]model.AllocationConflict, 0)
+	var previousEnd time.Time
+	for i, from := range points {
+		if i > 0 && from.Equal(points[i-1]) {
+			continue
+		}
+		until := end
+		for _, next := range points[i+1:] {
+			if next.After(from) {
+				until = next.AddDate(0, 0, -1)
+				break
+			}
+		}
+
+		total := checked.Allocation
+		shares := []model.AllocationShare{{ProjectID: checked.ProjectID, ProjectName: checked.ProjectName, Allocation: checked.Allocation}}
+		for _, other := range others {
+			active := model.WorkerProject{StartDate: other.StartDate, EndDate: other.EndDate}
+			if active.ActiveOn(from) {
+				total += other.Allocation
+				shares = append(shares, model.AllocationShare{ProjectID: other.ProjectID, ProjectName: other.ProjectName, Allocation: other.Allocation})
+			}
+		}
+		if total <= 100 {
+			continue
+		}
+
+		// Extend the previous conflict when it continues with the same projects
+		if last := len(conflicts) - 1; last >= 0 && previousEnd.AddDate(0, 0, 1).Equal(from) && sameShares(conflicts[last].Projects, shares) {
+			conflicts[last].EndDate = openDate(until, openEnd)
+		} else {
+			conflicts = append(conflicts, model.AllocationConflict{
+				StartDate:  openDate(from, time.Time{}),
+				EndDate:    openDate(until, openEnd),
+				Allocation: total,
+				Projects:   shares,
+			})
+		}
+		previousEnd = until
+	}
+
+	return conflicts
+}
+
+// sameShares reports whether two allocation breakdowns list the same projects
+func sameShares(a, b []model.AllocationShare) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ProjectID != b[i].ProjectID {
+			return false
+		}
+	}
+	return true
+}
+
+// openDate returns nil for the date standing in for an open end, otherwise the date
+func openDate(date, open time.Time) *time.Time {
+	if date.Equal(open) {
+		return nil
+	}
+	return &date
+}
+
+// activeAssignments limits a worker_projects query to the assignments active on the given date
+func activeAssignments(db *gorm.DB, date time.Time) *gorm.DB {
+	return db.Where("worker_projects.start_date IS NULL OR worker_projects.start_date <= ?", date).
+		Where("worker_projects.end_date IS NULL OR worker_projects.end_date >= ?", date)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrVerificationTokenInvalid is returned when a verification token is unknown, expired or already used
+	ErrVerificationTokenInvalid = errors.New("invalid or expired verification token")
+	// ErrInviteInvalid is returned when an invite is unknown, expired, revoked or already used
+	ErrInviteInvalid = errors.New("invalid or expired invite")
+)
+
+// RegistrationRepository handles database operations for email verification and registration invites
+type RegistrationRepository struct {
+	db *gorm.DB
+}
+
+// NewRegistrationRepository creates a new RegistrationRepository instance
+func NewRegistrationRepository() *RegistrationRepository {
+	return &RegistrationRepository{
+		db: config.DB,
+	}
+}
+
+// CreateVerificationToken stores a new verification token and invalidates earlier unused tokens of the user
+func (r *RegistrationRepository) CreateVerificationToken(token *model.EmailVerificationToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// VerifyEmail consumes the verification token with the given hash, marks the email
+// as verified and activates the account. It returns the ID of the verified user.
+func (r *RegistrationRepository) VerifyEmail(hash string) (uint, error) {
+	var token model.EmailVerificationToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVerificationTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&model.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVerificationTokenInvalid
+		}
+
+		// Only accounts still waiting for verification are activated, never deactivated ones
+		return tx.Model(&model.User{}).
+			Where("id = ? AND email_verified = ?", token.UserID, false).
+			Updates(map[string]interface{}{"email_verified": true, "active": true}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return token.UserID, nil
+}
+
+// CreateInvite stores a new invite and revokes earlier pending invites for the same email
+func (r *RegistrationRepository) CreateInvite(invite *model.RegistrationInvite) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.RegistrationInvite{}).
+			Where("LOWER(email) = LOWER(?) AND used_at IS NULL AND revoked_at IS NULL", invite.Email).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(invite).Error
+	})
+}
+
+// GetInvites retrieves all invites, newest first
+func (r *RegistrationRepository) GetInvites() ([]model.RegistrationInvite, error) {
+	var invites []model.RegistrationInvite
+	err := r.db.Order("created_at DESC").Find(&invites).Error
+	return invites, err
+}
+
+// GetValidInvite retrieves an unused, unrevoked and unexpired invite by the hash of its token
+func (r *RegistrationRepository) GetValidInvite(hash string) (*model.RegistrationInvite, error) {
+	var invite model.RegistrationInvite
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", hash, time.Now()).
+		First(&invite).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteInvalid
+		}
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// UseInvite marks an invite as used. Only one registration can use an invite.
+func (r *RegistrationRepository) UseInvite(id uint) error {
+	result := r.db.Model(&model.RegistrationInvite{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+// ReleaseInvite makes a used invite available again, for registrations that failed after using it
+func (r *RegistrationRepository) ReleaseInvite(id uint) error {
+	return r.db.Model(&model.RegistrationInvite{}).Where("id = ?", id).Update("used_at", nil).Error
+}
+
+// RevokeInvite revokes a pending invite
+func (r *RegistrationRepository) RevokeInvite(id uint) error {
+	result := r.db.Model(&model.RegistrationInvite{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
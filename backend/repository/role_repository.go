@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/cache"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRoleInUse is returned when deleting a role that is still assigned to users
+	ErrRoleInUse = errors.New("role is assigned to users")
+	// ErrRoleBuiltIn is returned when deleting a built-in role or changing the admin role
+	ErrRoleBuiltIn = errors.New("built-in role cannot be changed")
+	// ErrUnknownPermission is returned when a role references a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+// RoleRepository handles database operations for roles and permissions
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new RoleRepository instance
+func NewRoleRepository() *RoleRepository {
+	return &RoleRepository{
+		db: config.DB,
+	}
+}
+
+// GetAllPermissions retrieves the permission catalog
+func (r *RoleRepository) GetAllPermissions() ([]model.Permission, error) {
+	var permissions []model.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetAll retrieves all roles with their permissions
+func (r *RoleRepository) GetAll() ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// GetByID retrieves a role with its permissions by ID
+func (r *RoleRepository) GetByID(id uint) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Exists reports whether a role with the given name exists
+func (r *RoleRepository) Exists(name string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Role{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// GetRolePermissions returns the permission names granted to a role.
+// Results are cached briefly because this is checked on every protected request.
+func (r *RoleRepository) GetRolePermissions(name string) ([]string, error) {
+	key := cache.GetCacheKey("role_permissions", name)
+	if cached, ok := cache.QueryCache.Get(key); ok {
+		return cached.([]string), nil
+	}
+
+	var permissions []string
+	err := r.db.Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", name).
+		Pluck("role_permissions.permission_name", &permissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cache.QueryCache.SetWithExpiration(key, permissions, cache.ShortExpiration)
+	return permissions, nil
+}
+
+// Create creates a new role with the given permissions
+func (r *RoleRepository) Create(role *model.Role, permissionNames []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		permissions, err := findPermissions(tx, permissionNames)
+		if err != nil {
+			return err
+		}
+		role.Permissions = permissions
+		return tx.Omit("Permissions.*").Create(role).Error
+	})
+}
+
+// Update changes the description and permissions of a role. The role name cannot change
+// because users reference roles by name.
+func (r *RoleRepository) Update(role *model.Role, permissionNames []string) error {
+	if role.Name == model.RoleAdmin {
+		return ErrRoleBuiltIn
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		permissions, err := findPermissions(tx, permissionNames)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(role).Update("description", role.Description).Error; err != nil {
+			return err
+		}
+		role.Permissions = permissions
+		return tx.Model(role).Omit("Permissions.*").Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return err
+	}
+
+	cache.QueryCache.Delete(cache.GetCacheKey("role_permissions", role.Name))
+	return nil
+}
+
+// Delete deletes a custom role that is not assigned to any user
+func (r *RoleRepository) Delete(role *model.Role) error {
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	var users int64
+	if err := r.db.Model(&model.User{}).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	cache.QueryCache.Delete(cache.GetCacheKey("role_permissions", role.Name))
+	return nil
+}
+
+// findPermissions loads permissions by name, failing if any of them is unknown
+func findPermissions(tx *gorm.DB, names []string) ([]model.Permission, error) {
+	if len(names) == 0 {
+		return []model.Permission{}, nil
+	}
+
+	var permissions []model.Permission
+	if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	// Compare against distinct names so duplicates in the request don't fail the check
+	distinct := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		distinct[name] = struct{}{}
+	}
+	if len(permissions) != len(distinct) {
+		return nil, ErrUnknownPermission
+	}
+	return permissions, nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that was already exchanged is presented again
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
+// sessionLastSeenResolution limits how often the last-seen time of a session is written
+const sessionLastSeenResolution = time.Minute
+
+// SessionRepository handles database operations for login sessions and refresh tokens
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new SessionRepository instance
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{
+		db: config.DB,
+	}
+}
+
+// CreateSession creates a new session together with its first refresh token
+func (r *SessionRepository) CreateSession(session *model.Session, token *model.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		token.SessionID = session.ID
+		return tx.Create(token).Error
+	})
+}
+
+// CreateImpersonationSession creates a session without refresh tokens for an admin acting
+// as another user. It ends when its access token expires or when it is revoked.
+func (r *SessionRepository) CreateImpersonationSession(session *model.Session) error {
+	return r.db.Create(session).Error
+}
+
+// GetSessionByID retrieves a session by ID
+func (r *SessionRepository) GetSessionByID(id string) (*model.Session, error) {
+	var session model.Session
+	if err := r.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value
+func (r *SessionRepository) GetRefreshTokenByHash(hash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks the current refresh token as used and stores its replacement.
+// It returns ErrRefreshTokenReused if the current token was consumed concurrently.
+func (r *SessionRepository) RotateRefreshToken(current *model.RefreshToken, next *model.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Only one caller can consume a refresh token
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		next.SessionID = current.SessionID
+		next.UserID = current.UserID
+		return tx.Create(next).Error
+	})
+}
+
+// RevokeSession revokes a session and therefore its whole refresh token family
+func (r *SessionRepository) RevokeSession(id string) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions revokes every active session of a user, including the sessions
+// in which the user impersonates someone else
+func (r *SessionRepository) RevokeUserSessions(userID uint) error {
+	return r.db.Model(&model.Session{}).
+		Where("(user_id = ? OR impersonator_id = ?) AND revoked_at IS NULL", userID, userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// GetUserSessions retrieves the active sessions of a user, most recently used first
+func (r *SessionRepository) GetUserSessions(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("COALESCE(last_seen_at, created_at) DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeUserSession revokes a session of a user. It returns gorm.ErrRecordNotFound
+// if the user has no such active session.
+func (r *SessionRepository) RevokeUserSession(userID uint, id string) error {
+	result := r.db.Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes every active session of a user except the given one
+func (r *SessionRepository) RevokeOtherSessions(userID uint, keepID string) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchSession reports whether a session exists, is not revoked and has not expired,
+// and records the time and client IP of the request using it
+func (r *SessionRepository) TouchSession(id string, ip string) (bool, error) {
+	var session model.Session
+	now := time.Now()
+	err := r.db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, now).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// Only write once per resolution window or when the client moved to another IP
+	if session.LastSeenAt == nil || now.Sub(*session.LastSeenAt) >= sessionLastSeenResolution || session.IPAddress != ip {
+		r.db.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   ip,
+		})
+	}
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettingRepository handles database operations for system settings
+type SettingRepository struct {
+	db *gorm.DB
+}
+
+// NewSettingRepository creates a new SettingRepository instance
+func NewSettingRepository() *SettingRepository {
+	return &SettingRepository{
+		db: config.DB,
+	}
+}
+
+// Get retrieves the value of a setting, returning defaultValue if it was never set
+func (r *SettingRepository) Get(key, defaultValue string) (string, error) {
+	var setting model.Setting
+	if err := r.db.Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultValue, nil
+		}
+		return defaultValue, err
+	}
+	return setting.Value, nil
+}
+
+// GetBool retrieves a boolean setting, returning defaultValue if it is missing or invalid
+func (r *SettingRepository) GetBool(key string, defaultValue bool) bool {
+	value, err := r.Get(key, "")
+	if err != nil || value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// Set creates or updates a setting
+func (r *SettingRepository) Set(key, value string) error {
+	setting := &model.Setting{Key: key, Value: value}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(setting).Error
+}
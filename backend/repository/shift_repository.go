@@ -0,0 +1,460 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShiftTimes is returned when a shift has no start or end time and no template to take them from
+	ErrShiftTimes = errors.New("shift start and end time are required")
+	// ErrShiftWeekNotEmpty is returned when copying shifts into a week that already has shifts
+	ErrShiftWeekNotEmpty = errors.New("week already has shifts")
+	// ErrShiftWorkerTwice is returned when a worker is listed more than once on a shift
+	ErrShiftWorkerTwice = errors.New("worker is listed more than once on the shift")
+)
+
+// ShiftRepository handles database operations for shift templates and scheduled shifts
+type ShiftRepository struct {
+	db *gorm.DB
+}
+
+// NewShiftRepository creates a new ShiftRepository instance
+func NewShiftRepository() *ShiftRepository {
+	return &ShiftRepository{
+		db: config.DB,
+	}
+}
+
+// shiftRow is another shift of a worker, as used by the conflict checks
+type shiftRow struct {
+	ShiftID   uint
+	WorkerID  uint
+	Date      time.Time
+	StartTime string
+	EndTime   string
+}
+
+// GetTemplates retrieves the shift templates of a project
+func (r *ShiftRepository) GetTemplates(projectID uint, orgID uint) ([]model.ShiftTemplate, error) {
+	var templates []model.ShiftTemplate
+	err := r.db.Where("project_id = ? AND organization_id = ?", projectID, orgID).
+		Order("start_time, name").
+		Find(&templates).Error
+	return templates, err
+}
+
+// CreateTemplate adds a shift template to a project
+func (r *ShiftRepository) CreateTemplate(template *model.ShiftTemplate, orgID uint) error {
+	if err := r.db.Where("id = ? AND organization_id = ?", template.ProjectID, orgID).First(&model.Project{}).Error; err != nil {
+		return err
+	}
+	template.ID = 0
+	template.OrganizationID = orgID
+	return r.db.Create(template).Error
+}
+
+// UpdateTemplate changes a shift template. Shifts already scheduled from it are not changed.
+func (r *ShiftRepository) UpdateTemplate(template *model.ShiftTemplate, orgID uint) error {
+	existing := &model.ShiftTemplate{}
+	err := r.db.Where("id = ? AND project_id = ? AND organization_id = ?", template.ID, template.ProjectID, orgID).
+		First(existing).Error
+	if err != nil {
+		return err
+	}
+
+	// Keep the owning organization and creation time
+	template.OrganizationID = existing.OrganizationID
+	template.CreatedAt = existing.CreatedAt
+	return r.db.Save(template).Error
+}
+
+// DeleteTemplate removes a shift template from a project, keeping the shifts scheduled from it
+func (r *ShiftRepository) DeleteTemplate(projectID, id, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND project_id = ? AND organization_id = ?", id, projectID, orgID).
+			Delete(&model.ShiftTemplate{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&model.Shift{}).Where("template_id = ?", id).Update("template_id", nil).Error
+	})
+}
+
+// GetProjectRoster retrieves the shifts of a project during the week starting on weekStart
+func (r *ShiftRepository) GetProjectRoster(projectID uint, orgID uint, weekStart time.Time) ([]model.Shift, error) {
+	// Verify project belongs to organization
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(&model.Project{}).Error; err != nil {
+		return nil, err
+	}
+
+	var shifts []model.Shift
+	err := r.db.Preload("Workers.Worker").
+		Where("project_id = ? AND organization_id = ?", projectID, orgID).
+		Where("date >= ? AND date <= ?", weekStart, weekStart.AddDate(0, 0, 6)).
+		Order("date, start_time, id").
+		Find(&shifts).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range shifts {
+		shifts[i].Unfilled = shifts[i].UnfilledPositions()
+	}
+	return shifts, nil
+}
+
+// GetWorkerRoster retrieves the shifts of a worker on any project during the week starting on weekStart
+func (r *ShiftRepository) GetWorkerRoster(workerID uint, orgID uint, weekStart time.Time) ([]model.Shift, error) {
+	// Verify worker belongs to organization
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return nil, err
+	}
+
+	var shifts []model.Shift
+	err := r.db.Preload("Project").Preload("Workers.Worker").
+		Joins("JOIN shift_workers ON shift_workers.shift_id = shifts.id AND shift_workers.worker_id = ?", workerID).
+		Where("shifts.organization_id = ?", orgID).
+		Where("shifts.date >= ? AND shifts.date <= ?", weekStart, weekStart.AddDate(0, 0, 6)).
+		Order("shifts.date, shifts.start_time, shifts.id").
+		Find(&shifts).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range shifts {
+		shifts[i].Unfilled = shifts[i].UnfilledPositions()
+	}
+	return shifts, nil
+}
+
+// GetShift retrieves a shift of a project with its workers
+func (r *ShiftRepository) GetShift(projectID, id, orgID uint) (*model.Shift, error) {
+	var shift model.Shift
+	err := r.db.Preload("Workers.Worker").
+		Where("id = ? AND project_id = ? AND organization_id = ?", id, projectID, orgID).
+		First(&shift).Error
+	if err != nil {
+		return nil, err
+	}
+	shift.Unfilled = shift.UnfilledPositions()
+	return &shift, nil
+}
+
+// CreateShift schedules a shift on a project. Times and positions left empty are taken from the
+// shift's template. Nothing is saved when any worker has a conflict; the conflicts are returned instead.
+func (r *ShiftRepository) CreateShift(shift *model.Shift, orgID uint, userID uint, minRest time.Duration) ([]model.ShiftConflict, error) {
+	var conflicts []model.ShiftConflict
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND organization_id = ?", shift.ProjectID, orgID).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+
+		shift.ID = 0
+		shift.UserID = userID
+		shift.OrganizationID = orgID
+		if err := prepareShift(tx, shift); err != nil {
+			return err
+		}
+
+		var err error
+		if conflicts, err = shiftConflicts(tx, shift, minRest); err != nil || len(conflicts) > 0 {
+			return err
+		}
+		return saveShift(tx, shift)
+	})
+	return conflicts, err
+}
+
+// UpdateShift changes a scheduled shift and replaces its workers, unless any worker has a conflict
+func (r *ShiftRepository) UpdateShift(shift *model.Shift, orgID uint, minRest time.Duration) ([]model.ShiftConflict, error) {
+	var conflicts []model.ShiftConflict
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.Shift{}
+		err := tx.Where("id = ? AND project_id = ? AND organization_id = ?", shift.ID, shift.ProjectID, orgID).
+			First(existing).Error
+		if err != nil {
+			return err
+		}
+
+		// Keep the scheduling user, owning organization and creation time
+		shift.UserID = existing.UserID
+		shift.OrganizationID = existing.OrganizationID
+		shift.CreatedAt = existing.CreatedAt
+		if err := prepareShift(tx, shift); err != nil {
+			return err
+		}
+
+		if conflicts, err = shiftConflicts(tx, shift, minRest); err != nil || len(conflicts) > 0 {
+			return err
+		}
+		if err := tx.Where("shift_id = ?", shift.ID).Delete(&model.ShiftWorker{}).Error; err != nil {
+			return err
+		}
+		return saveShift(tx, shift)
+	})
+	return conflicts, err
+}
+
+// DeleteShift removes a scheduled shift and its workers
+func (r *ShiftRepository) DeleteShift(projectID, id, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		shift := &model.Shift{}
+		if err := tx.Where("id = ? AND project_id = ? AND organization_id = ?", id, projectID, orgID).First(shift).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shift_id = ?", shift.ID).Delete(&model.ShiftWorker{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(shift).Error
+	})
+}
+
+// CopyWeek copies the shifts of a project from the week before weekStart into the week starting on
+// weekStart. Workers who can no longer work a copied shift are left off it and reported as conflicts.
+func (r *ShiftRepository) CopyWeek(projectID uint, orgID uint, userID uint, weekStart time.Time, minRest time.Duration) ([]model.Shift, []model.ShiftConflict, error) {
+	weekEnd := weekStart.AddDate(0, 0, 6)
+	skipped := make([]model.ShiftConflict, 0)
+	copiedIDs := make([]uint, 0)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND organization_id = ?", projectID, orgID).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		err := tx.Model(&model.Shift{}).
+			Where("project_id = ? AND organization_id = ? AND date >= ? AND date <= ?", projectID, orgID, weekStart, weekEnd).
+			Count(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrShiftWeekNotEmpty
+		}
+
+		var previous []model.Shift
+		err = tx.Preload("Workers").
+			Where("project_id = ? AND organization_id = ?", projectID, orgID).
+			Where("date >= ? AND date <= ?", weekStart.AddDate(0, 0, -7), weekStart.AddDate(0, 0, -1)).
+			Order("date, start_time, id").
+			Find(&previous).Error
+		if err != nil {
+			return err
+		}
+
+		for _, source := range previous {
+			shift := model.Shift{
+				ProjectID:      source.ProjectID,
+				TemplateID:     source.TemplateID,
+				Date:           dateOnly(source.Date).AddDate(0, 0, 7),
+				StartTime:      source.StartTime,
+				EndTime:        source.EndTime,
+				Positions:      source.Positions,
+				Notes:          source.Notes,
+				UserID:         userID,
+				OrganizationID: orgID,
+			}
+			for _, worker := range source.Workers {
+				shift.Workers = append(shift.Workers, model.ShiftWorker{WorkerID: worker.WorkerID, Position: worker.Position})
+			}
+
+			conflicts, err := shiftConflicts(tx, &shift, minRest)
+			if err != nil {
+				return err
+			}
+			if len(conflicts) > 0 {
+				skipped = append(skipped, conflicts...)
+				blocked := make(map[uint]bool, len(conflicts))
+				for _, conflict := range conflicts {
+					blocked[conflict.WorkerID] = true
+				}
+				available := make([]model.ShiftWorker, 0, len(shift.Workers))
+				for _, worker := range shift.Workers {
+					if !blocked[worker.WorkerID] {
+						available = append(available, worker)
+					}
+				}
+				shift.Workers = available
+			}
+
+			if err := saveShift(tx, &shift); err != nil {
+				return err
+			}
+			copiedIDs = append(copiedIDs, shift.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var copied []model.Shift
+	err = r.db.Preload("Workers.Worker").
+		Where("id IN ?", copiedIDs).
+		Order("date, start_time, id").
+		Find(&copied).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range copied {
+		copied[i].Unfilled = copied[i].UnfilledPositions()
+	}
+	return copied, skipped, nil
+}
+
+// prepareShift normalizes the date of a shift, fills in times and positions from its template and
+// the position of workers scheduled without one, and rejects workers listed twice
+func prepareShift(tx *gorm.DB, shift *model.Shift) error {
+	shift.Date = dateOnly(shift.Date)
+
+	if shift.TemplateID != nil {
+		template := &model.ShiftTemplate{}
+		err := tx.Where("id = ? AND project_id = ? AND organization_id = ?", *shift.TemplateID, shift.ProjectID, shift.OrganizationID).
+			First(template).Error
+		if err != nil {
+			return err
+		}
+		if shift.StartTime == "" && shift.EndTime == "" {
+			shift.StartTime = template.StartTime
+			shift.EndTime = template.EndTime
+		}
+		if len(shift.Positions) == 0 {
+			shift.Positions = template.Positions
+		}
+	}
+	if shift.StartTime == "" || shift.EndTime == "" {
+		return ErrShiftTimes
+	}
+
+	workerIDs := make([]uint, 0, len(shift.Workers))
+	for _, worker := range shift.Workers {
+		workerIDs = append(workerIDs, worker.WorkerID)
+	}
+	if len(workerIDs) == 0 {
+		return nil
+	}
+	if len(uniqueIDs(workerIDs)) != len(workerIDs) {
+		return ErrShiftWorkerTwice
+	}
+
+	var workers []model.Worker
+	if err := tx.Select("id", "position").Where("id IN ? AND organization_id = ?", workerIDs, shift.OrganizationID).Find(&workers).Error; err != nil {
+		return err
+	}
+	if len(workers) != len(workerIDs) {
+		return gorm.ErrRecordNotFound
+	}
+	positions := make(map[uint]string, len(workers))
+	for _, worker := range workers {
+		positions[worker.ID] = worker.Position
+	}
+	for i := range shift.Workers {
+		if shift.Workers[i].Position == "" {
+			shift.Workers[i].Position = positions[shift.Workers[i].WorkerID]
+		}
+	}
+	return nil
+}
+
+// saveShift creates or replaces a shift and creates its workers
+func saveShift(tx *gorm.DB, shift *model.Shift) error {
+	if err := tx.Omit("Project", "Workers").Save(shift).Error; err != nil {
+		return err
+	}
+	for i := range shift.Workers {
+		shift.Workers[i].ID = 0
+		shift.Workers[i].ShiftID = shift.ID
+		shift.Workers[i].Worker = nil
+	}
+	if len(shift.Workers) == 0 {
+		return nil
+	}
+	return tx.Create(&shift.Workers).Error
+}
+
+// shiftConflicts reports the workers of a shift who are not assigned to its project on its date,
+// are on approved leave that day, or have another shift overlapping it or closer than minRest
+func shiftConflicts(tx *gorm.DB, shift *model.Shift, minRest time.Duration) ([]model.ShiftConflict, error) {
+	conflicts := make([]model.ShiftConflict, 0)
+	if len(shift.Workers) == 0 {
+		return conflicts, nil
+	}
+	workerIDs := make([]uint, 0, len(shift.Workers))
+	for _, worker := range shift.Workers {
+		workerIDs = append(workerIDs, worker.WorkerID)
+	}
+
+	var assigned []uint
+	err := activeAssignments(tx.Model(&model.WorkerProject{}), shift.Date).
+		Where("worker_projects.project_id = ? AND worker_projects.organization_id = ? AND worker_projects.worker_id IN ?",
+			shift.ProjectID, shift.OrganizationID, workerIDs).
+		Pluck("worker_projects.worker_id", &assigned).Error
+	if err != nil {
+		return nil, err
+	}
+	isAssigned := make(map[uint]bool, len(assigned))
+	for _, workerID := range assigned {
+		isAssigned[workerID] = true
+	}
+
+	var leaves []model.LeaveRequest
+	err = tx.Where("worker_id IN ? AND status = ? AND start_date <= ? AND end_date >= ?",
+		workerIDs, model.LeaveStatusApproved, shift.Date, shift.Date).
+		Find(&leaves).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Shifts up to two days apart are the only ones that can overlap or cut into the rest time
+	var others []shiftRow
+	err = tx.Table("shift_workers").
+		Select("shift_workers.shift_id, shift_workers.worker_id, shifts.date, shifts.start_time, shifts.end_time").
+		Joins("JOIN shifts ON shifts.id = shift_workers.shift_id").
+		Joins("JOIN projects ON projects.id = shifts.project_id AND projects.deleted_at IS NULL").
+		Where("shift_workers.worker_id IN ? AND shifts.id <> ?", workerIDs, shift.ID).
+		Where("shifts.date >= ? AND shifts.date <= ?", shift.Date.AddDate(0, 0, -2), shift.Date.AddDate(0, 0, 2)).
+		Order("shifts.date, shifts.start_time").
+		Scan(&others).Error
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := shift.Start(), shift.End()
+	for _, worker := range shift.Workers {
+		if !isAssigned[worker.WorkerID] {
+			conflicts = append(conflicts, model.ShiftConflict{WorkerID: worker.WorkerID, Date: shift.Date, Reason: model.ShiftConflictNotAssigned})
+		}
+		for _, leave := range leaves {
+			if leave.WorkerID == worker.WorkerID {
+				leaveID := leave.ID
+				conflicts = append(conflicts, model.ShiftConflict{WorkerID: worker.WorkerID, Date: shift.Date, Reason: model.ShiftConflictOnLeave, LeaveID: &leaveID})
+			}
+		}
+		for _, row := range others {
+			if row.WorkerID != worker.WorkerID {
+				continue
+			}
+			other := model.Shift{Date: row.Date, StartTime: row.StartTime, EndTime: row.EndTime}
+			shiftID := row.ShiftID
+			if other.Start().Before(end) && start.Before(other.End()) {
+				conflicts = append(conflicts, model.ShiftConflict{WorkerID: worker.WorkerID, Date: shift.Date, Reason: model.ShiftConflictOverlap, ShiftID: &shiftID})
+				continue
+			}
+
+			rest := start.Sub(other.End())
+			if other.Start().After(start) {
+				rest = other.Start().Sub(end)
+			}
+			if rest < minRest {
+				hours := rest.Hours()
+				conflicts = append(conflicts, model.ShiftConflict{WorkerID: worker.WorkerID, Date: shift.Date, Reason: model.ShiftConflictRest, ShiftID: &shiftID, RestHours: &hours})
+			}
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// SigningKeyRepository handles database operations for token signing keys
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository instance
+func NewSigningKeyRepository() *SigningKeyRepository {
+	return &SigningKeyRepository{
+		db: config.DB,
+	}
+}
+
+// GetSigningKeys retrieves the keys that are unretired or were retired after the given time, newest first
+func (r *SigningKeyRepository) GetSigningKeys(retiredAfter time.Time) ([]model.SigningKey, error) {
+	var keys []model.SigningKey
+	err := r.db.Where("retired_at IS NULL OR retired_at > ?", retiredAfter).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// RotateSigningKey stores a new key and retires all others
+func (r *SigningKeyRepository) RotateSigningKey(key *model.SigningKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.SigningKey{}).
+			Where("retired_at IS NULL").
+			Update("retired_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+// DeleteRetiredBefore deletes keys retired before the given time, once no valid token can use them
+func (r *SigningKeyRepository) DeleteRetiredBefore(before time.Time) error {
+	return r.db.Where("retired_at < ?", before).Delete(&model.SigningKey{}).Error
+}
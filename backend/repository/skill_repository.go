@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSkillExists is returned when a skill with the same name is already in the catalog
+	ErrSkillExists = errors.New("skill already exists")
+	// ErrSkillInUse is returned when deleting a skill that is still held by workers
+	ErrSkillInUse = errors.New("skill is held by workers")
+)
+
+// SkillRepository handles database operations for the skill catalog and the skills held by workers
+type SkillRepository struct {
+	db *gorm.DB
+}
+
+// NewSkillRepository creates a new SkillRepository instance
+func NewSkillRepository() *SkillRepository {
+	return &SkillRepository{
+		db: config.DB,
+	}
+}
+
+// GetAll retrieves the skill catalog of an organization, optionally only skills of one kind
+func (r *SkillRepository) GetAll(orgID uint, kind string) ([]model.Skill, error) {
+	var skills []model.Skill
+	query := r.db.Where("organization_id = ?", orgID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.Order("name").Find(&skills).Error
+	return skills, err
+}
+
+// GetByID retrieves a skill by ID within an organization
+func (r *SkillRepository) GetByID(id uint, orgID uint) (*model.Skill, error) {
+	var skill model.Skill
+	if err := r.db.Where("id = ? AND organization_id = ?", id, orgID).First(&skill).Error; err != nil {
+		return nil, err
+	}
+	return &skill, nil
+}
+
+// Create adds a skill to the catalog of its organization
+func (r *SkillRepository) Create(skill *model.Skill) error {
+	if err := r.checkNameAvailable(skill); err != nil {
+		return err
+	}
+	return r.db.Create(skill).Error
+}
+
+// Update changes the name, kind and description of a skill
+func (r *SkillRepository) Update(skill *model.Skill, orgID uint) error {
+	existing, err := r.GetByID(skill.ID, orgID)
+	if err != nil {
+		return err
+	}
+	skill.OrganizationID = existing.OrganizationID
+	skill.CreatedAt = existing.CreatedAt
+
+	if err := r.checkNameAvailable(skill); err != nil {
+		return err
+	}
+	return r.db.Model(existing).Updates(map[string]interface{}{
+		"name":        skill.Name,
+		"kind":        skill.Kind,
+		"description": skill.Description,
+	}).Error
+}
+
+// Delete removes a skill from the catalog. Skills still held by workers cannot be deleted.
+func (r *SkillRepository) Delete(id uint, orgID uint) error {
+	skill, err := r.GetByID(id, orgID)
+	if err != nil {
+		return err
+	}
+
+	var held int64
+	if err := r.db.Model(&model.WorkerSkill{}).Where("skill_id = ?", skill.ID).Count(&held).Error; err != nil {
+		return err
+	}
+	if held > 0 {
+		return ErrSkillInUse
+	}
+
+	return r.db.Delete(skill).Error
+}
+
+// GetWorkerSkills retrieves the skills and certifications held by a worker
+func (r *SkillRepository) GetWorkerSkills(workerID uint, orgID uint) ([]model.WorkerSkill, error) {
+	var skills []model.WorkerSkill
+	err := r.db.Preload("Skill").
+		Where("worker_id = ? AND organization_id = ?", workerID, orgID).
+		Order("expiry_date").
+		Find(&skills).Error
+	return skills, err
+}
+
+// AddWorkerSkill records a skill held by a worker (ensuring both belong to the organization)
+func (r *SkillRepository) AddWorkerSkill(workerSkill *model.WorkerSkill, orgID uint) error {
+	if err := r.checkWorkerSkill(workerSkill, orgID); err != nil {
+		return err
+	}
+	workerSkill.OrganizationID = orgID
+	return r.db.Omit("Skill", "Worker").Create(workerSkill).Error
+}
+
+// UpdateWorkerSkill changes the skill, dates and certificate details of a worker's skill
+func (r *SkillRepository) UpdateWorkerSkill(workerSkill *model.WorkerSkill, orgID uint) error {
+	existing := &model.WorkerSkill{}
+	err := r.db.Where("id = ? AND worker_id = ? AND organization_id = ?", workerSkill.ID, workerSkill.WorkerID, orgID).
+		First(existing).Error
+	if err != nil {
+		return err
+	}
+	if err := r.checkWorkerSkill(workerSkill, orgID); err != nil {
+		return err
+	}
+
+	// Keep the owning organization and creation time
+	workerSkill.OrganizationID = existing.OrganizationID
+	workerSkill.CreatedAt = existing.CreatedAt
+	return r.db.Omit("Skill", "Worker").Save(workerSkill).Error
+}
+
+// RemoveWorkerSkill removes a skill from a worker
+func (r *SkillRepository) RemoveWorkerSkill(workerID, id, orgID uint) error {
+	result := r.db.Where("id = ? AND worker_id = ? AND organization_id = ?", id, workerID, orgID).
+		Delete(&model.WorkerSkill{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetExpiring retrieves the skills of current workers that expire between from and until,
+// soonest first
+func (r *SkillRepository) GetExpiring(orgID uint, from, until time.Time) ([]model.WorkerSkill, error) {
+	var skills []model.WorkerSkill
+	err := r.db.Preload("Skill").Preload("Worker").
+		Joins("JOIN workers ON workers.id = worker_skills.worker_id AND workers.deleted_at IS NULL").
+		Where("worker_skills.organization_id = ?", orgID).
+		Where("worker_skills.expiry_date >= ? AND worker_skills.expiry_date < ?", from, until).
+		Order("worker_skills.expiry_date").
+		Find(&skills).Error
+	return skills, err
+}
+
+// checkNameAvailable makes sure no other skill of the organization has the same name
+func (r *SkillRepository) checkNameAvailable(skill *model.Skill) error {
+	var count int64
+	err := r.db.Model(&model.Skill{}).
+		Where("organization_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", skill.OrganizationID, skill.Name, skill.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSkillExists
+	}
+	return nil
+}
+
+// checkWorkerSkill verifies that the worker and the skill belong to the organization
+func (r *SkillRepository) checkWorkerSkill(workerSkill *model.WorkerSkill, orgID uint) error {
+	if err := r.db.Where("id = ? AND organization_id = ?", workerSkill.WorkerID, orgID).First(&model.Worker{}).Error; err != nil {
+		return err
+	}
+	_, err := r.GetByID(workerSkill.SkillID, orgID)
+	return err
+}
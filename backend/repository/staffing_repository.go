@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// StaffingRepository handles database operations for project staffing requirements
+type StaffingRepository struct {
+	db *gorm.DB
+}
+
+// NewStaffingRepository creates a new StaffingRepository instance
+func NewStaffingRepository() *StaffingRepository {
+	return &StaffingRepository{
+		db: config.DB,
+	}
+}
+
+// staffingAssignment is a worker assigned to a project, as used by the gap analysis
+type staffingAssignment struct {
+	ProjectID uint
+	WorkerID  uint
+	StartDate *time.Time
+	EndDate   *time.Time
+	Position  string
+}
+
+// overlaps reports whether the assignment covers at least one day between start and end
+func (a staffingAssignment) overlaps(start, end time.Time) bool {
+	if a.StartDate != nil && a.StartDate.After(end) {
+		return false
+	}
+	return a.EndDate == nil || !a.EndDate.Before(start)
+}
+
+// onLeave reports whether the worker is on approved full-day leave on every working day they are
+// assigned between start and end. Weekends are not expected to be covered by leave.
+func (a staffingAssignment) onLeave(start, end time.Time, leaves []model.LeaveRequest) bool {
+	if len(leaves) == 0 {
+		return false
+	}
+	if a.StartDate != nil && a.StartDate.After(start) {
+		start = *a.StartDate
+	}
+	if a.EndDate != nil && a.EndDate.Before(end) {
+		end = *a.EndDate
+	}
+
+	workingDays := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.UTC().Weekday() == time.Saturday || day.UTC().Weekday() == time.Sunday {
+			continue
+		}
+		workingDays++
+		absent := false
+		for _, leave := range leaves {
+			if leave.FullDayOn(day) {
+				absent = true
+				break
+			}
+		}
+		if !absent {
+			return false
+		}
+	}
+	return workingDays > 0
+}
+
+// GetRequirements retrieves the staffing requirements of a project
+func (r *StaffingRepository) GetRequirements(projectID uint, orgID uint) ([]model.StaffingRequirement, error) {
+	var requirements []model.StaffingRequirement
+	err := r.db.Preload("Skill").
+		Where("project_id = ? AND organization_id = ?", projectID, orgID).
+		Order("start_date, id").
+		Find(&requirements).Error
+	return requirements, err
+}
+
+// CreateRequirement adds a staffing requirement to a project (ensuring the project and skill belong to the organization)
+func (r *StaffingRepository) CreateRequirement(requirement *model.StaffingRequirement, orgID uint) error {
+	if err := r.checkRequirement(requirement, orgID); err != nil {
+		return err
+	}
+	requirement.OrganizationID = orgID
+	return r.db.Omit("Skill").Create(requirement).Error
+}
+
+// UpdateRequirement changes a staffing requirement of a project
+func (r *StaffingRepository) UpdateRequirement(requirement *model.StaffingRequirement, orgID uint) error {
+	existing := &model.StaffingRequirement{}
+	err := r.db.Where("id = ? AND project_id = ? AND organization_id = ?", requirement.ID, requirement.ProjectID, orgID).
+		First(existing).Error
+	if err != nil {
+		return err
+	}
+	if err := r.checkRequirement(requirement, orgID); err != nil {
+		return err
+	}
+
+	// Keep the owning organization and creation time
+	requirement.OrganizationID = existing.OrganizationID
+	requirement.CreatedAt = existing.CreatedAt
+	return r.db.Omit("Skill").Save(requirement).Error
+}
+
+// DeleteRequirement removes a staffing requirement from a project
+func (r *StaffingRepository) DeleteRequirement(projectID, id, orgID uint) error {
+	result := r.db.Where("id = ? AND project_id = ? AND organization_id = ?", id, projectID, orgID).
+		Delete(&model.StaffingRequirement{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetGaps compares the staffing requirements with the workers assigned to the projects for
+// each week starting on from (a Monday). Only projects with requirements in the period are
+// reported. A projectID of 0 reports all projects of the organization.
+func (r *StaffingRepository) GetGaps(orgID uint, projectID uint, from time.Time, weeks int) ([]model.ProjectStaffing, error) {
+	until := from.AddDate(0, 0, 7*weeks)
+
+	// Requirements overlapping the period, of projects that still exist
+	var requirements []model.StaffingRequirement
+	query := r.db.Preload("Skill").
+		Joins("JOIN projects ON projects.id = staffing_requirements.project_id AND projects.deleted_at IS NULL").
+		Where("staffing_requirements.organization_id = ?", orgID).
+		Where("staffing_requirements.start_date < ? AND staffing_requirements.end_date >= ?", until, from)
+	if projectID != 0 {
+		query = query.Where("staffing_requirements.project_id = ?", projectID)
+	}
+	if err := query.Order("staffing_requirements.project_id, staffing_requirements.start_date, staffing_requirements.id").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	if len(requirements) == 0 {
+		return []model.ProjectStaffing{}, nil
+	}
+
+	projectIDs := make([]uint, 0)
+	for _, requirement := range requirements {
+		if len(projectIDs) == 0 || projectIDs[len(projectIDs)-1] != requirement.ProjectID {
+			projectIDs = append(projectIDs, requirement.ProjectID)
+		}
+	}
+
+	var projects []model.Project
+	if err := r.db.Select("id", "name").Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string)
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+
+	// Workers assigned to the projects during the period
+	var assignments []staffingAssignment
+	err := r.db.Table("worker_projects").
+		Select("worker_projects.project_id, worker_projects.worker_id, worker_projects.start_date, worker_projects.end_date, workers.position").
+		Joins("JOIN workers ON workers.id = worker_projects.worker_id AND workers.deleted_at IS NULL").
+		Where("worker_projects.organization_id = ? AND worker_projects.project_id IN ?", orgID, projectIDs).
+		Where("worker_projects.start_date IS NULL OR worker_projects.start_date < ?", until).
+		Where("worker_projects.end_date IS NULL OR worker_projects.end_date >= ?", from).
+		Scan(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Skills held by the assigned workers
+	workerIDs := make([]uint, 0, len(assignments))
+	for _, assignment := range assignments {
+		workerIDs = append(workerIDs, assignment.WorkerID)
+	}
+	skills := make(map[uint][]model.WorkerSkill)
+	if len(workerIDs) > 0 {
+		var held []model.WorkerSkill
+		if err := r.db.Where("organization_id = ? AND worker_id IN ?", orgID, workerIDs).Find(&held).Error; err != nil {
+			return nil, err
+		}
+		for _, workerSkill := range held {
+			skills[workerSkill.WorkerID] = append(skills[workerSkill.WorkerID], workerSkill)
+		}
+	}
+
+	// Approved leave of the assigned workers during the period
+	leaves := make(map[uint][]model.LeaveRequest)
+	if len(workerIDs) > 0 {
+		var approved []model.LeaveRequest
+		err := r.db.Where("organization_id = ? AND worker_id IN ? AND status = ?", orgID, workerIDs, model.LeaveStatusApproved).
+			Where("start_date < ? AND end_date >= ?", until, from).
+			Find(&approved).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, leave := range approved {
+			leaves[leave.WorkerID] = append(leaves[leave.WorkerID], leave)
+		}
+	}
+
+	report := make([]model.ProjectStaffing, 0, len(projectIDs))
+	for _, id := range projectIDs {
+		project := model.ProjectStaffing{
+			ProjectID:   id,
+			ProjectName: names[id],
+			Weeks:       make([]model.StaffingWeek, 0, weeks),
+		}
+		for week := 0; week < weeks; week++ {
+			weekStart := from.AddDate(0, 0, 7*week)
+			project.Weeks = append(project.Weeks, staffingWeek(id, weekStart, requirements, assignments, skills, leaves))
+		}
+		report = append(report, project)
+	}
+
+	return report, nil
+}
+
+// staffingWeek computes the gaps of one project during the week starting on weekStart.
+// A worker counts towards every requirement they match while assigned during its period, unless
+// they are on approved leave for all of it.
+func staffingWeek(projectID uint, weekStart time.Time, requirements []model.StaffingRequirement, assignments []staffingAssignment, skills map[uint][]model.WorkerSkill, leaves map[uint][]model.LeaveRequest) model.StaffingWeek {
+	weekEnd := weekStart.AddDate(0, 0, 6)
+	week := model.StaffingWeek{
+		WeekStart:    weekStart,
+		Requirements: make([]model.StaffingGap, 0),
+	}
+
+	matched := make(map[uint]bool)
+	for _, assignment := range assignments {
+		if assignment.ProjectID != projectID || !assignment.overlaps(weekStart, weekEnd) {
+			continue
+		}
+		if assignment.onLeave(weekStart, weekEnd, leaves[assignment.WorkerID]) {
+			week.OnLeave++
+		} else {
+			week.Assigned++
+			matched[assignment.WorkerID] = false
+		}
+	}
+
+	for _, requirement := range requirements {
+		if requirement.ProjectID != projectID || requirement.StartDate.After(weekEnd) || requirement.EndDate.Before(weekStart) {
+			continue
+		}
+
+		// The part of the week the requirement applies to
+		periodStart, periodEnd := weekStart, weekEnd
+		if requirement.StartDate.After(periodStart) {
+			periodStart = requirement.StartDate
+		}
+		if requirement.EndDate.Before(periodEnd) {
+			periodEnd = requirement.EndDate
+		}
+
+		gap := model.StaffingGap{
+			RequirementID: requirement.ID,
+			Position:      requirement.Position,
+			SkillID:       requirement.SkillID,
+			Required:      requirement.Headcount,
+		}
+		if requirement.Skill != nil {
+			gap.SkillName = requirement.Skill.Name
+		}
+
+		for _, assignment := range assignments {
+			if assignment.ProjectID != projectID || !assignment.overlaps(periodStart, periodEnd) ||
+				assignment.onLeave(periodStart, periodEnd, leaves[assignment.WorkerID]) {
+				continue
+			}
+			if matchesRequirement(requirement, assignment, skills[assignment.WorkerID], periodStart, periodEnd) {
+				gap.Assigned++
+				matched[assignment.WorkerID] = true
+			}
+		}
+
+		if gap.Assigned < gap.Required {
+			gap.Shortfall = gap.Required - gap.Assigned
+		} else {
+			gap.Surplus = gap.Assigned - gap.Required
+		}
+
+		week.Required += gap.Required
+		week.Shortfall += gap.Shortfall
+		week.Surplus += gap.Surplus
+		week.Requirements = append(week.Requirements, gap)
+	}
+
+	for _, isMatched := range matched {
+		if !isMatched {
+			week.Unmatched++
+		}
+	}
+
+	return week
+}
+
+// matchesRequirement reports whether an assigned worker has the required position, or holds
+// the required skill for the whole period
+func matchesRequirement(requirement model.StaffingRequirement, assignment staffingAssignment, held []model.WorkerSkill, periodStart, periodEnd time.Time) bool {
+	if requirement.SkillID == nil {
+		return strings.EqualFold(strings.TrimSpace(assignment.Position), strings.TrimSpace(requirement.Position))
+	}
+
+	for _, workerSkill := range held {
+		if workerSkill.SkillID != *requirement.SkillID {
+			continue
+		}
+		if workerSkill.IssueDate != nil && workerSkill.IssueDate.After(periodStart) {
+			continue
+		}
+		if workerSkill.ExpiryDate != nil && workerSkill.ExpiryDate.Before(periodEnd) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// checkRequirement verifies that the project and the required skill belong to the organization
+func (r *StaffingRepository) checkRequirement(requirement *model.StaffingRequirement, orgID uint) error {
+	if err := r.db.Where("id = ? AND organization_id = ?", requirement.ProjectID, orgID).First(&model.Project{}).Error; err != nil {
+		return err
+	}
+	if requirement.SkillID != nil {
+		return r.db.Where("id = ? AND organization_id = ?", *requirement.SkillID, orgID).First(&model.Skill{}).Error
+	}
+	return nil
+}
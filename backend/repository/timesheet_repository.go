@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
+	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
+	"gorm.io/gorm"
+)
+
+// maxHoursPerDay is the most hours a worker can record on one day across all projects
+const maxHoursPerDay = 24
+
+var (
+	// ErrNotAssigned is returned when recording hours for a worker not assigned to the project on that date
+	ErrNotAssigned = errors.New("worker is not assigned to the project on that date")
+	// ErrTimesheetLocked is returned when changing an entry that is submitted or approved
+	ErrTimesheetLocked = errors.New("timesheet entry is submitted or approved")
+	// ErrTimesheetExists is returned when the worker already has an entry for the project and date
+	ErrTimesheetExists = errors.New("timesheet entry already exists")
+	// ErrTimesheetStatus is returned when an entry is not in the state a review step expects
+	ErrTimesheetStatus = errors.New("timesheet entry is not in the expected state")
+	// ErrTooManyHours is returned when a worker would record more than 24 hours on one day
+	ErrTooManyHours = errors.New("worker would record more than 24 hours on one day")
+	// ErrPayPeriodFinalized is returned when recording or approving hours on a day already paid by a finalized payroll run
+	ErrPayPeriodFinalized = errors.New("date is in the period of a finalized payroll run")
+)
+
+// TimesheetRepository handles database operations for timesheet entries
+type TimesheetRepository struct {
+	db *gorm.DB
+}
+
+// NewTimesheetRepository creates a new TimesheetRepository instance
+func NewTimesheetRepository() *TimesheetRepository {
+	return &TimesheetRepository{
+		db: config.DB,
+	}
+}
+
+// GetAll retrieves the timesheet entries of an organization, newest first. Supported filters are
+// "worker_id", "project_id", "status", "from" and "until".
+func (r *TimesheetRepository) GetAll(orgID uint, filters map[string]interface{}, page int, pageSize int) ([]model.TimesheetEntry, int64, error) {
+	var entries []model.TimesheetEntry
+	var total int64
+	query := r.db.Model(&model.TimesheetEntry{}).Where("organization_id = ?", orgID)
+
+	// Apply filters
+	for key, value := range filters {
+		switch key {
+		case "worker_id", "project_id", "status":
+			query = query.Where(key+" = ?", value)
+		case "from":
+			query = query.Where("date >= ?", value)
+		case "until":
+			query = query.Where("date <= ?", value)
+		}
+	}
+
+	// Count total records (before pagination)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	if page > 0 && pageSize > 0 {
+		offset := (page - 1) * pageSize
+		query = query.Offset(offset).Limit(pageSize)
+	}
+
+	err := query.Preload("Worker").Preload("Project").
+		Order("date DESC, worker_id").
+		Find(&entries).Error
+	return entries, total, err
+}
+
+// GetByID retrieves a timesheet entry by ID within an organization
+func (r *TimesheetRepository) GetByID(id uint, orgID uint) (*model.TimesheetEntry, error) {
+	var entry model.TimesheetEntry
+	err := r.db.Preload("Worker").Preload("Project").
+		Where("id = ? AND organization_id = ?", id, orgID).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Create records a draft timesheet entry
+func (r *TimesheetRepository) Create(entry *model.TimesheetEntry, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		entry.ID = 0
+		entry.Date = dateOnly(entry.Date)
+		entry.Status = model.TimesheetStatusDraft
+		entry.UserID = userID
+		entry.OrganizationID = orgID
+
+		if err := checkTimesheetEntry(tx, entry); err != nil {
+			return err
+		}
+		return tx.Omit("Worker", "Project").Create(entry).Error
+	})
+}
+
+// Update changes a draft or rejected entry. A corrected rejected entry becomes a draft again.
+func (r *TimesheetRepository) Update(entry *model.TimesheetEntry, orgID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		existing := &model.TimesheetEntry{}
+		if err := tx.Where("id = ? AND organization_id = ?", entry.ID, orgID).First(existing).Error; err != nil {
+			return err
+		}
+		if !existing.Editable() {
+			return ErrTimesheetLocked
+		}
+
+		// Keep the recording user, owning organization and creation time
+		entry.Date = dateOnly(entry.Date)
+		entry.Status = model.TimesheetStatusDraft
+		entry.ReviewedByID = nil
+		entry.ReviewedAt = nil
+		entry.RejectionReason = ""
+		entry.UserID = existing.UserID
+		entry.OrganizationID = existing.OrganizationID
+		entry.CreatedAt = existing.CreatedAt
+
+		if err := checkTimesheetEntry(tx, entry); err != nil {
+			return err
+		}
+		return tx.Omit("Worker", "Project").Save(entry).Error
+	})
+}
+
+// Delete removes a draft or rejected entry
+func (r *TimesheetRepository) Delete(id uint, orgID uint) error {
+	entry := &model.TimesheetEntry{}
+	if err := r.db.Where("id = ? AND organization_id = ?", id, orgID).First(entry).Error; err != nil {
+		return err
+	}
+	if !entry.Editable() {
+		return ErrTimesheetLocked
+	}
+	return r.db.Delete(entry).Error
+}
+
+// SaveWeek records the hours of a crew on a project in one transaction. Existing draft or rejected
+// entries of the same day are replaced, and removed when the new hours are zero. Days without hours
+// and without an entry are skipped.
+func (r *TimesheetRepository) SaveWeek(entries []model.TimesheetEntry, orgID uint, userID uint) ([]model.TimesheetEntry, error) {
+	saved := make([]model.TimesheetEntry, 0, len(entries))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			entry.Date = dateOnly(entry.Date)
+			entry.Status = model.TimesheetStatusDraft
+			entry.UserID = userID
+			entry.OrganizationID = orgID
+
+			if entry.RegularHours == 0 && entry.OvertimeHours == 0 {
+				if err := deleteTimesheetEntry(tx, &entry); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := saveTimesheetEntry(tx, &entry); err != nil {
+				return err
+			}
+			saved = append(saved, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// Submit sends draft and rejected entries for review
+func (r *TimesheetRepository) Submit(ids []uint, orgID uint) ([]model.TimesheetEntry, error) {
+	return r.transition(ids, orgID, []string{model.TimesheetStatusDraft, model.TimesheetStatusRejected}, nil, map[string]interface{}{
+		"status":           model.TimesheetStatusSubmitted,
+		"reviewed_by_id":   nil,
+		"reviewed_at":      nil,
+		"rejection_reason": "",
+	})
+}
+
+// Approve approves submitted entries. Entries on days paid by a finalized payroll run cannot be
+// approved, since no later run would pay them.
+func (r *TimesheetRepository) Approve(ids []uint, orgID uint, reviewerID uint) ([]model.TimesheetEntry, error) {
+	return r.transition(ids, orgID, []string{model.TimesheetStatusSubmitted}, checkPayrollLock, map[string]interface{}{
+		"status":           model.TimesheetStatusApproved,
+		"reviewed_by_id":   reviewerID,
+		"reviewed_at":      time.Now(),
+		"rejection_reason": "",
+	})
+}
+
+// Reject sends submitted entries back for correction
+func (r *TimesheetRepository) Reject(ids []uint, orgID uint, reviewerID uint, reason string) ([]model.TimesheetEntry, error) {
+	return r.transition(ids, orgID, []string{model.TimesheetStatusSubmitted}, nil, map[string]interface{}{
+		"status":           model.TimesheetStatusRejected,
+		"reviewed_by_id":   reviewerID,
+		"reviewed_at":      time.Now(),
+		"rejection_reason": reason,
+	})
+}
+
+// transition moves all given entries from one of the expected states to a new one, or none of them.
+// When check is given, every entry must also pass it.
+func (r *TimesheetRepository) transition(ids []uint, orgID uint, from []string, check func(tx *gorm.DB, entry *model.TimesheetEntry) error, updates map[string]interface{}) ([]model.TimesheetEntry, error) {
+	var entries []model.TimesheetEntry
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ? AND organization_id = ?", ids, orgID).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) != len(uniqueIDs(ids)) {
+			return gorm.ErrRecordNotFound
+		}
+		for i := range entries {
+			if !contains(from, entries[i].Status) {
+				return ErrTimesheetStatus
+			}
+			if check != nil {
+				if err := check(tx, &entries[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&model.TimesheetEntry{}).Where("id IN ? AND organization_id = ?", ids, orgID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.Preload("Worker").Preload("Project").
+		Where("id IN ? AND organization_id = ?", ids, orgID).
+		Order("date, worker_id").
+		Find(&entries).Error
+	return entries, err
+}
+
+// saveTimesheetEntry creates the entry of a worker on a project and day, or replaces the existing
+// draft or rejected one, and reports whether it was created
+func saveTimesheetEntry(tx *gorm.DB, entry *model.TimesheetEntry) (bool, error) {
+	existing := &model.TimesheetEntry{}
+	err := tx.Where("worker_id = ? AND project_id = ? AND date = ? AND organization_id = ?",
+		entry.WorkerID, entry.ProjectID, entry.Date, entry.OrganizationID).First(existing).Error
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	if found {
+		if !existing.Editable() {
+			return false, ErrTimesheetLocked
+		}
+		entry.ID = existing.ID
+		entry.UserID = existing.UserID
+		entry.CreatedAt = existing.CreatedAt
+	}
+
+	if err := checkTimesheetEntry(tx, entry); err != nil {
+		return false, err
+	}
+	if err := tx.Omit("Worker", "Project").Save(entry).Error; err != nil {
+		return false, err
+	}
+	return !found, nil
+}
+
+// deleteTimesheetEntry removes the draft or rejected entry of a worker on a project and day, if any
+func deleteTimesheetEntry(tx *gorm.DB, entry *model.TimesheetEntry) error {
+	existing := &model.TimesheetEntry{}
+	err := tx.Where("worker_id = ? AND project_id = ? AND date = ? AND organization_id = ?",
+		entry.WorkerID, entry.ProjectID, entry.Date, entry.OrganizationID).First(existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !existing.Editable() {
+		return ErrTimesheetLocked
+	}
+	return tx.Delete(existing).Error
+}
+
+// checkTimesheetEntry verifies that the worker is assigned to the project on the entry's date, that
+// the day has not been paid by a finalized payroll run, and that the worker does not exceed 24 hours
+// on that day across all projects
+func checkTimesheetEntry(tx *gorm.DB, entry *model.TimesheetEntry) error {
+	if err := checkPayrollLock(tx, entry); err != nil {
+		return err
+	}
+
+	var assigned int64
+	err := activeAssignments(tx.Model(&model.WorkerProject{}), entry.Date).
+		Where("worker_projects.worker_id = ? AND worker_projects.project_id = ? AND worker_projects.organization_id = ?",
+			entry.WorkerID, entry.ProjectID, entry.OrganizationID).
+		Count(&assigned).Error
+	if err != nil {
+		return err
+	}
+	if assigned == 0 {
+		return ErrNotAssigned
+	}
+
+	var conflicting int64
+	err = tx.Model(&model.TimesheetEntry{}).
+		Where("worker_id = ? AND project_id = ? AND date = ? AND id <> ?", entry.WorkerID, entry.ProjectID, entry.Date, entry.ID).
+		Count(&conflicting).Error
+	if err != nil {
+		return err
+	}
+	if conflicting > 0 {
+		return ErrTimesheetExists
+	}
+
+	var recorded float64
+	err = tx.Model(&model.TimesheetEntry{}).
+		Select("COALESCE(SUM(regular_hours + overtime_hours), 0)").
+		Where("worker_id = ? AND date = ? AND id <> ?", entry.WorkerID, entry.Date, entry.ID).
+		Scan(&recorded).Error
+	if err != nil {
+		return err
+	}
+	if recorded+entry.RegularHours+entry.OvertimeHours > maxHoursPerDay {
+		return ErrTooManyHours
+	}
+
+	return nil
+}
+
+// checkPayrollLock verifies that the entry's date is not in the period of a finalized payroll run
+// of its organization. Hours approved after a run is finalized would never be paid, because no
+// later run may overlap its period.
+func checkPayrollLock(tx *gorm.DB, entry *model.TimesheetEntry) error {
+	var count int64
+	err := tx.Model(&model.PayrollRun{}).
+		Where("organization_id = ? AND status = ?", entry.OrganizationID, model.PayrollStatusFinalized).
+		Where("period_start <= ? AND period_end >= ?", entry.Date, entry.Date).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPayPeriodFinalized
+	}
+	return nil
+}
+
+// dateOnly returns the calendar day of t as midnight UTC
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// uniqueIDs removes duplicate IDs
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
@@ -9,14 +10,23 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrInvalidRole is returned when assigning a role that does not exist
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrEmailNotVerified is returned on login with the correct password before the email was verified
+	ErrEmailNotVerified = errors.New("email not verified")
+)
+
 type UserRepository interface {
 	CreateUser(user *model.User, plainPassword string) error
 	GetUserByID(id uint) (*model.User, error)
 	GetUserByUsername(username string) (*model.User, error)
 	GetUserByEmail(email string) (*model.User, error)
 	ValidateCredentials(username, password string) (*model.User, error)
+	VerifyPassword(userID uint, password string) error
 	UpdateLastLogin(userID uint) error
-	ChangePassword(userID uint, newPassword string) error
+	ChangePassword(userID uint, newPassword string, historySize int) error
+	IsPasswordReused(userID uint, password string, historySize int) (bool, error)
 	GetAllUsers(page, pageSize int, search string) ([]model.User, int64, error)
 	UpdateUserStatus(userID uint, active bool) error
 	UpdateUserRole(userID uint, role string) error
@@ -32,7 +42,7 @@ func NewUserRepository() UserRepository {
 	}
 }
 
-// CreateUser creates a new user with hashed password
+// CreateUser creates a new user with hashed password and a personal organization
 func (r *userRepository) CreateUser(user *model.User, plainPassword string) error {
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plainPassword), bcrypt.DefaultCost)
@@ -41,8 +51,22 @@ func (r *userRepository) CreateUser(user *model.User, plainPassword string) erro
 	}
 	
 	user.PasswordHash = string(hashedPassword)
+	now := time.Now()
+	user.PasswordChangedAt = &now
 	
-	return r.db.Create(user).Error
+	// Every user gets a personal organization for their own workers and projects
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		// GORM writes the column default for a false Active, so set it explicitly
+		if !user.Active {
+			if err := tx.Model(user).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		return createPersonalOrganization(tx, user)
+	})
 }
 
 // GetUserByID retrieves a user by ID
@@ -82,34 +106,111 @@ func (r *userRepository) ValidateCredentials(username, password string) (*model.
 		return nil, err
 	}
 	
-	// Check if user is active
-	if !user.Active {
-		return nil, errors.New("account is inactive")
-	}
-	
 	// Compare password with hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
 		return nil, errors.New("invalid credentials")
 	}
 	
+	// Check if user is active, accounts waiting for email verification are inactive too
+	if !user.Active {
+		if !user.EmailVerified {
+			return nil, ErrEmailNotVerified
+		}
+		return nil, errors.New("account is inactive")
+	}
+	
 	return user, nil
 }
 
+// VerifyPassword checks a password against the stored hash of a user
+func (r *userRepository) VerifyPassword(userID uint, password string) error {
+	user, err := r.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return errors.New("invalid credentials")
+	}
+	
+	return nil
+}
+
 // UpdateLastLogin updates the last login timestamp for a user
 func (r *userRepository) UpdateLastLogin(userID uint) error {
 	return r.db.Exec("UPDATE users SET last_login = NOW() WHERE id = ?", userID).Error
 }
 
-// ChangePassword changes a user's password
-func (r *userRepository) ChangePassword(userID uint, newPassword string) error {
+// ChangePassword changes a user's password. The replaced hash is kept in the password history,
+// which is trimmed so that together with the current password it covers historySize passwords.
+func (r *userRepository) ChangePassword(userID uint, newPassword string, historySize int) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 	
-	return r.db.Model(&model.User{}).Where("id = ?", userID).
-		Update("password_hash", string(hashedPassword)).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		
+		// Keep the replaced password and drop history entries beyond the configured size
+		prune := tx.Where("user_id = ?", userID)
+		if historySize > 1 {
+			if err := tx.Create(&model.PasswordHistory{UserID: userID, PasswordHash: user.PasswordHash}).Error; err != nil {
+				return err
+			}
+			prune = prune.Where("id NOT IN (?)", tx.Model(&model.PasswordHistory{}).Select("id").
+				Where("user_id = ?", userID).
+				Order("created_at DESC, id DESC").
+				Limit(historySize-1))
+		}
+		if err := prune.Delete(&model.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash":       string(hashedPassword),
+			"password_changed_at": time.Now(),
+		}).Error
+	})
+}
+
+// IsPasswordReused reports whether a password matches the current password of a user
+// or one of the previous passwords kept in the history, historySize passwords in total
+func (r *userRepository) IsPasswordReused(userID uint, password string, historySize int) (bool, error) {
+	if historySize <= 0 {
+		return false, nil
+	}
+	
+	user, err := r.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	hashes := []string{user.PasswordHash}
+	
+	var history []model.PasswordHistory
+	if historySize > 1 {
+		err := r.db.Where("user_id = ?", userID).
+			Order("created_at DESC, id DESC").
+			Limit(historySize - 1).
+			Find(&history).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	for _, entry := range history {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+	
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // GetAllUsers retrieves all users with pagination and search
@@ -150,8 +251,12 @@ func (r *userRepository) UpdateUserStatus(userID uint, active bool) error {
 // UpdateUserRole changes a user's role
 func (r *userRepository) UpdateUserRole(userID uint, role string) error {
 	// Validate role
-	if role != "user" && role != "admin" {
-		return errors.New("invalid role")
+	var count int64
+	if err := r.db.Model(&model.Role{}).Where("name = ?", role).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidRole
 	}
 	
 	return r.db.Model(&model.User{}).Where("id = ?", userID).Update("role", role).Error
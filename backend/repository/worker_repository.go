@@ -1,11 +1,19 @@
 package repository
 
 import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/config"
 	"github.com/Forquosh/Worksite-Management-Studio-Online/backend/model"
 	"gorm.io/gorm"
 )
 
+// ErrBackdatedChange is returned when a position or salary change would take effect before the latest recorded change
+var ErrBackdatedChange = errors.New("change would take effect before the latest recorded change")
+
 type WorkerRepository struct {
 	db *gorm.DB
 }
@@ -16,51 +24,38 @@ func NewWorkerRepository() *WorkerRepository {
 	}
 }
 
-// Create creates a new worker
+// Create creates a new worker and records the first position and salary in its history.
+// Skills are managed through the SkillRepository.
 func (r *WorkerRepository) Create(worker *model.Worker) error {
-	return r.db.Create(worker).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Skills").Create(worker).Error; err != nil {
+			return err
+		}
+		return recordWorkerChanges(tx, &model.Worker{}, worker, worker.UserID)
+	})
 }
 
-// GetByID retrieves a worker by ID and user ID
-func (r *WorkerRepository) GetByID(id uint, userID uint) (*model.Worker, error) {
+// GetByID retrieves a worker by ID within an organization
+func (r *WorkerRepository) GetByID(id uint, orgID uint) (*model.Worker, error) {
 	var worker model.Worker
-	// Use preload with a custom join query to check both project's user_id and join table's user_id
-	err := r.db.Preload("Projects", func(db *gorm.DB) *gorm.DB {
-		return db.Joins("JOIN worker_projects ON worker_projects.project_id = projects.id").
-			Where("projects.user_id = ? AND worker_projects.user_id = ?", userID, userID)
-	}).Where("id = ? AND user_id = ?", id, userID).First(&worker).Error
+	// Only preload projects of the same organization
+	err := r.db.Preload("Projects", "organization_id = ?", orgID).
+		Preload("Skills.Skill").
+		Where("id = ? AND organization_id = ?", id, orgID).First(&worker).Error
 	if err != nil {
 		return nil, err
 	}
 	return &worker, nil
 }
 
-// GetAll retrieves all workers with optional filtering and sorting for a specific user
-func (r *WorkerRepository) GetAll(userID uint, filters map[string]interface{}, sortBy string, sortOrder string, page int, pageSize int) ([]model.Worker, int64, error) {
+// GetAll retrieves all workers with optional filtering and sorting for an organization
+func (r *WorkerRepository) GetAll(orgID uint, filters map[string]interface{}, sortBy string, sortOrder string, page int, pageSize int) ([]model.Worker, int64, error) {
 	var workers []model.Worker
 	var total int64
-	query := r.db.Model(&model.Worker{}).Where("user_id = ?", userID)
+	query := r.db.Model(&model.Worker{}).Where("organization_id = ?", orgID)
 
 	// Apply filters
-	for key, value := range filters {
-		switch key {
-		case "search":
-			searchTerm := value.(string)
-			query = query.Where("name LIKE ? OR position LIKE ?", "%"+searchTerm+"%", "%"+searchTerm+"%")
-		case "position":
-			query = query.Where("position = ?", value)
-		case "min_age":
-			query = query.Where("age >= ?", value)
-		case "max_age":
-			query = query.Where("age <= ?", value)
-		case "min_salary":
-			query = query.Where("salary >= ?", value)
-		case "max_salary":
-			query = query.Where("salary <= ?", value)
-		default:
-			query = query.Where(key+" = ?", value)
-		}
-	}
+	query = applyWorkerFilters(r.db, query, filters)
 
 	// Count total records (before pagination)
 	if err := query.Count(&total).Error; err != nil {
@@ -82,71 +77,297 @@ func (r *WorkerRepository) GetAll(userID uint, filters map[string]interface{}, s
 		query = query.Offset(offset).Limit(pageSize)
 	}
 
-	// Add user_id condition to the preloaded Projects to ensure we only get projects belonging to the current user
-	// Also ensure the worker_projects join table has the correct user_id
-	err := query.Preload("Projects", func(db *gorm.DB) *gorm.DB {
-		return db.Joins("JOIN worker_projects ON worker_projects.project_id = projects.id").
-			Where("projects.user_id = ? AND worker_projects.user_id = ?", userID, userID)
-	}).Find(&workers).Error
+	// Only preload projects of the same organization
+	err := query.Preload("Projects", "organization_id = ?", orgID).Find(&workers).Error
 	return workers, total, err
 }
 
 // Update updates a worker
-func (r *WorkerRepository) Update(worker *model.Worker, userID uint) error {
-	// First check if this worker belongs to the user
-	result := r.db.Where("id = ? AND user_id = ?", worker.ID, userID).First(&model.Worker{})
-	if result.Error != nil {
-		return result.Error
-	}
-	
-	return r.db.Save(worker).Error
+func (r *WorkerRepository) Update(worker *model.Worker, orgID uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// First check if this worker belongs to the organization
+		existing := &model.Worker{}
+		result := tx.Where("id = ? AND organization_id = ?", worker.ID, orgID).First(existing)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Keep the creator, owning organization and creation time
+		worker.UserID = existing.UserID
+		worker.OrganizationID = existing.OrganizationID
+		worker.CreatedAt = existing.CreatedAt
+
+		if err := tx.Omit("Skills").Save(worker).Error; err != nil {
+			return err
+		}
+		return recordWorkerChanges(tx, existing, worker, userID)
+	})
 }
 
 // Delete deletes a worker
-func (r *WorkerRepository) Delete(id uint, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.Worker{}).Error
+func (r *WorkerRepository) Delete(id uint, orgID uint) error {
+	return r.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&model.Worker{}).Error
 }
 
-// AddToProject adds a worker to a project (ensuring both belong to the user)
-func (r *WorkerRepository) AddToProject(workerID, projectID, userID uint) error {
-	// Verify worker belongs to user
+// AddToProject adds a worker to a project (ensuring both belong to the organization)
+func (r *WorkerRepository) AddToProject(workerID, projectID, orgID, userID uint) error {
+	// Verify worker belongs to organization
 	worker := &model.Worker{}
-	if err := r.db.Where("id = ? AND user_id = ?", workerID, userID).First(worker).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
 		return err
 	}
 	
-	// Verify project belongs to user
+	// Verify project belongs to organization
 	project := &model.Project{}
-	if err := r.db.Where("id = ? AND user_id = ?", projectID, userID).First(project).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(project).Error; err != nil {
 		return err
 	}
 	
-	// Create the join record with user_id
+	// Create the join record with organization_id and the creating user
 	workerProject := &model.WorkerProject{
-		WorkerID:  workerID,
-		ProjectID: projectID,
-		UserID:    userID,
+		WorkerID:       workerID,
+		ProjectID:      projectID,
+		UserID:         userID,
+		OrganizationID: orgID,
 	}
 	
 	// Use the custom join table to create the relationship
 	return r.db.Create(workerProject).Error
 }
 
-// RemoveFromProject removes a worker from a project (ensuring both belong to the user)
-func (r *WorkerRepository) RemoveFromProject(workerID, projectID, userID uint) error {
-	// Verify worker belongs to user
+// RemoveFromProject removes a worker from a project (ensuring both belong to the organization)
+func (r *WorkerRepository) RemoveFromProject(workerID, projectID, orgID uint) error {
+	// Verify worker belongs to organization
 	worker := &model.Worker{}
-	if err := r.db.Where("id = ? AND user_id = ?", workerID, userID).First(worker).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
 		return err
 	}
 	
-	// Verify project belongs to user
+	// Verify project belongs to organization
 	project := &model.Project{}
-	if err := r.db.Where("id = ? AND user_id = ?", projectID, userID).First(project).Error; err != nil {
+	if err := r.db.Where("id = ? AND organization_id = ?", projectID, orgID).First(project).Error; err != nil {
 		return err
 	}
 	
-	// Delete the join record that has the appropriate worker_id, project_id AND user_id
-	return r.db.Where("worker_id = ? AND project_id = ? AND user_id = ?", 
-		workerID, projectID, userID).Delete(&model.WorkerProject{}).Error
-} 
\ No newline at end of file
+	// Delete the join record that has the appropriate worker_id, project_id AND organization_id
+	return r.db.Where("worker_id = ? AND project_id = ? AND organization_id = ?", 
+		workerID, projectID, orgID).Delete(&model.WorkerProject{}).Error
+}
+
+// GetHistory retrieves the position and compensation changes of a worker, the latest first.
+// An empty field returns the changes of all fields.
+func (r *WorkerRepository) GetHistory(workerID uint, orgID uint, fields []string) ([]model.WorkerChange, error) {
+	worker := &model.Worker{}
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
+		return nil, err
+	}
+
+	var changes []model.WorkerChange
+	err := r.db.Where("worker_id = ? AND organization_id = ? AND field IN ?", workerID, orgID, fields).
+		Order("effective_date DESC, id DESC").
+		Find(&changes).Error
+	return changes, err
+}
+
+// GetAsOf reconstructs the position, salary and pay rate of a worker on a past date from its history
+func (r *WorkerRepository) GetAsOf(workerID uint, orgID uint, date time.Time) (*model.WorkerSnapshot, error) {
+	worker := &model.Worker{}
+	if err := r.db.Where("id = ? AND organization_id = ?", workerID, orgID).First(worker).Error; err != nil {
+		return nil, err
+	}
+
+	var changes []model.WorkerChange
+	err := r.db.Where("worker_id = ? AND organization_id = ? AND field IN ?", workerID, orgID,
+		[]string{model.WorkerFieldPosition, model.WorkerFieldSalary}).
+		Order("effective_date, id").
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	date = dateOnly(date)
+	snapshot := &model.WorkerSnapshot{
+		WorkerID: worker.ID,
+		Name:     worker.Name,
+		Date:     date,
+		Position: valueOn(changes, model.WorkerFieldPosition, date, worker.Position),
+	}
+	if salary := valueOn(changes, model.WorkerFieldSalary, date, strconv.Itoa(worker.Salary)); salary != "" {
+		snapshot.Salary, _ = strconv.Atoi(salary)
+	}
+
+	var rate model.PayRate
+	err = r.db.Where("worker_id = ? AND organization_id = ? AND effective_from <= ?", workerID, orgID, date).
+		Order("effective_from DESC").
+		First(&rate).Error
+	switch {
+	case err == nil:
+		snapshot.PayRate = &rate
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// recordWorkerChanges adds a history row for the position and the salary of a worker when they
+// differ between before and after. The change takes effect on the worker's EffectiveDate, today by default.
+func recordWorkerChanges(tx *gorm.DB, before *model.Worker, after *model.Worker, userID uint) error {
+	effective := dateOnly(time.Now().UTC())
+	if after.EffectiveDate != nil {
+		effective = dateOnly(*after.EffectiveDate)
+	}
+
+	var changes []model.WorkerChange
+	if before.Position != after.Position {
+		changes = append(changes, model.WorkerChange{
+			Field:    model.WorkerFieldPosition,
+			OldValue: before.Position,
+			NewValue: after.Position,
+		})
+	}
+	if before.ID == 0 || before.Salary != after.Salary {
+		oldSalary := ""
+		if before.ID != 0 {
+			oldSalary = strconv.Itoa(before.Salary)
+		}
+		changes = append(changes, model.WorkerChange{
+			Field:    model.WorkerFieldSalary,
+			OldValue: oldSalary,
+			NewValue: strconv.Itoa(after.Salary),
+		})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for i := range changes {
+		var later int64
+		err := tx.Model(&model.WorkerChange{}).
+			Where("worker_id = ? AND field = ? AND effective_date > ?", after.ID, changes[i].Field, effective).
+			Count(&later).Error
+		if err != nil {
+			return err
+		}
+		if later > 0 {
+			return ErrBackdatedChange
+		}
+
+		changes[i].WorkerID = after.ID
+		changes[i].EffectiveDate = effective
+		changes[i].Reason = after.ChangeReason
+		changes[i].UserID = userID
+		changes[i].OrganizationID = after.OrganizationID
+	}
+	return tx.Create(&changes).Error
+}
+
+// valueOn returns the value of a field on a date from changes ordered by effective date: the value
+// set by the last change on or before the date, or the value before the first later change, or the
+// current value when the field never changed
+func valueOn(changes []model.WorkerChange, field string, date time.Time, current string) string {
+	var fieldChanges []model.WorkerChange
+	for _, change := range changes {
+		if change.Field == field {
+			fieldChanges = append(fieldChanges, change)
+		}
+	}
+
+	// The first change taking effect after the date
+	next := sort.Search(len(fieldChanges), func(i int) bool {
+		return fieldChanges[i].EffectiveDate.After(date)
+	})
+	switch {
+	case next > 0:
+		return fieldChanges[next-1].NewValue
+	case next < len(fieldChanges):
+		return fieldChanges[next].OldValue
+	}
+	return current
+}
+
+// applyWorkerFilters applies the worker filters shared by the worker list and the available
+// workers of a project:
+//   - "skills" keeps workers holding every listed skill on the "skills_valid_on" date, today by default
+//   - "available_from" and "available_until" keep workers without assignments to ongoing projects or
+//     approved leave in that range
+//   - "exclude_project" drops the workers assigned to a project
+func applyWorkerFilters(db *gorm.DB, query *gorm.DB, filters map[string]interface{}) *gorm.DB {
+	for key, value := range filters {
+		switch key {
+		case "search":
+			searchTerm := value.(string)
+			query = query.Where("name LIKE ? OR position LIKE ?", "%"+searchTerm+"%", "%"+searchTerm+"%")
+		case "position":
+			query = query.Where("position = ?", value)
+		case "min_age":
+			query = query.Where("age >= ?", value)
+		case "max_age":
+			query = query.Where("age <= ?", value)
+		case "min_salary":
+			query = query.Where("salary >= ?", value)
+		case "max_salary":
+			query = query.Where("salary <= ?", value)
+		case "skills":
+			validOn, ok := filters["skills_valid_on"].(time.Time)
+			if !ok {
+				validOn = time.Now()
+			}
+			for _, skillID := range value.([]uint) {
+				query = query.Where("EXISTS (?)", validSkillQuery(db, skillID, validOn))
+			}
+		case "skills_valid_on":
+			// Applied together with "skills"
+		case "available_from", "available_until":
+			from, _ := filters["available_from"].(time.Time)
+			until, hasUntil := filters["available_until"].(time.Time)
+			if key == "available_until" && filters["available_from"] != nil {
+				// Applied once together with "available_from"
+				continue
+			}
+			query = query.Where("NOT EXISTS (?)", busyQuery(db, from, until, hasUntil)).
+				Where("NOT EXISTS (?)", leaveQuery(db, from, until, hasUntil))
+		case "exclude_project":
+			query = query.Where("NOT EXISTS (?)", db.Table("worker_projects").Select("1").
+				Where("worker_projects.worker_id = workers.id AND worker_projects.project_id = ?", value))
+		default:
+			query = query.Where(key+" = ?", value)
+		}
+	}
+
+	return query
+}
+
+// busyQuery selects the assignments of the outer query's worker to active or on hold projects
+// overlapping the range from until. Without until the range is open ended.
+func busyQuery(db *gorm.DB, from, until time.Time, hasUntil bool) *gorm.DB {
+	query := db.Table("worker_projects").Select("1").
+		Joins("JOIN projects ON projects.id = worker_projects.project_id AND projects.deleted_at IS NULL").
+		Where("worker_projects.worker_id = workers.id").
+		Where("projects.status NOT IN ?", []string{"completed", "cancelled"}).
+		Where("worker_projects.end_date IS NULL OR worker_projects.end_date >= ?", from)
+	if hasUntil {
+		query = query.Where("worker_projects.start_date IS NULL OR worker_projects.start_date <= ?", until)
+	}
+	return query
+}
+
+// leaveQuery selects the approved leave of the outer query's worker overlapping the range from until.
+// Without until the range is open ended.
+func leaveQuery(db *gorm.DB, from, until time.Time, hasUntil bool) *gorm.DB {
+	query := db.Model(&model.LeaveRequest{}).Select("1").
+		Where("leave_requests.worker_id = workers.id AND leave_requests.status = ?", model.LeaveStatusApproved).
+		Where("leave_requests.end_date >= ?", from)
+	if hasUntil {
+		query = query.Where("leave_requests.start_date <= ?", until)
+	}
+	return query
+}
+
+// validSkillQuery selects the skill records of the outer query's worker that hold the skill on the given date
+func validSkillQuery(db *gorm.DB, skillID uint, date time.Time) *gorm.DB {
+	return db.Model(&model.WorkerSkill{}).Select("1").
+		Where("worker_skills.worker_id = workers.id AND worker_skills.skill_id = ?", skillID).
+		Where("worker_skills.issue_date IS NULL OR worker_skills.issue_date <= ?", date).
+		Where("worker_skills.expiry_date IS NULL OR worker_skills.expiry_date >= ?", date)
+}
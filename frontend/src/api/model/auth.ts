@@ -14,5 +14,7 @@ export type RegisterRequest = {
 
 export type AuthResponse = {
   token: string
+  refresh_token?: string
+  expires_in?: number // Access token lifetime in seconds
   user: User
 }
@@ -18,7 +18,7 @@ export const adminService = {
       queryParams.append('search', search)
     }
 
-    const response = await fetch(`${API_URL}/users?${queryParams.toString()}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/users?${queryParams.toString()}`, {
       method: 'GET',
       headers: {
         'Content-Type': 'application/json',
@@ -36,7 +36,7 @@ export const adminService = {
 
   // Update user status (activate/deactivate)
   async updateUserStatus(userId: number, active: boolean): Promise<void> {
-    const response = await fetch(`${API_URL}/users/${userId}/status`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/users/${userId}/status`, {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
@@ -53,7 +53,7 @@ export const adminService = {
 
   // Update user role
   async updateUserRole(userId: number, role: string): Promise<void> {
-    const response = await fetch(`${API_URL}/users/${userId}/role`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/users/${userId}/role`, {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
@@ -75,13 +75,16 @@ export const adminService = {
       pageSize: pageSize.toString()
     })
 
-    const response = await fetch(`${API_URL}/users/${userId}/activity?${queryParams.toString()}`, {
-      method: 'GET',
-      headers: {
-        'Content-Type': 'application/json',
-        ...authService.getAuthHeaders()
+    const response = await authService.fetchWithAuth(
+      `${API_URL}/users/${userId}/activity?${queryParams.toString()}`,
+      {
+        method: 'GET',
+        headers: {
+          'Content-Type': 'application/json',
+          ...authService.getAuthHeaders()
+        }
       }
-    })
+    )
 
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({}))
@@ -6,8 +6,15 @@ const API_URL = 'https://backend-c9ng.onrender.com/api'
 
 // Local storage keys
 const TOKEN_KEY = 'auth_token'
+const REFRESH_TOKEN_KEY = 'auth_refresh_token'
 const USER_KEY = 'auth_user'
 
+// Event dispatched on window when the session cannot be refreshed and the user was logged out
+export const SESSION_EXPIRED_EVENT = 'auth:session-expired'
+
+// Refresh in progress, shared by requests that fail at the same time so the refresh token is used once
+let refreshPromise: Promise<boolean> | null = null
+
 // Authentication service
 // Handles login, registration, and token management
 export const authService = {
@@ -28,9 +35,8 @@ export const authService = {
 
     const data = await response.json()
 
-    // Store token and user in local storage
-    localStorage.setItem(TOKEN_KEY, data.token)
-    localStorage.setItem(USER_KEY, JSON.stringify(data.user))
+    // Store tokens and user in local storage
+    this.storeSession(data)
 
     return data
   },
@@ -52,9 +58,8 @@ export const authService = {
 
     const data = await response.json()
 
-    // Store token and user in local storage
-    localStorage.setItem(TOKEN_KEY, data.token)
-    localStorage.setItem(USER_KEY, JSON.stringify(data.user))
+    // Store tokens and user in local storage
+    this.storeSession(data)
 
     return data
   },
@@ -86,12 +91,79 @@ export const authService = {
   // Logout the current user
   logout(): void {
     localStorage.removeItem(TOKEN_KEY)
+    localStorage.removeItem(REFRESH_TOKEN_KEY)
     localStorage.removeItem(USER_KEY)
   },
 
+  // Store the tokens and user of a login, registration or refresh response
+  storeSession(data: AuthResponse): void {
+    localStorage.setItem(TOKEN_KEY, data.token)
+    if (data.refresh_token) {
+      localStorage.setItem(REFRESH_TOKEN_KEY, data.refresh_token)
+    }
+    localStorage.setItem(USER_KEY, JSON.stringify(data.user))
+  },
+
+  // Exchange the refresh token for a new token pair
+  // Resolves to false, and logs the user out, when the session cannot be refreshed
+  refresh(): Promise<boolean> {
+    if (refreshPromise) return refreshPromise
+
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY)
+    if (!refreshToken) return Promise.resolve(false)
+
+    refreshPromise = (async () => {
+      try {
+        const response = await fetch(`${API_URL}/auth/refresh`, {
+          method: 'POST',
+          headers: {
+            'Content-Type': 'application/json'
+          },
+          body: JSON.stringify({ refresh_token: refreshToken })
+        })
+
+        if (!response.ok) {
+          throw new Error('Session refresh failed')
+        }
+
+        this.storeSession(await response.json())
+        return true
+      } catch (error) {
+        console.error('Error refreshing session:', error)
+        this.logout()
+        window.dispatchEvent(new Event(SESSION_EXPIRED_EVENT))
+        return false
+      } finally {
+        refreshPromise = null
+      }
+    })()
+
+    return refreshPromise
+  },
+
+  // Fetch with the current access token
+  // A 401 response refreshes the session and retries the request once with the new token
+  async fetchWithAuth(url: string, init: RequestInit = {}): Promise<Response> {
+    const response = await fetch(url, withAuthHeaders(init, this.getToken()))
+    if (response.status !== 401 || !(await this.refresh())) {
+      return response
+    }
+
+    return fetch(url, withAuthHeaders(init, this.getToken()))
+  },
+
   // Get authentication headers for API requests
   getAuthHeaders(): HeadersInit {
     const token = this.getToken()
     return token ? { Authorization: `Bearer ${token}` } : {}
   }
 }
+
+// Copy request options with the Authorization header set to the given token
+function withAuthHeaders(init: RequestInit, token: string | null): RequestInit {
+  const headers = new Headers(init.headers)
+  if (token) {
+    headers.set('Authorization', `Bearer ${token}`)
+  }
+  return { ...init, headers }
+}
@@ -52,7 +52,7 @@ export const projectsService = {
     const url = `${API_URL}/projects${queryParams.toString() ? `?${queryParams.toString()}` : ''}`
 
     try {
-      const response = await fetch(url, {
+      const response = await authService.fetchWithAuth(url, {
         headers: {
           ...authService.getAuthHeaders()
         }
@@ -91,7 +91,7 @@ export const projectsService = {
 
   // Get a project by ID
   async getById(id: number): Promise<Project> {
-    const response = await fetch(`${API_URL}/projects/${id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/projects/${id}`, {
       headers: {
         ...authService.getAuthHeaders()
       }
@@ -109,7 +109,7 @@ export const projectsService = {
 
   // Add a new project
   async create(project: Omit<Project, 'id'>): Promise<Project> {
-    const response = await fetch(`${API_URL}/projects`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/projects`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -129,7 +129,7 @@ export const projectsService = {
 
   // Update an existing project
   async update(id: number, project: Partial<Project>): Promise<Project> {
-    const response = await fetch(`${API_URL}/projects/${id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/projects/${id}`, {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
@@ -149,7 +149,7 @@ export const projectsService = {
 
   // Delete a project
   async delete(id: number): Promise<void> {
-    const response = await fetch(`${API_URL}/projects/${id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/projects/${id}`, {
       method: 'DELETE',
       headers: {
         ...authService.getAuthHeaders()
@@ -165,7 +165,7 @@ export const projectsService = {
 
   // Assign a worker to a project
   async assignWorker(projectId: number, workerId: number): Promise<Project> {
-    const response = await fetch(`${API_URL}/projects/${projectId}/workers`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/projects/${projectId}/workers`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -185,12 +185,15 @@ export const projectsService = {
 
   // Unassign a worker from a project
   async unassignWorker(projectId: number, workerId: number): Promise<Project> {
-    const response = await fetch(`${API_URL}/projects/${projectId}/workers/${workerId}`, {
-      method: 'DELETE',
-      headers: {
-        ...authService.getAuthHeaders()
+    const response = await authService.fetchWithAuth(
+      `${API_URL}/projects/${projectId}/workers/${workerId}`,
+      {
+        method: 'DELETE',
+        headers: {
+          ...authService.getAuthHeaders()
+        }
       }
-    })
+    )
 
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({}))
@@ -218,7 +221,7 @@ export const projectsService = {
       queryParams.toString() ? `?${queryParams.toString()}` : ''
     }`
 
-    const response = await fetch(url, {
+    const response = await authService.fetchWithAuth(url, {
       headers: {
         ...authService.getAuthHeaders()
       }
@@ -64,7 +64,7 @@ export const workersService = {
     const url = `${API_URL}/workers${queryParams.toString() ? `?${queryParams.toString()}` : ''}`
 
     try {
-      const response = await fetch(url, {
+      const response = await authService.fetchWithAuth(url, {
         headers: {
           ...authService.getAuthHeaders()
         }
@@ -89,7 +89,7 @@ export const workersService = {
 
   // Get a worker by ID
   async getById(id: number): Promise<Worker> {
-    const response = await fetch(`${API_URL}/workers/${id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/workers/${id}`, {
       headers: {
         ...authService.getAuthHeaders()
       }
@@ -107,7 +107,7 @@ export const workersService = {
 
   // Add a new worker
   async create(worker: Omit<Worker, 'id'>): Promise<Worker> {
-    const response = await fetch(`${API_URL}/workers`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/workers`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
@@ -127,7 +127,7 @@ export const workersService = {
 
   // Update an existing worker
   async update(worker: Worker): Promise<Worker> {
-    const response = await fetch(`${API_URL}/workers/${worker.id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/workers/${worker.id}`, {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
@@ -147,7 +147,7 @@ export const workersService = {
 
   // Delete a worker
   async delete(id: number): Promise<void> {
-    const response = await fetch(`${API_URL}/workers/${id}`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/workers/${id}`, {
       method: 'DELETE',
       headers: {
         ...authService.getAuthHeaders()
@@ -163,7 +163,7 @@ export const workersService = {
 
   // Delete multiple workers
   async deleteMany(ids: number[]): Promise<void> {
-    const response = await fetch(`${API_URL}/workers/batch`, {
+    const response = await authService.fetchWithAuth(`${API_URL}/workers/batch`, {
       method: 'DELETE',
       headers: {
         'Content-Type': 'application/json',
@@ -1,7 +1,7 @@
 import { createContext, useContext, useState, useEffect, ReactNode } from 'react'
 import { useNavigate } from 'react-router-dom'
 import { User } from '@/api/model/user'
-import { authService } from '@/api/services/auth.service'
+import { authService, SESSION_EXPIRED_EVENT } from '@/api/services/auth.service'
 import { toast } from 'sonner'
 import { LoginRequest, RegisterRequest } from '@/api/model/auth'
 
@@ -47,6 +47,18 @@ export function AuthProvider({ children }: AuthProviderProps) {
     initAuth()
   }, [])
 
+  // Return to the start page when the session can no longer be refreshed
+  useEffect(() => {
+    const handleSessionExpired = () => {
+      setUser(null)
+      navigate('/')
+      toast.error('Your session has expired, please log in again')
+    }
+
+    window.addEventListener(SESSION_EXPIRED_EVENT, handleSessionExpired)
+    return () => window.removeEventListener(SESSION_EXPIRED_EVENT, handleSessionExpired)
+  }, [navigate])
+
   // Login function
   const login = async (credentials: LoginRequest) => {
     try {